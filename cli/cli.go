@@ -11,6 +11,7 @@ import (
 
 	"github.com/TouchBistro/goutils/log"
 	"github.com/TouchBistro/goutils/progress"
+	"github.com/TouchBistro/tb/config"
 	"github.com/TouchBistro/tb/engine"
 	"github.com/spf13/cobra"
 )
@@ -37,6 +38,9 @@ type Container struct {
 	Engine  *engine.Engine
 	Tracker progress.Tracker
 	Verbose bool
+	// ConfigOptions are the options used to read the tbrc. Commands that read the tbrc
+	// should use them so that settings passed with the --set flag are applied.
+	ConfigOptions config.ReadOptions
 	// Ctx is the context that should be used within a command to carry deadlines and cancellation signals.
 	Ctx context.Context
 	// This is only here for cleanup purposes, don't use it directly, use Tracker instead.
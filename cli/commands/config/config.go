@@ -0,0 +1,37 @@
+package config
+
+import (
+	"github.com/TouchBistro/tb/cli"
+	"github.com/spf13/cobra"
+)
+
+func NewConfigCommand(c *cli.Container) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage tbrc settings from the command line",
+		Long: `tb config manages the settings in .tbrc.yml files from the command line.
+
+Settings are read from the .tbrc.yml in the home directory, as well as any project .tbrc.yml
+files in the current directory and its parents. Project files take priority over the home file,
+and files closer to the current directory take priority over ones further away.
+Project files are only used once they have been trusted with tb config trust.
+
+Settings are identified by keys, which are paths of settings separated by dots,
+ex: overrides.TouchBistro/tb-registry/postgres.remote.tag. Entries of lists are identified
+by their index, or by their name for registries, ex: registries.TouchBistro/tb-registry.ref.
+Parts of a key that contain dots must be quoted with double quotes,
+ex: registries."ExampleZone/tb-registry.v2".ref.`,
+	}
+	configCmd.AddCommand(
+		newGetCommand(c),
+		newMigrateCommand(c),
+		newProfilesCommand(c),
+		newSetCommand(c),
+		newSourcesCommand(c),
+		newTrustCommand(c),
+		newUnsetCommand(c),
+		newUntrustCommand(c),
+		newViewCommand(c),
+	)
+	return configCmd
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func newGetCommand(c *cli.Container) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <key>",
+		Args:  cli.ExpectSingleArg("setting key"),
+		Short: "Print the value of a setting",
+		Long: `Prints the value of a setting from the merged tbrc files, with the active profile applied.
+
+Keys are paths of settings separated by dots. Entries of lists are identified by their index,
+or by their name for registries. Settings that are objects or lists are printed as yaml.
+
+Examples:
+
+Print whether experimental mode is enabled:
+
+	tb config get experimental
+
+Print the remote tag override of the postgres service:
+
+	tb config get overrides.TouchBistro/tb-registry/postgres.remote.tag
+
+Print the configuration of a registry:
+
+	tb config get registries.TouchBistro/tb-registry`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := args[0]
+			cfg, err := config.Read(c.ConfigOptions)
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to load tbrc",
+					Err: err,
+				}
+			}
+			n, err := config.GetSetting(cfg, key)
+			if errors.Is(err, config.ErrSettingNotFound) {
+				return &fatal.Error{Msg: fmt.Sprintf("%s is not set", key)}
+			} else if err != nil {
+				return &fatal.Error{
+					Msg: fmt.Sprintf("Failed to get %s", key),
+					Err: err,
+				}
+			}
+			if n.Kind == yaml.ScalarNode {
+				fmt.Println(n.Value)
+				return nil
+			}
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			if err := enc.Encode(n); err != nil {
+				return &fatal.Error{Msg: fmt.Sprintf("Failed to write %s", key), Err: err}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/TouchBistro/goutils/color"
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+)
+
+type migrateOptions struct {
+	check bool
+	file  string
+}
+
+func newMigrateCommand(c *cli.Container) *cobra.Command {
+	var opts migrateOptions
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Args:  cobra.NoArgs,
+		Short: "Migrate tbrc files to the latest format",
+		Long: `Migrates .tbrc.yml files that use an old version of the format to the latest version.
+By default the .tbrc.yml in the home directory and any project .tbrc.yml files are migrated,
+the --file flag can be used to only migrate a single file.
+
+Files are changed in place and comments are preserved. Before a file is changed, a backup of the
+original file is written next to it, ex: .tbrc.yml.v0.bak.
+
+Files are only migrated by this command, however tb understands old versions of the format when
+reading them. tb prints a warning when the .tbrc.yml in the home directory needs to be migrated.
+
+The --check flag can be used to report the migrations that are needed without changing any files.
+The command fails if any file needs to be migrated, which makes it useful in CI.
+
+Examples:
+
+Migrate all tbrc files:
+
+	tb config migrate
+
+Check if the project .tbrc.yml needs to be migrated:
+
+	tb config migrate --check --file .tbrc.yml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files := []string{opts.file}
+			if opts.file == "" {
+				cfg, err := config.Read(c.ConfigOptions)
+				if err != nil {
+					return &fatal.Error{
+						Msg: "Failed to load tbrc",
+						Err: err,
+					}
+				}
+				files = cfg.Files
+			}
+
+			needed := 0
+			for _, f := range files {
+				result, err := config.Migrate(config.MigrateOptions{File: f, Check: opts.check})
+				if err != nil {
+					return &fatal.Error{
+						Msg: fmt.Sprintf("Failed to migrate %s", f),
+						Err: err,
+					}
+				}
+				if !result.Needed() {
+					c.Tracker.Infof(color.Green("☑ %s is up to date"), result.Path)
+					continue
+				}
+				needed++
+				if opts.check {
+					c.Tracker.Infof("%s needs to be migrated from version %d to %d:", color.Cyan(result.Path), result.FromVersion, result.ToVersion)
+				} else {
+					c.Tracker.Infof("Migrated %s from version %d to %d:", color.Cyan(result.Path), result.FromVersion, result.ToVersion)
+				}
+				for _, change := range result.Changes {
+					c.Tracker.Infof("  - %s", change)
+				}
+				if result.BackupPath != "" {
+					c.Tracker.Infof("  Backup of the original file: %s", result.BackupPath)
+				}
+			}
+			if opts.check && needed > 0 {
+				return &fatal.Error{Msg: fmt.Sprintf("%d tbrc file(s) need to be migrated, run 'tb config migrate' to migrate them", needed)}
+			}
+			return nil
+		},
+	}
+	flags := migrateCmd.Flags()
+	flags.BoolVar(&opts.check, "check", false, "Report the migrations that are needed without changing any files")
+	flags.StringVar(&opts.file, "file", "", "Path of a tbrc file to migrate, defaults to all tbrc files")
+	return migrateCmd
+}
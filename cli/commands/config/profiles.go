@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+)
+
+func newProfilesCommand(c *cli.Container) *cobra.Command {
+	return &cobra.Command{
+		Use:   "profiles",
+		Args:  cobra.NoArgs,
+		Short: "List profiles and show the active one",
+		Long: `Lists the profiles defined in tbrc files along with how many overrides, playlists,
+and registries each one has. The active profile is marked with a *.
+
+The active profile is set with the profile setting in a tbrc file, the TB_PROFILE environment
+variable, or the --profile flag, in order of increasing priority.
+
+Examples:
+
+List all profiles:
+
+	tb config profiles
+
+Use the profile named venue-core:
+
+	tb up --profile venue-core`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Read(c.ConfigOptions)
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to load tbrc",
+					Err: err,
+				}
+			}
+
+			names := make([]string, 0, len(cfg.Profiles))
+			for name := range cfg.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if len(names) > 0 {
+				tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(tw, "  NAME\tOVERRIDES\tPLAYLISTS\tREGISTRIES")
+				for _, name := range names {
+					p := cfg.Profiles[name]
+					marker := " "
+					if name == cfg.Profile {
+						marker = "*"
+					}
+					fmt.Fprintf(tw, "%s %s\t%d\t%d\t%d\n", marker, name, len(p.Overrides), len(p.Playlists), len(p.Registries))
+				}
+				if err := tw.Flush(); err != nil {
+					return &fatal.Error{Msg: "Failed to write profiles", Err: err}
+				}
+				fmt.Println()
+			} else {
+				fmt.Println("No profiles defined")
+			}
+
+			switch _, ok := cfg.Profiles[cfg.Profile]; {
+			case cfg.Profile == "":
+				fmt.Println("No active profile")
+			case !ok:
+				fmt.Printf("Active profile %s from %s does not exist\n", cfg.Profile, cfg.Sources["profile"])
+			default:
+				fmt.Printf("Active profile: %s (from %s)\n", cfg.Profile, cfg.Sources["profile"])
+			}
+			return nil
+		},
+	}
+}
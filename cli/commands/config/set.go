@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/TouchBistro/goutils/color"
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+)
+
+func newSetCommand(c *cli.Container) *cobra.Command {
+	var file string
+	setCmd := &cobra.Command{
+		Use: "set <key> <value>",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return fmt.Errorf("expected 2 args for setting key and value, received %d args", len(args))
+			}
+			return nil
+		},
+		Short: "Set the value of a setting",
+		Long: `Sets the value of a setting in a tbrc file. By default the .tbrc.yml in the home directory
+is changed, the --file flag can be used to change a project .tbrc.yml instead.
+
+Keys are paths of settings separated by dots. Entries of lists are identified by their index,
+or by their name for registries. Any settings in the key that don't exist yet are created.
+The value is parsed as yaml, which allows setting objects and lists.
+
+The key and value are validated before the file is changed. Comments in the file are preserved.
+
+Examples:
+
+Enable experimental mode:
+
+	tb config set experimental true
+
+Use a specific image tag for the postgres service:
+
+	tb config set overrides.TouchBistro/tb-registry/postgres.remote.tag 14-alpine
+
+Use a specific ref of a registry:
+
+	tb config set registries.TouchBistro/tb-registry.ref v1.2.0
+
+Set the services of a playlist in a project .tbrc.yml:
+
+	tb config set --file .tbrc.yml playlists.db.services '[TouchBistro/tb-registry/postgres]'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, value := args[0], args[1]
+			if err := config.SetSetting(key, value, config.EditOptions{File: file}); err != nil {
+				return &fatal.Error{
+					Msg: fmt.Sprintf("Failed to set %s", key),
+					Err: err,
+				}
+			}
+			c.Tracker.Infof(color.Green("Successfully set %s"), key)
+			return nil
+		},
+	}
+	setCmd.Flags().StringVar(&file, "file", "", "Path of the tbrc file to change, defaults to the .tbrc.yml in the home directory")
+	return setCmd
+}
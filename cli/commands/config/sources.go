@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+)
+
+func newSourcesCommand(c *cli.Container) *cobra.Command {
+	return &cobra.Command{
+		Use:   "sources",
+		Args:  cobra.NoArgs,
+		Short: "Show where each setting comes from",
+		Long: `Shows the .tbrc.yml files that were read, in order of increasing priority,
+along with where each setting comes from. Settings come from a .tbrc.yml file, the active profile,
+a TB_* environment variable, ex: $TB_EXPERIMENTAL, or the --set flag. Project .tbrc.yml files
+that are not trusted are ignored and listed separately.
+
+Entries of playlists, overrides, variables, and workspaces are shown individually,
+ex: overrides.TouchBistro/tb-registry/postgres, as are registries, ex: registries.TouchBistro/tb-registry.
+
+Examples:
+
+Show where each setting comes from:
+
+	tb config sources`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Read(c.ConfigOptions)
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to load tbrc",
+					Err: err,
+				}
+			}
+
+			fmt.Println("Files (lowest to highest priority):")
+			for _, f := range cfg.Files {
+				fmt.Printf("  - %s\n", f)
+			}
+			fmt.Println()
+			if len(cfg.UntrustedFiles) > 0 {
+				fmt.Println("Untrusted files (ignored, use 'tb config trust' to use them):")
+				for _, f := range cfg.UntrustedFiles {
+					fmt.Printf("  - %s\n", f)
+				}
+				fmt.Println()
+			}
+
+			settings := make([]string, 0, len(cfg.Sources))
+			for s := range cfg.Sources {
+				settings = append(settings, s)
+			}
+			sort.Strings(settings)
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "SETTING\tSOURCE")
+			for _, s := range settings {
+				fmt.Fprintf(tw, "%s\t%s\n", s, cfg.Sources[s])
+			}
+			if err := tw.Flush(); err != nil {
+				return &fatal.Error{Msg: "Failed to write settings", Err: err}
+			}
+			return nil
+		},
+	}
+}
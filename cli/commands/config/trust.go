@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/TouchBistro/goutils/color"
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+)
+
+func newTrustCommand(c *cli.Container) *cobra.Command {
+	return &cobra.Command{
+		Use:   "trust [file]",
+		Args:  cobra.MaximumNArgs(1),
+		Short: "Trust a project .tbrc.yml",
+		Long: `Trusts a project .tbrc.yml so that tb uses it. If no file is given, the .tbrc.yml in the
+current directory is trusted.
+
+Project .tbrc.yml files can add registries and overrides, which control the containers tb runs,
+so they are ignored until they are trusted. Review the file before trusting it. A trusted file is
+ignored again when it changes, ex: after a git pull, review the changes and trust it again to use it.
+Changes made with tb config set --file and tb config migrate keep the file trusted.
+
+Examples:
+
+Trust the .tbrc.yml in the current directory:
+
+	tb config trust`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := ".tbrc.yml"
+			if len(args) > 0 {
+				path = args[0]
+			}
+			if err := config.TrustFile(path, config.TrustOptions{}); err != nil {
+				return &fatal.Error{
+					Msg: fmt.Sprintf("Failed to trust %s", path),
+					Err: err,
+				}
+			}
+			c.Tracker.Infof(color.Green("Successfully trusted %s"), path)
+			return nil
+		},
+	}
+}
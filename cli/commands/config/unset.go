@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TouchBistro/goutils/color"
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+)
+
+func newUnsetCommand(c *cli.Container) *cobra.Command {
+	var file string
+	unsetCmd := &cobra.Command{
+		Use:   "unset <key>",
+		Args:  cli.ExpectSingleArg("setting key"),
+		Short: "Remove a setting",
+		Long: `Removes a setting from a tbrc file. By default the .tbrc.yml in the home directory
+is changed, the --file flag can be used to change a project .tbrc.yml instead.
+If the setting is not set in the file, the command will no-op.
+
+Keys are paths of settings separated by dots. Entries of lists are identified by their index,
+or by their name for registries. Comments in the file are preserved.
+
+Examples:
+
+Remove the overrides of the postgres service:
+
+	tb config unset overrides.TouchBistro/tb-registry/postgres
+
+Remove a registry:
+
+	tb config unset registries.TouchBistro/tb-registry-example`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := args[0]
+			err := config.UnsetSetting(key, config.EditOptions{File: file})
+			if errors.Is(err, config.ErrSettingNotFound) {
+				c.Tracker.Infof(color.Green("☑ %s is not set"), key)
+				return nil
+			} else if err != nil {
+				return &fatal.Error{
+					Msg: fmt.Sprintf("Failed to unset %s", key),
+					Err: err,
+				}
+			}
+			c.Tracker.Infof(color.Green("Successfully unset %s"), key)
+			return nil
+		},
+	}
+	unsetCmd.Flags().StringVar(&file, "file", "", "Path of the tbrc file to change, defaults to the .tbrc.yml in the home directory")
+	return unsetCmd
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TouchBistro/goutils/color"
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+)
+
+func newUntrustCommand(c *cli.Container) *cobra.Command {
+	return &cobra.Command{
+		Use:   "untrust [file]",
+		Args:  cobra.MaximumNArgs(1),
+		Short: "Stop trusting a project .tbrc.yml",
+		Long: `Stops trusting a project .tbrc.yml so that tb no longer uses it. If no file is given, the
+.tbrc.yml in the current directory is untrusted. If the file is not trusted, the command will no-op.
+
+Examples:
+
+Stop trusting the .tbrc.yml in the current directory:
+
+	tb config untrust`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := ".tbrc.yml"
+			if len(args) > 0 {
+				path = args[0]
+			}
+			err := config.UntrustFile(path, config.TrustOptions{})
+			if errors.Is(err, config.ErrFileNotTrusted) {
+				c.Tracker.Infof(color.Green("☑ %s is not trusted"), path)
+				return nil
+			} else if err != nil {
+				return &fatal.Error{
+					Msg: fmt.Sprintf("Failed to untrust %s", path),
+					Err: err,
+				}
+			}
+			c.Tracker.Infof(color.Green("Successfully untrusted %s"), path)
+			return nil
+		},
+	}
+}
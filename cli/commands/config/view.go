@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func newViewCommand(c *cli.Container) *cobra.Command {
+	return &cobra.Command{
+		Use:   "view",
+		Args:  cobra.NoArgs,
+		Short: "Print the merged settings",
+		Long: `Prints the settings that tb uses, after merging all tbrc files and applying TB_* environment
+variables and --set flags, as yaml. The active profile is applied as well.
+Settings that are not set are omitted.
+
+Use tb config sources to see which file each setting comes from.
+
+Examples:
+
+Print the merged settings:
+
+	tb config view`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Read(c.ConfigOptions)
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to load tbrc",
+					Err: err,
+				}
+			}
+			n, err := config.EncodeSettings(cfg)
+			if err != nil {
+				return &fatal.Error{Msg: "Failed to encode settings", Err: err}
+			}
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			if err := enc.Encode(n); err != nil {
+				return &fatal.Error{Msg: "Failed to write settings", Err: err}
+			}
+			return nil
+		},
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/TouchBistro/goutils/command"
@@ -135,6 +136,14 @@ func getDbConf(ctx context.Context, c *cli.Container, serviceName string) (dbCon
 		user:     result[3],
 		password: result[4],
 	}
+	// The port is published on the host with the offset of the workspace applied.
+	if offset := c.Engine.PortOffset(); offset != 0 {
+		port, err := strconv.Atoi(conf.port)
+		if err != nil {
+			return dbConfig{}, fmt.Errorf("invalid DB_PORT %q: %w", conf.port, err)
+		}
+		conf.port = strconv.Itoa(port + offset)
+	}
 
 	return conf, nil
 }
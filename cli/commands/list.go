@@ -26,6 +26,10 @@ func newListCommand(c *cli.Container) *cobra.Command {
 		Long: `Lists available services, playlists, and custom playlists.
 Custom playlists are playlists that are defined in a user's .tbrc.yml.
 
+If a service or playlist is shadowed, the resource that its name resolves to is shown next to it.
+A resource is shadowed if it was replaced by a resource in another registry, if a resource with
+the same name is in a registry with a higher priority, or if a custom playlist has the same name.
+
 Examples:
 
 List all services, playlists, and custom playlists:
@@ -57,7 +61,7 @@ List only custom playlists along with the services in each playlist (tree mode):
 				fmt.Println("Services:")
 				sort.Strings(listResult.Services)
 				for _, n := range listResult.Services {
-					fmt.Printf("  - %s\n", n)
+					fmt.Printf("  - %s%s\n", n, shadowedSuffix(listResult.ShadowedServices[n]))
 				}
 			}
 			if opts.listPlaylists {
@@ -84,7 +88,7 @@ func printPlaylists(playlists []engine.PlaylistSummary, tree bool) {
 		return playlists[i].Name < playlists[j].Name
 	})
 	for _, ps := range playlists {
-		fmt.Printf("  - %s\n", ps.Name)
+		fmt.Printf("  - %s%s\n", ps.Name, shadowedSuffix(ps.ShadowedBy))
 		if !tree {
 			continue
 		}
@@ -94,3 +98,11 @@ func printPlaylists(playlists []engine.PlaylistSummary, tree bool) {
 		}
 	}
 }
+
+// shadowedSuffix returns the text to show after a resource that is shadowed by the resource named by.
+func shadowedSuffix(by string) string {
+	if by == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (shadowed by %s)", by)
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"os"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/TouchBistro/goutils/fatal"
@@ -37,11 +38,19 @@ Flags may be provided to bypass the prompt and specify which resources to remove
 The special --all flag causes all resources to be removed, and also removes the
 directory where tb stores data.
 
+Only the docker resources of the current workspace are removed. If other workspaces exist,
+--all keeps their data so they can still be removed with --workspace.
+
 If any docker resources are specified to be removed, any running service containers will
 first be stopped and all service containers will be removed.
 
 tb nuke will not remove any docker resources that are not managed by tb.
 
+If --workspace is provided, only the docker resources belonging to that workspace are removed,
+other workspaces are left alone. Remote images, git repos, apps, and registries are shared by
+all workspaces so they cannot be removed for a single workspace. In this case --all removes all
+docker resources of the workspace along with its data.
+
 Examples:
 
 Prompt to select resources to remove:
@@ -58,7 +67,11 @@ Remove all downloaded iOS and desktop apps:
 
 Remove everything (completely wipe all tb data):
 
-	tb nuke --all`,
+	tb nuke --all
+
+Remove everything belonging to the feature-x workspace:
+
+	tb nuke --workspace feature-x --all`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// If no flags were provided do an interactive prompt and ask the user
 			// what they would like to remove.
@@ -73,10 +86,18 @@ Remove everything (completely wipe all tb data):
 					{"Images", &opts.nukeImages},
 					{"Volumes", &opts.nukeVolumes},
 					{"Networks", &opts.nukeNetworks},
-					{"Repos", &opts.nukeRepos},
-					{"Desktop Apps", &opts.nukeDesktopApps},
-					{"iOS Apps", &opts.nukeIOSBuilds},
-					{"Registries", &opts.nukeRegistries},
+				}
+				// Only docker resources can be removed for a single workspace.
+				if c.Engine.Workspace() == "" {
+					choices = append(choices, []struct {
+						name        string
+						optionField *bool
+					}{
+						{"Repos", &opts.nukeRepos},
+						{"Desktop Apps", &opts.nukeDesktopApps},
+						{"iOS Apps", &opts.nukeIOSBuilds},
+						{"Registries", &opts.nukeRegistries},
+					}...)
 				}
 				var promptOptions []string
 				for _, c := range choices {
@@ -101,15 +122,17 @@ Remove everything (completely wipe all tb data):
 					*choices[si].optionField = true
 				}
 			}
+			// Shared resources are not part of --all for a single workspace.
+			nukeShared := opts.nukeAll && c.Engine.Workspace() == ""
 			err := c.Engine.Nuke(c.Ctx, engine.NukeOptions{
 				RemoveContainers:  opts.nukeContainers || opts.nukeAll,
 				RemoveImages:      opts.nukeImages || opts.nukeAll,
 				RemoveNetworks:    opts.nukeNetworks || opts.nukeAll,
 				RemoveVolumes:     opts.nukeVolumes || opts.nukeAll,
-				RemoveRepos:       opts.nukeRepos || opts.nukeAll,
-				RemoveDesktopApps: opts.nukeDesktopApps || opts.nukeAll,
-				RemoveiOSApps:     opts.nukeIOSBuilds || opts.nukeAll,
-				RemoveRegistries:  opts.nukeRegistries || opts.nukeAll,
+				RemoveRepos:       opts.nukeRepos || nukeShared,
+				RemoveDesktopApps: opts.nukeDesktopApps || nukeShared,
+				RemoveiOSApps:     opts.nukeIOSBuilds || nukeShared,
+				RemoveRegistries:  opts.nukeRegistries || nukeShared,
 			})
 			if err != nil {
 				return &fatal.Error{
@@ -119,8 +142,20 @@ Remove everything (completely wipe all tb data):
 			}
 
 			// If --all was used removed the entire .tb dir as a way to completely clean up all trace of tb.
-			if opts.nukeAll {
-				if err := os.RemoveAll(c.Engine.Workdir()); err != nil {
+			// Nuke already removed the data of the workspace if one was used.
+			// The docker resources of other workspaces are not removed, so their data is kept
+			// to still be able to stop and remove them with --workspace.
+			if nukeShared {
+				workspaces, err := c.Engine.Workspaces()
+				if err != nil {
+					return &fatal.Error{
+						Msg: "Failed to find workspaces",
+						Err: err,
+					}
+				}
+				if len(workspaces) > 0 {
+					c.Tracker.Warnf("Kept the data of workspaces %s, use 'tb nuke --workspace <name> --all' to remove them", strings.Join(workspaces, ", "))
+				} else if err := os.RemoveAll(c.Engine.Workdir()); err != nil {
 					return &fatal.Error{
 						Msg: "Failed to remove .tb root directory",
 						Err: err,
@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+)
+
+func newListCommand(c *cli.Container) *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		Short:   "List registries",
+		Long: `Lists all registries in .tbrc.yml along with details about each one.
+
+For each registry the path, checked out commit, date of the commit, and number of services,
+playlists, and apps it contains are shown. Local registries are marked with (local).
+
+Examples:
+
+List all registries:
+
+	tb registry list`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Read(c.ConfigOptions)
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to load tbrc",
+					Err: err,
+				}
+			}
+			infos, err := config.ListRegistries(c.Ctx, cfg, config.RegistryOptions{})
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to list registries",
+					Err: err,
+				}
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "REGISTRY\tPATH\tCOMMIT\tUPDATED\tSERVICES\tPLAYLISTS\tAPPS")
+			for _, info := range infos {
+				name := info.Name
+				if info.Local {
+					name += " (local)"
+				}
+				if info.Err != nil {
+					fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t-\t-\n", name, info.Path)
+					continue
+				}
+				commit, updated := "-", "-"
+				if info.Commit.SHA != "" {
+					commit = shortSHA(info.Commit.SHA)
+					updated = info.Commit.Time.Local().Format("2006-01-02 15:04")
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\n", name, info.Path, commit, updated, info.Services, info.Playlists, info.Apps)
+			}
+			if err := tw.Flush(); err != nil {
+				return &fatal.Error{
+					Msg: "Failed to print registries",
+					Err: err,
+				}
+			}
+			// Report errors after the table so they don't mess up the formatting.
+			for _, info := range infos {
+				if info.Err != nil {
+					c.Tracker.Warnf("Unable to read registry %s: %v", info.Name, info.Err)
+				}
+			}
+			return nil
+		},
+	}
+}
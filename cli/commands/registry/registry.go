@@ -14,6 +14,12 @@ func NewRegistryCommand(c *cli.Container) *cobra.Command {
 A registry contains configuration to define services, playlists, and apps that tb can run.
 See https://github.com/TouchBistro/tb/blob/master/docs/registries.md for more details.`,
 	}
-	registryCmd.AddCommand(newAddCommand(c), newValidateCommand(c))
+	registryCmd.AddCommand(
+		newAddCommand(c),
+		newListCommand(c),
+		newRemoveCommand(c),
+		newUpdateCommand(c),
+		newValidateCommand(c),
+	)
 	return registryCmd
 }
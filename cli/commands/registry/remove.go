@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TouchBistro/goutils/color"
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+)
+
+type removeOptions struct {
+	removeClone bool
+}
+
+func newRemoveCommand(c *cli.Container) *cobra.Command {
+	var opts removeOptions
+	removeCmd := &cobra.Command{
+		Use:     "remove <registry-name>",
+		Aliases: []string{"rm"},
+		Args:    cli.ExpectSingleArg("registry name"),
+		Short:   "Remove a registry",
+		Long: `Removes a registry from tb. Comments in .tbrc.yml are preserved.
+
+By default the local clone of the registry in ~/.tb/registries is kept.
+Use the --clone flag to remove it as well.
+
+Examples:
+
+Remove the registry named TouchBistro/tb-registry-example:
+
+	tb registry remove TouchBistro/tb-registry-example
+
+Remove the registry and its local clone:
+
+	tb registry remove TouchBistro/tb-registry-example --clone`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registryName := args[0]
+			err := config.RemoveRegistry(registryName, config.RemoveRegistryOptions{RemoveClone: opts.removeClone})
+			if errors.Is(err, config.ErrRegistryNotFound) {
+				return &fatal.Error{Msg: fmt.Sprintf("registry %s has not been added", registryName)}
+			} else if err != nil {
+				return &fatal.Error{
+					Msg: fmt.Sprintf("failed to remove registry %s", registryName),
+					Err: err,
+				}
+			}
+			c.Tracker.Infof(color.Green("Successfully removed registry %s"), registryName)
+			return nil
+		},
+	}
+
+	flags := removeCmd.Flags()
+	flags.BoolVar(&opts.removeClone, "clone", false, "Also remove the local clone of the registry")
+	return removeCmd
+}
@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"path"
+
+	"github.com/TouchBistro/tb/registry"
+)
+
+// SARIF 2.1.0 types, only the subset of the spec used by tb is defined.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// newSARIFLog creates a SARIF log from findings. baseDir is prepended to the file of each finding
+// so that locations are relative to where tb was run, which is usually the root of the registry repo.
+func newSARIFLog(findings []registry.Finding, baseDir, version string) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "tb",
+			Version:        version,
+			InformationURI: "https://github.com/TouchBistro/tb",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	seenRules := make(map[string]bool)
+	for _, f := range findings {
+		if !seenRules[f.Rule] {
+			seenRules[f.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               f.Rule,
+				ShortDescription: sarifMessage{Text: registry.RuleDescription(f.Rule)},
+			})
+		}
+		loc := sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: path.Join(baseDir, f.File)},
+		}
+		if f.Line > 0 {
+			loc.Region = &sarifRegion{StartLine: f.Line, StartColumn: f.Column}
+		}
+		msg := f.Message
+		if f.Resource != "" {
+			msg = f.Resource + ": " + msg
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    f.Rule,
+			Level:     string(f.Severity),
+			Message:   sarifMessage{Text: msg},
+			Locations: []sarifLocation{{PhysicalLocation: loc}},
+		})
+	}
+	return sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}
+}
@@ -0,0 +1,71 @@
+package registry
+
+import (
+	"github.com/TouchBistro/goutils/color"
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/spf13/cobra"
+)
+
+func newUpdateCommand(c *cli.Container) *cobra.Command {
+	return &cobra.Command{
+		Use:   "update [registry-name]",
+		Args:  cobra.MaximumNArgs(1),
+		Short: "Update the commit registries are locked to",
+		Long: `Updates registries to the latest commit of their ref and records it in the lock file.
+
+Registries are locked to the commit recorded in ~/.tb.lock so that they only change when
+explicitly updated. If a registry has a ref configured in .tbrc.yml, it will be updated to
+the latest commit of that ref, otherwise the latest commit of the default branch is used.
+
+If no registry name is provided, all registries will be updated.
+
+Examples:
+
+Update all registries:
+
+	tb registry update
+
+Update only the registry named TouchBistro/tb-registry:
+
+	tb registry update TouchBistro/tb-registry`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Read(c.ConfigOptions)
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to load tbrc",
+					Err: err,
+				}
+			}
+			updates, err := config.UpdateRegistries(c.Ctx, cfg, args, config.RegistryOptions{})
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to update registries",
+					Err: err,
+				}
+			}
+			if len(updates) == 0 {
+				c.Tracker.Info(color.Green("☑ registries are already up to date"))
+				return nil
+			}
+			for _, u := range updates {
+				if u.OldCommit == "" {
+					c.Tracker.Infof("%s locked to %s", color.Cyan(u.Name), shortSHA(u.NewCommit))
+					continue
+				}
+				c.Tracker.Infof("%s updated from %s to %s", color.Cyan(u.Name), shortSHA(u.OldCommit), shortSHA(u.NewCommit))
+			}
+			c.Tracker.Info(color.Green("✔ Updated registries"))
+			return nil
+		},
+	}
+}
+
+// shortSHA returns the abbreviated form of a commit SHA.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
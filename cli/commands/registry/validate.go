@@ -1,8 +1,12 @@
 package registry
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/fs"
+	"os"
+	"path/filepath"
 
 	"github.com/TouchBistro/goutils/color"
 	"github.com/TouchBistro/goutils/fatal"
@@ -13,6 +17,24 @@ import (
 
 type validateOptions struct {
 	strict bool
+	format string
+}
+
+// findingOutput is the JSON representation of a validation finding.
+type findingOutput struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Resource string `json:"resource,omitempty"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// validateOutput is the JSON representation of the result of validating a registry.
+type validateOutput struct {
+	Valid    bool            `json:"valid"`
+	Findings []findingOutput `json:"findings"`
 }
 
 func newValidateCommand(c *cli.Container) *cobra.Command {
@@ -23,20 +45,82 @@ func newValidateCommand(c *cli.Container) *cobra.Command {
 		Short: "Validate the config files in a registry",
 		Long: `Validates the config files in a registry at the given path.
 
+In addition to checking each service, playlist, and app on its own, references between them
+are checked. This includes service dependencies, playlist services and extends, named volumes
+shared by services, and the envFile and build.dockerfilePath of services. Paths in a service's repo
+are only checked if the repo has been cloned by tb. Each problem found by these checks includes
+a rule ID, ex: [unknown-dependency].
+
+The --strict flag enables additional checks. Unknown variables are errors and each unknown key
+in a config file is reported with its file and line, along with the closest valid field name.
+
+The --format flag can be used to output the problems found in a machine readable format.
+Each problem includes the file, line, and column it was found at, the resource it belongs to,
+the rule ID, and the severity. Supported formats are text (the default), json, and sarif.
+SARIF output can be uploaded to GitHub code scanning to annotate problems in pull requests.
+
 Examples:
 
 Validate the config files in the current directory:
 
-	tb registry validate .`,
+	tb registry validate .
+
+Validate the config files in the current directory and output SARIF:
+
+	tb registry validate . --format sarif > tb.sarif`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.format != "text" && opts.format != "json" && opts.format != "sarif" {
+				return &fatal.Error{Msg: fmt.Sprintf("invalid format %q, must be 'text', 'json', or 'sarif'", opts.format)}
+			}
 			registryPath := args[0]
 			c.Tracker.Infof(color.Cyan("Validating registry files at path %q"), registryPath)
 
+			// Paths within service repos can be checked if the repos have been cloned by tb.
+			var reposPath string
+			if homedir, err := os.UserHomeDir(); err == nil {
+				reposPath = filepath.Join(homedir, ".tb", "repos")
+			}
+
 			valid := true
 			result := registry.Validate(registryPath, registry.ValidateOptions{
-				Strict: opts.strict,
-				Logger: c.Tracker,
+				Strict:    opts.strict,
+				ReposPath: reposPath,
+				Logger:    c.Tracker,
 			})
+			if opts.format != "text" {
+				valid := result.AppsErr == nil || errors.Is(result.AppsErr, fs.ErrNotExist)
+				valid = valid && (result.PlaylistsErr == nil || errors.Is(result.PlaylistsErr, fs.ErrNotExist))
+				valid = valid && (result.ServicesErr == nil || errors.Is(result.ServicesErr, fs.ErrNotExist))
+				var out interface{}
+				if opts.format == "sarif" {
+					out = newSARIFLog(result.Findings, filepath.ToSlash(registryPath), cmd.Root().Version)
+				} else {
+					vo := validateOutput{Valid: valid, Findings: make([]findingOutput, len(result.Findings))}
+					for i, f := range result.Findings {
+						vo.Findings[i] = findingOutput{
+							File:     filepath.ToSlash(filepath.Join(registryPath, f.File)),
+							Line:     f.Line,
+							Column:   f.Column,
+							Resource: f.Resource,
+							Rule:     f.Rule,
+							Severity: string(f.Severity),
+							Message:  f.Message,
+						}
+					}
+					out = vo
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				enc.SetEscapeHTML(false)
+				if err := enc.Encode(out); err != nil {
+					return &fatal.Error{Msg: "Failed to write validation result", Err: err}
+				}
+				if !valid {
+					return &fatal.Error{Msg: color.Red("❌ registry is invalid")}
+				}
+				return nil
+			}
+
 			if errors.Is(result.AppsErr, fs.ErrNotExist) {
 				c.Tracker.Infof(color.Yellow("No %s file"), registry.AppsFileName)
 			} else if result.AppsErr == nil {
@@ -76,5 +160,6 @@ Validate the config files in the current directory:
 
 	flags := validateCmd.Flags()
 	flags.BoolVar(&opts.strict, "strict", false, "Strict mode, treat more cases as errors")
+	flags.StringVar(&opts.format, "format", "text", "Output format, one of: text, json, sarif")
 	return validateCmd
 }
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/engine"
+	"github.com/spf13/cobra"
+)
+
+type restartOptions struct {
+	playlistName string
+}
+
+func newRestartCommand(c *cli.Container) *cobra.Command {
+	var opts restartOptions
+	restartCmd := &cobra.Command{
+		Use: "restart [services...]",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && opts.playlistName != "" {
+				return fmt.Errorf("cannot specify service names as args when --playlist or -p is used")
+			}
+			return nil
+		},
+		Short: "Restart containers",
+		Long: `Restarts service containers without recreating them, so any changes to the container's filesystem are kept.
+Unlike tb up, no containers are created, so each service must already have a container.
+By default all service containers are restarted.
+Service names can be provided as args, or the --playlist,-p flag can be used to provide a playlist name.
+
+Examples:
+
+Restart all service containers:
+
+	tb restart
+
+Restart the postgres and redis containers:
+
+	tb restart postgres redis`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := c.Engine.Restart(c.Ctx, engine.RestartOptions{
+				ServiceNames: args,
+				PlaylistName: opts.playlistName,
+			})
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to restart services",
+					Err: err,
+				}
+			}
+			c.Tracker.Info("✔ Restarted services")
+			return nil
+		},
+	}
+
+	flags := restartCmd.Flags()
+	flags.StringVarP(&opts.playlistName, "playlist", "p", "", "The name of a playlist")
+	return restartCmd
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/TouchBistro/goutils/spinner"
 	"github.com/TouchBistro/tb/cli"
 	appCommands "github.com/TouchBistro/tb/cli/commands/app"
+	configCommands "github.com/TouchBistro/tb/cli/commands/config"
 	registryCommands "github.com/TouchBistro/tb/cli/commands/registry"
 	"github.com/TouchBistro/tb/config"
 	"github.com/TouchBistro/tb/integrations/github"
@@ -25,6 +26,9 @@ import (
 type rootOptions struct {
 	noRegistryPull bool
 	verbose        bool
+	workspace      string
+	settings       []string
+	profile        string
 }
 
 func NewRootCommand(c *cli.Container, version string) *cobra.Command {
@@ -54,8 +58,9 @@ func NewRootCommand(c *cli.Container, version string) *cobra.Command {
 			}
 			fmt.Fprintln(os.Stderr, color.Magenta(fortune.Random().Pretty(termWidth)))
 
-			// Get the user config, pass empty string to have it find the config file
-			cfg, err := config.Read("")
+			// Get the user config, leave the paths empty to have it find the config files
+			c.ConfigOptions = config.ReadOptions{Settings: opts.settings, Profile: opts.profile}
+			cfg, err := config.Read(c.ConfigOptions)
 			if err != nil {
 				return &fatal.Error{
 					Msg: "Failed to load tbrc",
@@ -74,6 +79,15 @@ func NewRootCommand(c *cli.Container, version string) *cobra.Command {
 				PersistMessages: c.Verbose,
 			}
 
+			// Project tbrc files control what tb runs so always make it clear which ones are used.
+			// The first file is always the home tbrc.
+			for _, path := range cfg.Files[1:] {
+				c.Tracker.Infof(color.Cyan("Using project tbrc %s"), path)
+			}
+			for _, path := range cfg.UntrustedFiles {
+				c.Tracker.Warnf("Ignoring untrusted project tbrc %s, review it and run 'tb config trust %s' to use it", path, path)
+			}
+
 			// Any special messages based on user config
 			if cfg.Debug != nil {
 				// This prints a warning sign
@@ -85,11 +99,36 @@ func NewRootCommand(c *cli.Container, version string) *cobra.Command {
 			}
 			checkVersion(cmd.Context(), version, c.Tracker)
 
+			// Let the user know if the tbrc uses an older version of the format. The config that was read
+			// has already been migrated so it can still be used, the file is only changed by 'tb config migrate'.
+			if cmd.Name() != "migrate" {
+				result, err := config.Migrate(config.MigrateOptions{Check: true})
+				if err != nil {
+					c.Tracker.Warnf("Failed to check if tbrc needs to be migrated: %v", err)
+				} else if result.Needed() {
+					c.Tracker.Warnf("%s uses version %d of the format, run 'tb config migrate' to migrate it to version %d", result.Path, result.FromVersion, result.ToVersion)
+				}
+			}
+
+			// Create the context that commands can use.
+			// Generally it is recommended not to store contexts in structs, however this case is special
+			// since only one command runs on the each invocation of tb and the container can be seen
+			// as special parameters to the command. Also cobra does with cmd.Context().
+			c.Ctx = progress.ContextWithTracker(cmd.Context(), c.Tracker)
+
+			// The schema command only describes config files so registries aren't needed.
+			if cmd.Name() == "schema" {
+				return nil
+			}
+
 			// Determine how to proceed based on the type of command
-			initOpts := config.InitOptions{UpdateRegistries: !opts.noRegistryPull}
+			initOpts := config.InitOptions{Workspace: opts.workspace, NoRegistryPull: opts.noRegistryPull}
 			switch cmd.Parent().Name() {
-			case "registry":
-				// No further action required for registry commands
+			case "registry", "config":
+				// No further action required for registry and config commands
+				if opts.noRegistryPull {
+					c.Tracker.Warnf("--no-registry-pull has no effect on 'tb %s' commands", cmd.Parent().Name())
+				}
 				return nil
 			case "ios":
 				if !util.IsMacOS {
@@ -102,11 +141,6 @@ func NewRootCommand(c *cli.Container, version string) *cobra.Command {
 				initOpts.LoadServices = true
 			}
 
-			// Create the context that commands can use.
-			// Generally it is recommended not to store contexts in structs, however this case is special
-			// since only one command runs on the each invocation of tb and the container can be seen
-			// as special parameters to the command. Also cobra does with cmd.Context().
-			c.Ctx = progress.ContextWithTracker(cmd.Context(), c.Tracker)
 			c.Engine, err = config.Init(c.Ctx, cfg, initOpts)
 			if err != nil {
 				return &fatal.Error{
@@ -119,10 +153,14 @@ func NewRootCommand(c *cli.Container, version string) *cobra.Command {
 	}
 
 	persistentFlags := rootCmd.PersistentFlags()
-	persistentFlags.BoolVar(&opts.noRegistryPull, "no-registry-pull", false, "Don't pull latest version of registries when tb is run")
+	persistentFlags.BoolVar(&opts.noRegistryPull, "no-registry-pull", false, "Don't fetch registries when tb is run, use the commits that were last fetched")
 	persistentFlags.BoolVarP(&opts.verbose, "verbose", "v", false, "Enable verbose logging")
+	persistentFlags.StringVar(&opts.workspace, "workspace", "", "Name of the workspace to use, defaults to the main workspace")
+	persistentFlags.StringVar(&opts.profile, "profile", "", "Name of the tbrc profile to use, overrides the profile setting and TB_PROFILE")
+	persistentFlags.StringArrayVar(&opts.settings, "set", nil, "Override a tbrc setting for this run, ex: --set experimental=true, can be repeated")
 	rootCmd.AddCommand(
 		appCommands.NewAppCommand(c),
+		configCommands.NewConfigCommand(c),
 		registryCommands.NewRegistryCommand(c),
 		newCloneCommand(c),
 		newDBCommand(c),
@@ -132,7 +170,13 @@ func NewRootCommand(c *cli.Container, version string) *cobra.Command {
 		newListCommand(c),
 		newLogsCommand(c),
 		newNukeCommand(c),
+		newRestartCommand(c),
+		newSchemaCommand(c),
+		newStartCommand(c),
+		newStatusCommand(c),
+		newStopCommand(c),
 		newUpCommand(c),
+		newWatchCommand(c),
 	)
 	return rootCmd
 }
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/config"
+	"github.com/TouchBistro/tb/internal/jsonschema"
+	"github.com/TouchBistro/tb/registry"
+	"github.com/spf13/cobra"
+)
+
+// schemas maps each config file that has a schema to a function that creates it.
+var schemas = map[string]func() *jsonschema.Schema{
+	"services":  registry.ServicesSchema,
+	"playlists": registry.PlaylistsSchema,
+	"apps":      registry.AppsSchema,
+	"tbrc":      config.Schema,
+}
+
+func newSchemaCommand(c *cli.Container) *cobra.Command {
+	return &cobra.Command{
+		Use:       "schema <services|playlists|apps|tbrc>",
+		Args:      cli.ExpectSingleArg("config file"),
+		ValidArgs: []string{"services", "playlists", "apps", "tbrc"},
+		Short:     "Output the JSON Schema of a config file",
+		Long: `Outputs the JSON Schema of a config file. The schema can be used by editors to provide
+autocompletion and validation.
+
+The following config files are supported:
+- services: services.yml and the files in services.d in a registry
+- playlists: playlists.yml and the files in playlists.d in a registry
+- apps: apps.yml and the files in apps.d in a registry
+- tbrc: the .tbrc.yml config file
+
+Examples:
+
+Save the schema of services.yml to a file:
+
+	tb schema services > services.schema.json
+
+Then use it with the YAML language server by adding the following comment to the top of services.yml:
+
+	# yaml-language-server: $schema=./services.schema.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			newSchema, ok := schemas[args[0]]
+			if !ok {
+				return &fatal.Error{Msg: fmt.Sprintf("invalid config file %q, must be one of: services, playlists, apps, tbrc", args[0])}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(newSchema()); err != nil {
+				return &fatal.Error{
+					Msg: "Failed to write schema",
+					Err: err,
+				}
+			}
+			return nil
+		},
+	}
+}
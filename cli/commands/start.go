@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/engine"
+	"github.com/spf13/cobra"
+)
+
+type startOptions struct {
+	playlistName string
+}
+
+func newStartCommand(c *cli.Container) *cobra.Command {
+	var opts startOptions
+	startCmd := &cobra.Command{
+		Use: "start [services...]",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && opts.playlistName != "" {
+				return fmt.Errorf("cannot specify service names as args when --playlist or -p is used")
+			}
+			return nil
+		},
+		Short: "Start stopped containers",
+		Long: `Starts service containers that were previously stopped with tb stop.
+Unlike tb up, no containers are created, so each service must already have a container.
+By default all stopped service containers are started.
+Service names can be provided as args, or the --playlist,-p flag can be used to provide a playlist name.
+
+Examples:
+
+Start all stopped service containers:
+
+	tb start
+
+Start the postgres and redis containers:
+
+	tb start postgres redis`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := c.Engine.Start(c.Ctx, engine.StartOptions{
+				ServiceNames: args,
+				PlaylistName: opts.playlistName,
+			})
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to start services",
+					Err: err,
+				}
+			}
+			c.Tracker.Info("✔ Started services")
+			return nil
+		},
+	}
+
+	flags := startCmd.Flags()
+	flags.StringVarP(&opts.playlistName, "playlist", "p", "", "The name of a playlist")
+	return startCmd
+}
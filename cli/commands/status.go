@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/engine"
+	"github.com/TouchBistro/tb/integrations/docker"
+	"github.com/TouchBistro/tb/resource/service"
+	"github.com/spf13/cobra"
+)
+
+type statusOptions struct {
+	all    bool
+	format string
+}
+
+// statusOutput is the JSON representation of a service status.
+type statusOutput struct {
+	Name          string     `json:"name"`
+	Mode          string     `json:"mode"`
+	Image         string     `json:"image,omitempty"`
+	State         string     `json:"state"`
+	Health        string     `json:"health,omitempty"`
+	StartedAt     *time.Time `json:"startedAt,omitempty"`
+	UptimeSeconds int64      `json:"uptimeSeconds,omitempty"`
+	Ports         []string   `json:"ports"`
+	RestartCount  int        `json:"restartCount"`
+}
+
+func newStatusCommand(c *cli.Container) *cobra.Command {
+	var opts statusOptions
+	statusCmd := &cobra.Command{
+		Use:   "status [services...]",
+		Args:  cobra.ArbitraryArgs,
+		Short: "Show the status of service containers",
+		Long: `Shows the live status of service containers managed by tb.
+For each service the container state, health, uptime, image, published ports and restart count are shown.
+
+By default only services that have a container are shown. Service names can be provided as args
+to only show those services, or --all can be used to show every service.
+
+Examples:
+
+Show the status of all service containers:
+
+	tb status
+
+Show the status of the postgres and redis services as JSON:
+
+	tb status postgres redis --format json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.format != "table" && opts.format != "json" {
+				return &fatal.Error{Msg: fmt.Sprintf("invalid format %q, must be 'table' or 'json'", opts.format)}
+			}
+			statuses, err := c.Engine.Status(c.Ctx, engine.StatusOptions{
+				ServiceNames: args,
+				All:          opts.all,
+			})
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to get status of services",
+					Err: err,
+				}
+			}
+
+			now := time.Now()
+			if opts.format == "json" {
+				out := make([]statusOutput, len(statuses))
+				for i, st := range statuses {
+					so := statusOutput{
+						Name:         st.Name,
+						Mode:         st.Mode,
+						Image:        st.Image,
+						State:        statusState(st),
+						Health:       st.Health,
+						Ports:        st.Ports,
+						RestartCount: st.RestartCount,
+					}
+					if so.Ports == nil {
+						so.Ports = []string{}
+					}
+					if !st.StartedAt.IsZero() {
+						startedAt := st.StartedAt
+						so.StartedAt = &startedAt
+						if strings.EqualFold(st.State, docker.ContainerStateRunning) {
+							so.UptimeSeconds = int64(now.Sub(startedAt).Seconds())
+						}
+					}
+					out[i] = so
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(out); err != nil {
+					return &fatal.Error{
+						Msg: "Failed to write status as JSON",
+						Err: err,
+					}
+				}
+				return nil
+			}
+
+			if len(statuses) == 0 {
+				c.Tracker.Info("No service containers found. Use --all to show all services.")
+				return nil
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "SERVICE\tSTATE\tHEALTH\tUPTIME\tIMAGE\tPORTS\tRESTARTS")
+			for _, st := range statuses {
+				health := st.Health
+				if health == "" || health == docker.HealthNone {
+					health = "-"
+				}
+				uptime := "-"
+				if strings.EqualFold(st.State, docker.ContainerStateRunning) && !st.StartedAt.IsZero() {
+					uptime = formatUptime(now.Sub(st.StartedAt))
+				}
+				image := st.Image
+				if st.Mode == service.ModeBuild {
+					image = "(build)"
+				}
+				ports := strings.Join(st.Ports, ", ")
+				if ports == "" {
+					ports = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", st.Name, statusState(st), health, uptime, image, ports, st.RestartCount)
+			}
+			if err := tw.Flush(); err != nil {
+				return &fatal.Error{
+					Msg: "Failed to write status",
+					Err: err,
+				}
+			}
+			return nil
+		},
+	}
+
+	flags := statusCmd.Flags()
+	flags.BoolVarP(&opts.all, "all", "a", false, "Show all services, including those without a container")
+	flags.StringVar(&opts.format, "format", "table", "Output format, one of: table, json")
+	return statusCmd
+}
+
+// statusState returns the state to display for a service.
+func statusState(st engine.ServiceStatus) string {
+	if !st.HasContainer {
+		return "not created"
+	}
+	return st.State
+}
+
+// formatUptime formats d in a compact human readable way, ex: 3h12m.
+func formatUptime(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return d.String()
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/engine"
+	"github.com/spf13/cobra"
+)
+
+type stopOptions struct {
+	playlistName string
+}
+
+func newStopCommand(c *cli.Container) *cobra.Command {
+	var opts stopOptions
+	stopCmd := &cobra.Command{
+		Use: "stop [services...]",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && opts.playlistName != "" {
+				return fmt.Errorf("cannot specify service names as args when --playlist or -p is used")
+			}
+			return nil
+		},
+		Short: "Stop containers without removing them",
+		Long: `Stops running service containers without removing them. The containers can be started again with tb start.
+By default all running service containers are stopped.
+Service names can be provided as args, or the --playlist,-p flag can be used to provide a playlist name.
+
+Examples:
+
+Stop all service containers:
+
+	tb stop
+
+Stop the postgres and redis containers:
+
+	tb stop postgres redis`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := c.Engine.Stop(c.Ctx, engine.StopOptions{
+				ServiceNames: args,
+				PlaylistName: opts.playlistName,
+			})
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to stop services",
+					Err: err,
+				}
+			}
+			c.Tracker.Info("✔ Stopped services")
+			return nil
+		},
+	}
+
+	flags := stopCmd.Flags()
+	flags.StringVarP(&opts.playlistName, "playlist", "p", "", "The name of a playlist")
+	return stopCmd
+}
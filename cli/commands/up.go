@@ -2,7 +2,10 @@ package commands
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"time"
 
 	"github.com/TouchBistro/goutils/command"
 	"github.com/TouchBistro/goutils/fatal"
@@ -16,6 +19,11 @@ type upOptions struct {
 	skipGitPull       bool
 	skipDockerPull    bool
 	skipLazydocker    bool
+	skipDependencies  bool
+	dryRun            bool
+	watch             bool
+	watchIgnore       []string
+	readyTimeout      time.Duration
 	playlistName      string
 	serviceNames      []string
 }
@@ -41,12 +49,25 @@ func newUpCommand(c *cli.Container) *cobra.Command {
 			return nil
 		},
 		Short: "Start services or playlists",
-		Long: `Starts one or more services. The following actions will be performed before starting services:
+		Long: `Starts one or more services. Any dependencies of the services are also started, unless --no-deps is used.
+The following actions will be performed before starting services:
 
-- Stop and remove any services that are already running.
 - Pull base images and service images.
 - Build any services with mode build.
-- Run pre-run steps for services.
+- Stop and remove any services that are already running and have changed.
+- Run pre-run steps for services that have changed.
+
+Services that are already running and have not changed since they were last started by tb up,
+and whose dependencies have not changed, are left running and their pre-run steps are skipped.
+
+Once services are started, tb up waits for each one to be ready. Services that define a healthcheck
+must report healthy, other services only need to be running. The --ready-timeout flag controls how long
+to wait for each service.
+
+The --watch flag can be used to rebuild and restart services in build mode whenever their source changes.
+In this case lazydocker is not started. See tb watch for more details.
+
+The --dry-run flag can be used to see what tb up would do without actually doing it.
 
 Services can be specified in one of two ways. First, the names of the services can be specified directly as args.
 Second, the --playlist,-p flag can be used to provide a playlist name in order to start all the services in the playlist.
@@ -69,12 +90,15 @@ Run the postgres and localstack services directly:
 			if len(serviceNames) == 0 {
 				serviceNames = opts.serviceNames
 			}
-			err := c.Engine.Up(c.Ctx, engine.UpOptions{
-				ServiceNames:   serviceNames,
-				PlaylistName:   opts.playlistName,
-				SkipPreRun:     opts.skipServicePreRun,
-				SkipDockerPull: opts.skipDockerPull,
-				SkipGitPull:    opts.skipGitPull,
+			plan, err := c.Engine.Up(c.Ctx, engine.UpOptions{
+				ServiceNames:     serviceNames,
+				PlaylistName:     opts.playlistName,
+				SkipDependencies: opts.skipDependencies,
+				SkipPreRun:       opts.skipServicePreRun,
+				SkipDockerPull:   opts.skipDockerPull,
+				SkipGitPull:      opts.skipGitPull,
+				ReadyTimeout:     opts.readyTimeout,
+				DryRun:           opts.dryRun,
 			})
 			if err != nil {
 				return &fatal.Error{
@@ -82,8 +106,30 @@ Run the postgres and localstack services directly:
 					Err: err,
 				}
 			}
+			if opts.dryRun {
+				printUpPlan(os.Stdout, plan)
+				return nil
+			}
 			c.Tracker.Info("✔ Started services")
 
+			if opts.watch {
+				if len(plan.Builds) == 0 {
+					c.Tracker.Warn("No services in build mode were started, there is nothing to watch")
+					return nil
+				}
+				err := c.Engine.Watch(c.Ctx, engine.WatchOptions{
+					ServiceNames: plan.Builds,
+					Ignore:       opts.watchIgnore,
+				})
+				if err != nil {
+					return &fatal.Error{
+						Msg: "Failed to watch services",
+						Err: err,
+					}
+				}
+				return nil
+			}
+
 			if !opts.skipLazydocker {
 				// lazydocker opt in, if it exists it will be launched, otherwise this step will be skipped
 				const lazydocker = "lazydocker"
@@ -111,6 +157,11 @@ Run the postgres and localstack services directly:
 	flags.BoolVar(&opts.skipGitPull, "no-git-pull", false, "Don't update git repositories")
 	flags.BoolVar(&opts.skipDockerPull, "no-remote-pull", false, "Don't get new remote images")
 	flags.BoolVar(&opts.skipLazydocker, "no-lazydocker", false, "Don't start lazydocker")
+	flags.BoolVar(&opts.skipDependencies, "no-deps", false, "Don't pull, build, or run pre-run steps for dependencies of services")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "Show the actions that would be performed without performing them")
+	flags.BoolVar(&opts.watch, "watch", false, "Rebuild and restart services in build mode when their source changes")
+	flags.StringArrayVar(&opts.watchIgnore, "watch-ignore", nil, "Glob pattern of files to ignore when using --watch, can be specified multiple times")
+	flags.DurationVar(&opts.readyTimeout, "ready-timeout", engine.DefaultReadyTimeout, "How long to wait for each service to become ready")
 	flags.StringVarP(&opts.playlistName, "playlist", "p", "", "The name of a playlist")
 	flags.StringSliceVarP(&opts.serviceNames, "services", "s", []string{}, "Comma separated list of services to start. eg --services postgres,localstack.")
 	err := flags.MarkDeprecated("services", "and will be removed, pass service names as arguments instead")
@@ -121,3 +172,56 @@ Run the postgres and localstack services directly:
 	}
 	return upCmd
 }
+
+// printUpPlan writes a human readable version of plan to w.
+func printUpPlan(w io.Writer, plan engine.UpPlan) {
+	printSection := func(title string, items []string) {
+		fmt.Fprintf(w, "%s:\n", title)
+		if len(items) == 0 {
+			fmt.Fprintln(w, "  (none)")
+		}
+		for _, item := range items {
+			fmt.Fprintf(w, "  - %s\n", item)
+		}
+		fmt.Fprintln(w)
+	}
+	imageItems := func(actions []engine.ImageAction) []string {
+		var items []string
+		for _, a := range actions {
+			status := "missing"
+			if a.Present {
+				status = "present"
+			}
+			action := "skip"
+			if a.Pull {
+				action = "pull"
+			}
+			items = append(items, fmt.Sprintf("%s %s (%s)", action, a.Image, status))
+		}
+		return items
+	}
+
+	printSection("Services to start", plan.Services)
+	printSection("Logins", plan.Logins)
+	var repoItems []string
+	for _, a := range plan.GitRepos {
+		action := "pull"
+		if a.Clone {
+			action = "clone"
+		}
+		repoItems = append(repoItems, fmt.Sprintf("%s %s", action, a.Repo))
+	}
+	printSection("Git repos", repoItems)
+	printSection("Containers to stop and remove", plan.StopContainers)
+	printSection("Running and unchanged services", plan.Unchanged)
+	printSection("Base images", imageItems(plan.BaseImages))
+	printSection("Service images", imageItems(plan.ServiceImages))
+	printSection("Services to build", plan.Builds)
+	var preRunItems []string
+	for i, wave := range plan.PreRuns {
+		for _, a := range wave {
+			preRunItems = append(preRunItems, fmt.Sprintf("[%d] %s: %s", i+1, a.Service, a.Command))
+		}
+	}
+	printSection("Pre-run steps (grouped by wave)", preRunItems)
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/TouchBistro/goutils/fatal"
+	"github.com/TouchBistro/tb/cli"
+	"github.com/TouchBistro/tb/engine"
+	"github.com/spf13/cobra"
+)
+
+type watchOptions struct {
+	ignore   []string
+	debounce time.Duration
+}
+
+func newWatchCommand(c *cli.Container) *cobra.Command {
+	var opts watchOptions
+	watchCmd := &cobra.Command{
+		Use:   "watch <services...>",
+		Args:  cobra.MinimumNArgs(1),
+		Short: "Rebuild and restart services when their source changes",
+		Long: `Watches the build context of one or more services for changes. When a file changes, the service's
+docker image is rebuilt and its container is recreated. Only services in build mode can be watched.
+
+The --ignore flag can be used to ignore files that should not cause a rebuild. Patterns are matched against
+both the path relative to the build context and the name of each file, so a pattern like node_modules ignores
+every node_modules directory. .git directories are always ignored.
+
+tb watch runs until it is stopped with control-C.
+
+Examples:
+
+Watch the venue-core-service service:
+
+	tb watch venue-core-service
+
+Watch the venue-core-service service, ignoring logs and node_modules:
+
+	tb watch venue-core-service --ignore node_modules --ignore '*.log'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := c.Engine.Watch(c.Ctx, engine.WatchOptions{
+				ServiceNames: args,
+				Ignore:       opts.ignore,
+				Debounce:     opts.debounce,
+			})
+			if err != nil {
+				return &fatal.Error{
+					Msg: "Failed to watch services",
+					Err: err,
+				}
+			}
+			return nil
+		},
+	}
+
+	flags := watchCmd.Flags()
+	flags.StringArrayVar(&opts.ignore, "ignore", nil, "Glob pattern of files to ignore, can be specified multiple times")
+	flags.DurationVar(&opts.debounce, "debounce", time.Second, "How long to wait for changes to stop before rebuilding")
+	return watchCmd
+}
@@ -1,14 +1,13 @@
 package config
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
-	"github.com/TouchBistro/goutils/color"
 	"github.com/TouchBistro/goutils/errors"
 	"github.com/TouchBistro/goutils/file"
 	"github.com/TouchBistro/goutils/progress"
@@ -16,6 +15,7 @@ import (
 	"github.com/TouchBistro/tb/errkind"
 	"github.com/TouchBistro/tb/integrations/git"
 	"github.com/TouchBistro/tb/integrations/simulator"
+	"github.com/TouchBistro/tb/internal/jsonschema"
 	"github.com/TouchBistro/tb/internal/util"
 	"github.com/TouchBistro/tb/registry"
 	"github.com/TouchBistro/tb/resource"
@@ -27,6 +27,9 @@ import (
 // ErrRegistryExists indicates that the registry being added already exists.
 var ErrRegistryExists errors.String = "registry already exists"
 
+// ErrRegistryNotFound indicates that the registry being removed does not exist.
+var ErrRegistryNotFound errors.String = "registry not found"
+
 const (
 	tbrcName      = ".tbrc.yml"
 	rootDir       = ".tb"
@@ -40,11 +43,51 @@ var tbrcTemplate []byte
 type Config struct {
 	// Triple state bools suck but we need this so we can tell if the user set it explicitly.
 	// TODO(@cszatmary): Remove this when we do a breaking change.
-	Debug            *bool                              `yaml:"debug"`
-	ExperimentalMode bool                               `yaml:"experimental"`
-	Playlists        map[string]playlist.Playlist       `yaml:"playlists"`
-	Overrides        map[string]service.ServiceOverride `yaml:"overrides"`
-	Registries       []registry.Registry                `yaml:"registries"`
+	Debug            *bool                              `yaml:"debug" desc:"Deprecated: use the --verbose flag instead."`
+	ExperimentalMode bool                               `yaml:"experimental" desc:"Enable experimental features."`
+	Playlists        map[string]playlist.Playlist       `yaml:"playlists" desc:"Custom playlists, they take priority over playlists in registries."`
+	Overrides        map[string]service.ServiceOverride `yaml:"overrides" desc:"Overrides to apply to services, keyed by the full name of the service."`
+	Profile          string                             `yaml:"profile" desc:"Name of the profile to use, can be overridden with the --profile flag or TB_PROFILE."`
+	Profiles         map[string]Profile                 `yaml:"profiles" desc:"Named sets of overrides, playlists, and registries that are applied on top of the rest of the config when the profile is used."`
+	Registries       []registry.Registry                `yaml:"registries" desc:"Registries to get services, playlists, and apps from."`
+	Strict           bool                               `yaml:"strict" desc:"Treat unknown keys in this file and in registry files as errors."`
+	Variables        map[string]string                  `yaml:"variables" desc:"Variables to use for variable expansion in registries, they take priority over variables defined by registries."`
+	Version          int                                `yaml:"version" desc:"Version of the format of this file, used to migrate it when the format changes."`
+	Workspaces       map[string]Workspace               `yaml:"workspaces" desc:"Workspaces for running isolated copies of services."`
+
+	// Files are the tbrc files the config was read from, in order of increasing priority.
+	// Files is not part of the config but is determined by Read.
+	Files []string `yaml:"-"`
+	// Sources maps each setting to where it was set, either a file, a profile, ex: profiles.venue-core,
+	// an environment variable, ex: $TB_EXPERIMENTAL, or a flag, ex: --set. Settings are identified by their key,
+	// ex: experimental. Entries of playlists, overrides, profiles, variables, and workspaces are identified
+	// by the key and the name of the entry, ex: overrides.TouchBistro/tb-registry/postgres, and
+	// registries are identified by their name, ex: registries.TouchBistro/tb-registry.
+	// Sources is not part of the config but is determined by Read.
+	Sources map[string]string `yaml:"-"`
+	// UntrustedFiles are the project tbrc files that were found but not used since they are not trusted.
+	// UntrustedFiles is not part of the config but is determined by Read.
+	UntrustedFiles []string `yaml:"-"`
+}
+
+// Workspace contains configuration for a workspace.
+type Workspace struct {
+	// PortOffset is added to each host port published by services in the workspace.
+	PortOffset int `yaml:"portOffset" desc:"Added to each host port published by services in the workspace."`
+}
+
+// Profile is a named set of settings that is applied on top of the rest of the config when it is used.
+// This allows switching between different setups without editing the config, ex: running all services
+// remotely or building one service locally.
+type Profile struct {
+	Overrides  map[string]service.ServiceOverride `yaml:"overrides" desc:"Overrides to apply to services, they are merged with the overrides of the same service."`
+	Playlists  map[string]playlist.Playlist       `yaml:"playlists" desc:"Custom playlists, they are merged with the custom playlists with the same name."`
+	Registries []registry.Registry                `yaml:"registries" desc:"Registries to use, ex: to use a different ref. They are merged with the registries with the same name, otherwise they are added."`
+}
+
+// Schema returns the JSON Schema of the tbrc config file.
+func Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(Config{}, "tb "+tbrcName)
 }
 
 // NOTE: This is deprecated and is only here for backwards compatibility.
@@ -55,11 +98,43 @@ func (c Config) DebugEnabled() bool {
 	return *c.Debug
 }
 
-// Read reads the config file using the given home directory.
-// If homedir is empty, it will be resolved from the environment.
-// If a config file does not exist in homedir, one will be created.
-func Read(homedir string) (Config, error) {
+// ReadOptions allows for customizing the behaviour of Read.
+// All fields are optional.
+type ReadOptions struct {
+	// HomeDir is the home directory containing the main tbrc.
+	// If it is empty, it will be resolved from the environment.
+	HomeDir string
+	// WorkingDir is the directory to start looking for project tbrc files in.
+	// If it is empty, the current working directory is used.
+	WorkingDir string
+	// Settings override settings from tbrc files and environment variables.
+	// Each setting is of the form key=value, ex: overrides.TouchBistro/tb-registry/postgres.mode=remote.
+	// Settings are applied in order, so later settings take priority.
+	Settings []string
+	// Profile is the name of the profile to use. If it is set, it takes priority over the profile setting.
+	Profile string
+}
+
+// Read reads the tbrc in the home directory. If it does not exist, one will be created.
+//
+// Read also looks for project tbrc files in the working directory and each of its parents,
+// stopping at the home directory. This allows a repo to have a checked in tbrc with the config
+// it needs. Project tbrc files are merged on top of the home tbrc, with files closer to the
+// working directory taking priority. See Config.Files and Config.Sources for where each setting came from.
+// Project tbrc files are only used if they have been trusted with TrustFile, since they control what
+// tb runs. Project tbrc files that are not trusted are ignored and recorded in Config.UntrustedFiles.
+//
+// The active profile, named by Config.Profile or opts.Profile, is applied on top of the settings from
+// tbrc files. If the profile does not exist, it is not applied and Init will return ErrProfileNotFound.
+// Settings can then be overridden by TB_* environment variables, ex: TB_EXPERIMENTAL=true,
+// and by opts.Settings, which take priority over everything else.
+//
+// Files using an old version of the tbrc format are migrated to the current version when they are read,
+// but they are not changed. Use Migrate to change them. The version of the returned config is always
+// the current version.
+func Read(opts ReadOptions) (Config, error) {
 	const op = errors.Op("config.Read")
+	homedir := opts.HomeDir
 	if homedir == "" {
 		var err error
 		homedir, err = os.UserHomeDir()
@@ -84,27 +159,99 @@ func Read(homedir string) (Config, error) {
 		}
 	}
 
-	f, err := os.Open(configPath)
+	projectPaths, err := findProjectFiles(opts.WorkingDir, homedir)
 	if err != nil {
 		return Config{}, errors.Wrap(err, errors.Meta{
 			Kind:   errkind.IO,
-			Reason: fmt.Sprintf("failed to open file %s", configPath),
+			Reason: "failed to find project tbrc files",
 			Op:     op,
 		})
 	}
-	defer f.Close()
+	trusted, err := readTrustFile(op, filepath.Join(homedir, rootDir, trustName))
+	if err != nil {
+		return Config{}, err
+	}
 
+	// Settings are merged as yaml nodes and decoded once they have all been applied.
+	// This makes sure they are decoded the same way as if they had been set in a single file.
 	var config Config
-	if err := yaml.NewDecoder(f).Decode(&config); err != nil {
+	var untrusted []string
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	nodes := make(map[string]*yaml.Node)
+	for _, path := range append([]string{configPath}, projectPaths...) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, errors.Wrap(err, errors.Meta{
+				Kind:   errkind.IO,
+				Reason: fmt.Sprintf("failed to read file %s", path),
+				Op:     op,
+			})
+		}
+		// Check the contents that are used, so the file can't change after it was checked.
+		if path != configPath && !trusted.trusts(path, data) {
+			untrusted = append(untrusted, path)
+			continue
+		}
+		node, err := decodeFile(op, path, data)
+		if err != nil {
+			return config, err
+		}
+		config.merge(root, node, path, path != configPath)
+		nodes[path] = node
+	}
+	if err := resolve(op, root, opts, config.Sources); err != nil {
+		return config, err
+	}
+	files, sources := config.Files, config.Sources
+	if err := root.Decode(&config); err != nil {
 		return config, errors.Wrap(err, errors.Meta{
-			Kind:   errkind.IO,
-			Reason: fmt.Sprintf("couldn't read yaml file at %s", configPath),
+			Kind:   errkind.Invalid,
+			Reason: "failed to decode settings",
 			Op:     op,
 		})
 	}
+	config.Files, config.Sources, config.UntrustedFiles = files, sources, untrusted
+	config.Version = CurrentVersion
+	if !config.Strict {
+		return config, nil
+	}
+	var errs errors.List
+	for _, path := range config.Files {
+		for _, uk := range jsonschema.FindUnknownKeys(Schema(), nodes[path]) {
+			errs = append(errs, errors.Wrap(uk, errors.Meta{
+				Kind:   errkind.Invalid,
+				Reason: path,
+				Op:     op,
+			}))
+		}
+	}
+	if len(errs) > 0 {
+		return config, errs
+	}
 	return config, nil
 }
 
+// readFile reads the tbrc at path and migrates it to the current version. It returns the yaml node
+// of the file so that keys set in the file can be determined. The file is decoded to make sure it is valid.
+func decodeFile(op errors.Op, path string, data []byte) (*yaml.Node, error) {
+	var node yaml.Node
+	err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&node)
+	if err == nil {
+		if _, _, err := migrateDocument(op, &node); err != nil {
+			return nil, errors.Wrap(err, errors.Meta{Reason: path, Op: op})
+		}
+		err = node.Decode(&Config{})
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("couldn't read yaml file at %s", path),
+			Op:     op,
+		})
+	}
+	return &node, nil
+}
+
 type InitOptions struct {
 	// If true, Init will load services and playlists from registries.
 	// If false, no services or playlists will be available in the returned Engine instance.
@@ -112,9 +259,14 @@ type InitOptions struct {
 	// If true, Init will load apps from registries.
 	// If false, no apps will be available in the returned Engine instance.
 	LoadApps bool
-	// If true, registries will be updated before being read, otherwise the existing version
-	// will be read. Missing registries will always be cloned regardless of the value of this field.
-	UpdateRegistries bool
+	// Workspace is the name of the workspace to use. If omitted, the default workspace is used.
+	Workspace string
+	// If true, registries will not be fetched, the commits that were last fetched will be used instead.
+	// Missing registries will always be cloned regardless of the value of this field.
+	NoRegistryPull bool
+	// GitClient is used to work with the git repos of registries and services.
+	// Defaults to git.New() if omitted.
+	GitClient git.Git
 }
 
 // Init takes a config and initializes an engine.Engine for performing tb operations.
@@ -122,6 +274,10 @@ type InitOptions struct {
 //
 // Init will read all registries specified in config and use that to produce a list of
 // services, playlists, and apps for the Engine to manage.
+//
+// Each registry is checked out at the commit recorded in the lock file. Registries that
+// are not in the lock file yet, or whose ref has changed, are resolved to a commit which
+// is then added to the lock file. Use UpdateRegistries to move the lock forward.
 func Init(ctx context.Context, config Config, opts InitOptions) (*engine.Engine, error) {
 	const op = errors.Op("config.Init")
 
@@ -144,6 +300,11 @@ func Init(ctx context.Context, config Config, opts InitOptions) (*engine.Engine,
 		})
 	}
 
+	// The profile was applied by Read, make sure it exists so that it isn't silently ignored.
+	if _, ok := config.Profiles[config.Profile]; config.Profile != "" && !ok {
+		return nil, errors.Wrap(ErrProfileNotFound, errors.Meta{Kind: errkind.Invalid, Reason: config.Profile, Op: op})
+	}
+
 	// Handle registries
 
 	// We need at least one registry otherwise tb is pretty useless so let the user know.
@@ -151,79 +312,14 @@ func Init(ctx context.Context, config Config, opts InitOptions) (*engine.Engine,
 		return nil, errors.New(errkind.Invalid, "no registries defined", op)
 	}
 
-	// Validate and normalize all registries.
 	tracker := progress.TrackerFromContext(ctx)
-	for i, r := range config.Registries {
-		// Resolve true registry path
-		if r.LocalPath != "" {
-			// Remind people they are using a local version in case they forgot
-			tracker.Infof("❗ Using a local version of the %s registry ❗", color.Cyan(r.Name))
-
-			// Local paths can be prefixed with ~ for convenience
-			if strings.HasPrefix(r.LocalPath, "~") {
-				r.Path = filepath.Join(homedir, strings.TrimPrefix(r.LocalPath, "~"))
-			} else {
-				path, err := filepath.Abs(r.LocalPath)
-				if err != nil {
-					return nil, errors.Wrap(err, errors.Meta{
-						Kind:   errkind.IO,
-						Reason: fmt.Sprintf("failed to resolve absolute path to local registry %s", r.Name),
-						Op:     op,
-					})
-				}
-				r.Path = path
-			}
-		} else {
-			// If not local, the path will be where the registry is/will be cloned.
-			r.Path = filepath.Join(tbRoot, registriesDir, r.Name)
-		}
-		config.Registries[i] = r
-	}
-
-	// Go through each registry and make sure it is ready for use.
-	err = progress.RunParallel(ctx, progress.RunParallelOptions{
-		Message: "Cloning/updating registries",
-		Count:   len(config.Registries),
-	}, func(ctx context.Context, i int) error {
-		r := config.Registries[i]
-		if r.LocalPath != "" {
-			// User's are responsible for local registries so we just assume they are good to go.
-			tracker.Debugf("Skipping local registry %s", r.Name)
-			return nil
-		}
-
-		// Clone if missing, otherwise we can't actually use it which would be pretty useless.
-		gitClient := git.New()
-		if !file.Exists(r.Path) {
-			tracker.Debugf("Registry %s is missing, cloning", r.Name)
-			if err := gitClient.Clone(ctx, r.Name, r.Path); err != nil {
-				return errors.Wrap(err, errors.Meta{
-					Reason: fmt.Sprintf("failed to clone registry %s", r.Name),
-					Op:     op,
-				})
-			}
-			tracker.Debugf("Finished cloning registry %s", r.Name)
-			return nil
-		}
-		if !opts.UpdateRegistries {
-			return nil
-		}
-
-		tracker.Debugf("Updating registry %s", r.Name)
-		if err := gitClient.Pull(ctx, r.Path); err != nil {
-			return errors.Wrap(err, errors.Meta{
-				Reason: fmt.Sprintf("failed to update registry %s", r.Name),
-				Op:     op,
-			})
-		}
-		tracker.Debugf("Finished cloning/pulling registry %s", r.Name)
-		return nil
-	})
-	if err != nil {
-		return nil, errors.Wrap(err, errors.Meta{
-			Reason: "failed to clone/update registries",
-			Op:     op,
-		})
+	if err := resolveRegistryPaths(ctx, config.Registries, homedir, tbRoot, op); err != nil {
+		return nil, err
+	}
+	// Make sure all registries are checked out at their locked commit.
+	syncOpts := syncOptions{noFetch: opts.NoRegistryPull, gitClient: opts.GitClient}
+	if _, err := syncRegistries(ctx, config.Registries, homedir, syncOpts, op); err != nil {
+		return nil, err
 	}
 
 	// Validate service overrides.
@@ -253,6 +349,8 @@ func Init(ctx context.Context, config Config, opts InitOptions) (*engine.Engine,
 		RootPath:     tbRoot,
 		ReposPath:    filepath.Join(tbRoot, "repos"),
 		Overrides:    config.Overrides,
+		Variables:    config.Variables,
+		Strict:       config.Strict,
 		Logger:       tracker,
 	})
 	if err != nil {
@@ -284,8 +382,17 @@ func Init(ctx context.Context, config Config, opts InitOptions) (*engine.Engine,
 		}
 	}
 
+	// Workspaces don't need to be configured, but without a port offset their ports
+	// will conflict with the other workspaces.
+	ws, ok := config.Workspaces[opts.Workspace]
+	if opts.Workspace != "" && !ok {
+		tracker.Warnf("Workspace %s has no portOffset configured in tbrc, its ports may conflict with other workspaces", opts.Workspace)
+	}
+
 	e, err := engine.New(engine.Options{
 		Workdir:         tbRoot,
+		Workspace:       opts.Workspace,
+		PortOffset:      ws.PortOffset,
 		Services:        registryResult.Services,
 		Playlists:       registryResult.Playlists,
 		IOSApps:         registryResult.IOSApps,
@@ -293,6 +400,7 @@ func Init(ctx context.Context, config Config, opts InitOptions) (*engine.Engine,
 		BaseImages:      registryResult.BaseImages,
 		LoginStrategies: registryResult.LoginStrategies,
 		DeviceList:      deviceList,
+		GitClient:       opts.GitClient,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, errors.Meta{Reason: "failed to initialize engine", Op: op})
@@ -321,8 +429,9 @@ func AddRegistry(registryName, homedir string) error {
 	}
 
 	// Check if registry already added
-	// We need to read the config first so we can look at the registries
-	config, err := Read(homedir)
+	// We need to read the config first so we can look at the registries.
+	// Only the home tbrc is modified so project tbrc files are ignored by using homedir as the working dir.
+	config, err := Read(ReadOptions{HomeDir: homedir, WorkingDir: homedir})
 	if err != nil {
 		return errors.Wrap(err, errors.Meta{Op: op})
 	}
@@ -379,8 +488,8 @@ func AddRegistry(registryName, homedir string) error {
 		Content: []*yaml.Node{nameKeyNode, nameValueNode},
 	}
 
-	// Find registries section
-	registriesNode := findYamlNode(tbrcDocumentNode, "registries")
+	// Find registries section, only the top level key is used since profiles can also have registries.
+	registriesNode := settingNode(tbrcDocumentNode, []string{"registries"}, nil)
 
 	// registries key doesn't exist
 	// need to add it at the end of the document
@@ -421,53 +530,126 @@ func AddRegistry(registryName, homedir string) error {
 	// Add new registries at the end of the list
 	registriesNode.Content = append(registriesNode.Content, registryNode)
 
+	if err := overwriteYamlFile(f, tbrcDocumentNode); err != nil {
+		return errors.Wrap(err, errors.Meta{Op: op})
+	}
+	return nil
+}
+
+// RemoveRegistryOptions customizes the behaviour of RemoveRegistry.
+type RemoveRegistryOptions struct {
+	// HomeDir is the home directory where the config file is located.
+	// If empty, it will be resolved from the environment.
+	HomeDir string
+	// RemoveClone specifies to also remove the local clone of the registry.
+	RemoveClone bool
+}
+
+// RemoveRegistry removes the registry from the config file. The registry is also removed
+// from the lock file. Comments in the config file are preserved.
+//
+// If the registry does not exist in the config file, ErrRegistryNotFound will be returned.
+func RemoveRegistry(registryName string, opts RemoveRegistryOptions) error {
+	const op = errors.Op("config.RemoveRegistry")
+	homedir := opts.HomeDir
+	if homedir == "" {
+		var err error
+		homedir, err = os.UserHomeDir()
+		if err != nil {
+			return errors.Wrap(err, errors.Meta{
+				Kind:   errkind.Internal,
+				Reason: "unable to find user home directory",
+				Op:     op,
+			})
+		}
+	}
+
+	// Read into a node to preserve comments, same as AddRegistry.
+	tbrcPath := filepath.Join(homedir, tbrcName)
+	f, err := os.OpenFile(tbrcPath, os.O_RDWR, 0644)
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to open file %s", tbrcPath),
+			Op:     op,
+		})
+	}
+	defer f.Close()
+
+	tbrcDocumentNode := &yaml.Node{}
+	if err := yaml.NewDecoder(f).Decode(tbrcDocumentNode); err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("couldn't read yaml file at %s", tbrcPath),
+			Op:     op,
+		})
+	}
+
+	// Find the registry in the registries list and remove it.
+	// Only the top level key is used since profiles can also have registries.
+	registriesNode := settingNode(tbrcDocumentNode, []string{"registries"}, nil)
+	if registriesNode == nil || registriesNode.Kind != yaml.SequenceNode {
+		return ErrRegistryNotFound
+	}
+	index := childIndex(registriesNode, registryName)
+	if index == -1 {
+		return ErrRegistryNotFound
+	}
+	registriesNode.Content = append(registriesNode.Content[:index], registriesNode.Content[index+1:]...)
+	if err := overwriteYamlFile(f, tbrcDocumentNode); err != nil {
+		return errors.Wrap(err, errors.Meta{Op: op})
+	}
+
+	lock, err := ReadLock(homedir)
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{Op: op})
+	}
+	if lock.removeRegistry(registryName) {
+		if err := WriteLock(homedir, lock); err != nil {
+			return errors.Wrap(err, errors.Meta{Op: op})
+		}
+	}
+
+	if opts.RemoveClone {
+		registryPath := filepath.Join(homedir, rootDir, registriesDir, registryName)
+		if err := os.RemoveAll(registryPath); err != nil {
+			return errors.Wrap(err, errors.Meta{
+				Kind:   errkind.IO,
+				Reason: fmt.Sprintf("failed to remove %s", registryPath),
+				Op:     op,
+			})
+		}
+	}
+	return nil
+}
+
+// overwriteYamlFile replaces the contents of f with the yaml encoded node.
+func overwriteYamlFile(f *os.File, node *yaml.Node) error {
+	const op = errors.Op("config.overwriteYamlFile")
 	// Make sure we overwrite the file instead of appending to it
 	// Need to go back to the start and truncate it
 	if _, err := f.Seek(0, 0); err != nil {
 		return errors.Wrap(err, errors.Meta{
 			Kind:   errkind.IO,
-			Reason: fmt.Sprintf("failed to seek start of file %s", tbrcPath),
+			Reason: fmt.Sprintf("failed to seek start of file %s", f.Name()),
 			Op:     op,
 		})
 	}
-
 	if err := f.Truncate(0); err != nil {
 		return errors.Wrap(err, errors.Meta{
 			Kind:   errkind.IO,
-			Reason: fmt.Sprintf("failed to truncate file %s", tbrcPath),
+			Reason: fmt.Sprintf("failed to truncate file %s", f.Name()),
 			Op:     op,
 		})
 	}
-
 	encoder := yaml.NewEncoder(f)
 	encoder.SetIndent(2)
-	if err := encoder.Encode(tbrcDocumentNode); err != nil {
+	if err := encoder.Encode(node); err != nil {
 		return errors.Wrap(err, errors.Meta{
 			Kind:   errkind.IO,
-			Reason: fmt.Sprintf("failed to write %s", tbrcPath),
+			Reason: fmt.Sprintf("failed to write %s", f.Name()),
 			Op:     op,
 		})
 	}
-
-	return nil
-}
-
-func findYamlNode(node *yaml.Node, key string) *yaml.Node {
-	foundKey := false
-	for _, n := range node.Content {
-		if foundKey {
-			return n
-		}
-		if n.Value == key {
-			foundKey = true
-			continue
-		}
-		if len(n.Content) > 0 {
-			foundNode := findYamlNode(n, key)
-			if foundNode != nil {
-				return foundNode
-			}
-		}
-	}
 	return nil
 }
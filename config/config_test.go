@@ -3,10 +3,16 @@ package config_test
 import (
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/TouchBistro/goutils/errors"
 	"github.com/TouchBistro/tb/config"
+	"github.com/TouchBistro/tb/errkind"
+	"github.com/TouchBistro/tb/internal/jsonschema"
 	"github.com/TouchBistro/tb/registry"
+	"github.com/TouchBistro/tb/resource/service"
 	"github.com/matryer/is"
 )
 
@@ -21,28 +27,46 @@ func TestLoad(t *testing.T) {
 			data: `experimental: true
 registries:
   - name: TouchBistro/tb-registry
+    ref: v1.2.0
   - name: ExampleZone/tb-registry
     localPath: ~/tools/tb-registry`,
 			want: func(homedir string) config.Config {
+				configPath := filepath.Join(homedir, ".tbrc.yml")
 				return config.Config{
 					ExperimentalMode: true,
+					Version:          config.CurrentVersion,
 					Registries: []registry.Registry{
 						{
-							Name:      "TouchBistro/tb-registry",
-							LocalPath: "",
+							Name: "TouchBistro/tb-registry",
+							Ref:  "v1.2.0",
 						},
 						{
 							Name:      "ExampleZone/tb-registry",
 							LocalPath: "~/tools/tb-registry",
 						},
 					},
+					Files: []string{configPath},
+					Sources: map[string]string{
+						"experimental":                       configPath,
+						"registries.TouchBistro/tb-registry": configPath,
+						"registries.ExampleZone/tb-registry": configPath,
+					},
 				}
 			},
 		},
 		{
 			name: "no tbrc",
 			want: func(homedir string) config.Config {
-				return config.Config{}
+				// The default tbrc is created.
+				configPath := filepath.Join(homedir, ".tbrc.yml")
+				return config.Config{
+					Version: config.CurrentVersion,
+					Files:   []string{configPath},
+					Sources: map[string]string{
+						"experimental": configPath,
+						"strict":       configPath,
+					},
+				}
 			},
 		},
 	}
@@ -57,7 +81,7 @@ registries:
 				}
 			}
 
-			cfg, err := config.Read(tmpdir)
+			cfg, err := config.Read(config.ReadOptions{HomeDir: tmpdir, WorkingDir: tmpdir})
 			is := is.New(t)
 			is.NoErr(err)
 			is.Equal(cfg, tt.want(tmpdir))
@@ -65,6 +89,294 @@ registries:
 	}
 }
 
+func TestLoadProject(t *testing.T) {
+	homedir := t.TempDir()
+	repoDir := filepath.Join(homedir, "code", "venue-core-service")
+	workdir := filepath.Join(repoDir, "src")
+	if err := os.MkdirAll(workdir, 0o755); err != nil {
+		t.Fatalf("failed to create dir %s: %v", workdir, err)
+	}
+	homePath := filepath.Join(homedir, ".tbrc.yml")
+	codePath := filepath.Join(homedir, "code", ".tbrc.yml")
+	repoPath := filepath.Join(repoDir, ".tbrc.yml")
+	files := map[string]string{
+		homePath: `experimental: true
+registries:
+  - name: TouchBistro/tb-registry
+  - name: ExampleZone/tb-registry
+overrides:
+  TouchBistro/tb-registry/postgres:
+    mode: build
+  TouchBistro/tb-registry/redis:
+    mode: build
+`,
+		codePath: `experimental: false
+variables:
+  logLevel: info
+`,
+		repoPath: `registries:
+  - name: TouchBistro/tb-registry
+    ref: v2.0.0
+  - name: TouchBistro/tb-registry-team
+    localPath: ../tb-registry-team
+overrides:
+  TouchBistro/tb-registry/postgres:
+    mode: remote
+variables:
+  logLevel: debug
+`,
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("failed to write file %s: %v", path, err)
+		}
+	}
+
+	// Project files are not used until they are trusted.
+	cfg, err := config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: workdir})
+	is := is.New(t)
+	is.NoErr(err)
+	is.Equal(cfg.Files, []string{homePath})
+	is.Equal(cfg.UntrustedFiles, []string{codePath, repoPath})
+	is.Equal(cfg.ExperimentalMode, true)
+
+	for _, path := range []string{codePath, repoPath} {
+		is.NoErr(config.TrustFile(path, config.TrustOptions{HomeDir: homedir}))
+	}
+	cfg, err = config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: workdir})
+	is.NoErr(err)
+	is.Equal(cfg, config.Config{
+		ExperimentalMode: false,
+		Version:          config.CurrentVersion,
+		Registries: []registry.Registry{
+			{Name: "TouchBistro/tb-registry", Ref: "v2.0.0"},
+			{Name: "ExampleZone/tb-registry"},
+			{Name: "TouchBistro/tb-registry-team", LocalPath: filepath.Join(homedir, "code", "tb-registry-team")},
+		},
+		Overrides: map[string]service.ServiceOverride{
+			"TouchBistro/tb-registry/postgres": {Mode: "remote"},
+			"TouchBistro/tb-registry/redis":    {Mode: "build"},
+		},
+		Variables: map[string]string{"logLevel": "debug"},
+		Files:     []string{homePath, codePath, repoPath},
+		Sources: map[string]string{
+			"experimental":                               codePath,
+			"registries.TouchBistro/tb-registry":         repoPath,
+			"registries.ExampleZone/tb-registry":         homePath,
+			"registries.TouchBistro/tb-registry-team":    repoPath,
+			"overrides.TouchBistro/tb-registry/postgres": repoPath,
+			"overrides.TouchBistro/tb-registry/redis":    homePath,
+			"variables.logLevel":                         repoPath,
+		},
+	})
+
+	// Trusted files that change are no longer used until they are trusted again.
+	changed := strings.Replace(files[codePath], "experimental: false", "experimental: true", 1)
+	if err := os.WriteFile(codePath, []byte(changed), 0o644); err != nil {
+		t.Fatalf("failed to write file %s: %v", codePath, err)
+	}
+	cfg, err = config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: workdir})
+	is.NoErr(err)
+	is.Equal(cfg.Files, []string{homePath, repoPath})
+	is.Equal(cfg.UntrustedFiles, []string{codePath})
+	is.NoErr(config.TrustFile(codePath, config.TrustOptions{HomeDir: homedir}))
+	cfg, err = config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: workdir})
+	is.NoErr(err)
+	is.Equal(cfg.Files, []string{homePath, codePath, repoPath})
+	is.Equal(cfg.ExperimentalMode, true)
+
+	// Changing a trusted file with tb keeps it trusted.
+	is.NoErr(config.SetSetting("experimental", "false", config.EditOptions{HomeDir: homedir, File: codePath}))
+	cfg, err = config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: workdir})
+	is.NoErr(err)
+	is.Equal(cfg.Files, []string{homePath, codePath, repoPath})
+	is.Equal(cfg.ExperimentalMode, false)
+
+	// Untrusted files are no longer used.
+	is.NoErr(config.UntrustFile(codePath, config.TrustOptions{HomeDir: homedir}))
+	cfg, err = config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: workdir})
+	is.NoErr(err)
+	is.Equal(cfg.Files, []string{homePath, repoPath})
+	is.Equal(cfg.UntrustedFiles, []string{codePath})
+	err = config.UntrustFile(codePath, config.TrustOptions{HomeDir: homedir})
+	is.True(errors.Is(err, config.ErrFileNotTrusted))
+
+	// Project files above the home directory are not used.
+	cfg, err = config.Read(config.ReadOptions{HomeDir: repoDir, WorkingDir: workdir})
+	is.NoErr(err)
+	is.Equal(cfg.Files, []string{repoPath})
+}
+
+func TestLoadOverrides(t *testing.T) {
+	homedir := t.TempDir()
+	workdir := filepath.Join(homedir, "code")
+	if err := os.MkdirAll(workdir, 0o755); err != nil {
+		t.Fatalf("failed to create dir %s: %v", workdir, err)
+	}
+	homePath := filepath.Join(homedir, ".tbrc.yml")
+	projectPath := filepath.Join(workdir, ".tbrc.yml")
+	files := map[string]string{
+		homePath: `experimental: false
+registries:
+  - name: TouchBistro/tb-registry
+overrides:
+  TouchBistro/tb-registry/postgres:
+    mode: build
+  TouchBistro/tb-registry/redis:
+    mode: build
+variables:
+  logLevel: info
+`,
+		projectPath: `overrides:
+  TouchBistro/tb-registry/postgres:
+    mode: remote
+variables:
+  region: ca
+`,
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("failed to write file %s: %v", path, err)
+		}
+	}
+	if err := config.TrustFile(projectPath, config.TrustOptions{HomeDir: homedir}); err != nil {
+		t.Fatalf("failed to trust file %s: %v", projectPath, err)
+	}
+	t.Setenv("TB_EXPERIMENTAL", "true")
+	t.Setenv("TB_OVERRIDES", `{TouchBistro/tb-registry/postgres: {remote: {tag: "14"}}, TouchBistro/tb-registry/redis: {mode: remote}}`)
+	t.Setenv("TB_VARIABLES", "{logLevel: warn}")
+
+	cfg, err := config.Read(config.ReadOptions{
+		HomeDir:    homedir,
+		WorkingDir: workdir,
+		Settings: []string{
+			"variables.logLevel=debug",
+			"overrides.TouchBistro/tb-registry/redis.mode=build",
+			"registries.TouchBistro/tb-registry.ref=v2.0.0",
+			"registries.ExampleZone/tb-registry.priority=1",
+			"overrides.TouchBistro/tb-registry/redis.mode=remote",
+		},
+	})
+	is := is.New(t)
+	is.NoErr(err)
+	is.Equal(cfg, config.Config{
+		ExperimentalMode: true,
+		Version:          config.CurrentVersion,
+		Registries: []registry.Registry{
+			{Name: "TouchBistro/tb-registry", Ref: "v2.0.0"},
+			{Name: "ExampleZone/tb-registry", Priority: 1},
+		},
+		Overrides: map[string]service.ServiceOverride{
+			"TouchBistro/tb-registry/postgres": {Mode: "remote", Remote: service.RemoteOverride{Tag: "14"}},
+			"TouchBistro/tb-registry/redis":    {Mode: "remote"},
+		},
+		Variables: map[string]string{"logLevel": "debug", "region": "ca"},
+		Files:     []string{homePath, projectPath},
+		Sources: map[string]string{
+			"experimental":                               "$TB_EXPERIMENTAL",
+			"registries.TouchBistro/tb-registry":         "--set",
+			"registries.ExampleZone/tb-registry":         "--set",
+			"overrides.TouchBistro/tb-registry/postgres": "$TB_OVERRIDES",
+			"overrides.TouchBistro/tb-registry/redis":    "--set",
+			"variables.logLevel":                         "--set",
+			"variables.region":                           projectPath,
+		},
+	})
+}
+
+func TestLoadOverridesKeepEmptyValues(t *testing.T) {
+	tmpdir := t.TempDir()
+	configPath := filepath.Join(tmpdir, ".tbrc.yml")
+	data := `overrides:
+  TouchBistro/tb-registry/venue-core-service:
+    entrypoint: []
+    envVars:
+      FOO: ""
+`
+	if err := os.WriteFile(configPath, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write file %s: %v", configPath, err)
+	}
+	t.Setenv("TB_EXPERIMENTAL", "true")
+
+	cfg, err := config.Read(config.ReadOptions{
+		HomeDir:    tmpdir,
+		WorkingDir: tmpdir,
+		Settings:   []string{"overrides.TouchBistro/tb-registry/venue-core-service.mode=build"},
+	})
+	is := is.New(t)
+	is.NoErr(err)
+	is.True(cfg.ExperimentalMode)
+	// Empty values are meaningful in overrides, they clear the entrypoint and set FOO to an empty string.
+	is.Equal(cfg.Overrides, map[string]service.ServiceOverride{
+		"TouchBistro/tb-registry/venue-core-service": {
+			Entrypoint: []string{},
+			EnvVars:    map[string]string{"FOO": ""},
+			Mode:       "build",
+		},
+	})
+}
+
+func TestLoadOverridesInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		settings []string
+	}{
+		{"invalid env value", map[string]string{"TB_EXPERIMENTAL": "maybe"}, nil},
+		{"unknown key in env value", map[string]string{"TB_OVERRIDES": "{TouchBistro/tb-registry/postgres: {mod: remote}}"}, nil},
+		{"missing value", nil, []string{"experimental"}},
+		{"unknown key", nil, []string{"experimentl=true"}},
+		{"invalid enum", nil, []string{"overrides.TouchBistro/tb-registry/postgres.mode=local"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpdir := t.TempDir()
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := config.Read(config.ReadOptions{HomeDir: tmpdir, WorkingDir: tmpdir, Settings: tt.settings})
+			is := is.New(t)
+			var errsErr *errors.Error
+			is.True(errors.As(err, &errsErr))
+			is.Equal(errsErr.Kind, errkind.Invalid)
+		})
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	tmpdir := t.TempDir()
+	configPath := filepath.Join(tmpdir, ".tbrc.yml")
+	data := `strict: true
+overides:
+  TouchBistro/tb-registry/postgres:
+    mode: remote
+    remote:
+      tagg: latest
+`
+	if err := os.WriteFile(configPath, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write file %s: %v", configPath, err)
+	}
+
+	_, err := config.Read(config.ReadOptions{HomeDir: tmpdir, WorkingDir: tmpdir})
+	is := is.New(t)
+	var errs errors.List
+	is.True(errors.As(err, &errs))
+	is.Equal(len(errs), 1)
+	var uk *jsonschema.UnknownKeyError
+	is.True(errors.As(errs[0], &uk))
+	is.Equal(uk.Key, "overides")
+	is.Equal(uk.Line, 2)
+	is.Equal(uk.Suggestion, "overrides")
+
+	// Without strict unknown keys are ignored.
+	data = strings.Replace(data, "strict: true", "strict: false", 1)
+	if err := os.WriteFile(configPath, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write file %s: %v", configPath, err)
+	}
+	_, err = config.Read(config.ReadOptions{HomeDir: tmpdir, WorkingDir: tmpdir})
+	is.NoErr(err)
+}
+
 type addRegistryTest struct {
 	name         string
 	registryName string
@@ -209,6 +521,27 @@ playlists:
 `,
 		err: config.ErrRegistryExists,
 	},
+	{
+		name:         "profiles before registries",
+		registryName: "TouchBistro/tb-registry-example",
+		existingTBRC: `profiles:
+  local:
+    registries:
+      - name: TouchBistro/tb-registry
+        localPath: ~/registries/TouchBistro/tb-registry
+registries:
+  - name: TouchBistro/tb-registry
+`,
+		expectedTBRC: `profiles:
+  local:
+    registries:
+      - name: TouchBistro/tb-registry
+        localPath: ~/registries/TouchBistro/tb-registry
+registries:
+  - name: TouchBistro/tb-registry
+  - name: TouchBistro/tb-registry-example
+`,
+	},
 }
 
 func TestAddRegistry(t *testing.T) {
@@ -220,7 +553,7 @@ func TestAddRegistry(t *testing.T) {
 			if err != nil {
 				t.Fatalf("failed to write file %s: %v", tbrcPath, err)
 			}
-			if _, err := config.Read(tmpdir); err != nil {
+			if _, err := config.Read(config.ReadOptions{HomeDir: tmpdir, WorkingDir: tmpdir}); err != nil {
 				t.Fatalf("failed to load tbrc: %v", err)
 			}
 
@@ -236,3 +569,131 @@ func TestAddRegistry(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoveRegistry(t *testing.T) {
+	tests := []struct {
+		name         string
+		registryName string
+		existingTBRC string
+		expectedTBRC string
+		err          error
+	}{
+		{
+			name:         "removes registry and keeps comments",
+			registryName: "TouchBistro/tb-registry-example",
+			existingTBRC: `# Toggle experimental mode to test new features
+experimental: false
+# Add registries to access their services and playlists
+registries:
+  - name: TouchBistro/tb-registry
+    localPath: ~/registries/TouchBistro/tb-registry
+  - name: TouchBistro/tb-registry-example
+# Custom playlists
+playlists:
+  online-ordering:
+    services:
+      - online-ordering-service
+`,
+			expectedTBRC: `# Toggle experimental mode to test new features
+experimental: false
+# Add registries to access their services and playlists
+registries:
+  - name: TouchBistro/tb-registry
+    localPath: ~/registries/TouchBistro/tb-registry
+# Custom playlists
+playlists:
+  online-ordering:
+    services:
+      - online-ordering-service
+`,
+		},
+		{
+			name:         "registry does not exist",
+			registryName: "TouchBistro/tb-registry-example",
+			existingTBRC: `# Add registries to access their services and playlists
+registries:
+  - name: TouchBistro/tb-registry
+`,
+			expectedTBRC: `# Add registries to access their services and playlists
+registries:
+  - name: TouchBistro/tb-registry
+`,
+			err: config.ErrRegistryNotFound,
+		},
+		{
+			name:         "profiles before registries",
+			registryName: "TouchBistro/tb-registry",
+			existingTBRC: `profiles:
+  local:
+    registries:
+      - name: TouchBistro/tb-registry
+        localPath: ~/registries/TouchBistro/tb-registry
+registries:
+  - name: TouchBistro/tb-registry
+  - name: TouchBistro/tb-registry-example
+`,
+			expectedTBRC: `profiles:
+  local:
+    registries:
+      - name: TouchBistro/tb-registry
+        localPath: ~/registries/TouchBistro/tb-registry
+registries:
+  - name: TouchBistro/tb-registry-example
+`,
+		},
+		{
+			name:         "no registries",
+			registryName: "TouchBistro/tb-registry",
+			existingTBRC: `experimental: false
+`,
+			expectedTBRC: `experimental: false
+`,
+			err: config.ErrRegistryNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpdir := t.TempDir()
+			tbrcPath := filepath.Join(tmpdir, ".tbrc.yml")
+			err := os.WriteFile(tbrcPath, []byte(tt.existingTBRC), 0o644)
+			if err != nil {
+				t.Fatalf("failed to write file %s: %v", tbrcPath, err)
+			}
+			clonePath := filepath.Join(tmpdir, ".tb", "registries", tt.registryName)
+			if err := os.MkdirAll(clonePath, 0o755); err != nil {
+				t.Fatalf("failed to create dir %s: %v", clonePath, err)
+			}
+
+			err = config.RemoveRegistry(tt.registryName, config.RemoveRegistryOptions{
+				HomeDir:     tmpdir,
+				RemoveClone: true,
+			})
+			is := is.New(t)
+			is.Equal(err, tt.err)
+
+			data, err := os.ReadFile(tbrcPath)
+			if err != nil {
+				t.Fatalf("Failed to read tbrc file: %v", err)
+			}
+			is.Equal(string(data), tt.expectedTBRC)
+			_, err = os.Stat(clonePath)
+			is.Equal(os.IsNotExist(err), tt.err == nil) // clone is only removed if the registry was removed
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	is := is.New(t)
+	s := config.Schema()
+	is.Equal(s.Title, "tb .tbrc.yml")
+	var props []string
+	for name, ps := range s.Properties {
+		props = append(props, name)
+		is.True(ps.Description != "") // every field is documented
+	}
+	sort.Strings(props)
+	is.Equal(props, []string{"debug", "experimental", "overrides", "playlists", "profile", "profiles", "registries", "strict", "variables", "version", "workspaces"})
+	// Overrides of lists can be a list or an object.
+	override := s.Definitions["service.ServiceOverride"]
+	is.Equal(len(override.Properties["ports"].OneOf), 2)
+}
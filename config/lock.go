@@ -0,0 +1,141 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TouchBistro/goutils/errors"
+	"github.com/TouchBistro/goutils/file"
+	"github.com/TouchBistro/tb/errkind"
+	"gopkg.in/yaml.v3"
+)
+
+// lockName is the name of the file that records which commit each registry is locked to.
+// It lives next to the tbrc since it is tied to the registries listed in it.
+const lockName = ".tb.lock"
+
+// Lock represents a lock file which records the exact commit each registry is locked to.
+// This makes sure registries only change when a user explicitly updates them, instead
+// of every time a new commit is pushed.
+type Lock struct {
+	// Registries maps registries to the commit they are locked to.
+	// Registries are keyed by name, or by name@ref if the registry config sets a ref,
+	// so that each ref a registry is used with stays locked, ex: when profiles use different refs.
+	Registries map[string]LockedRegistry `yaml:"registries"`
+}
+
+// lockKey returns the key of a registry with the given name and ref in Lock.Registries.
+func lockKey(name, ref string) string {
+	if ref == "" {
+		return name
+	}
+	return name + "@" + ref
+}
+
+// removeRegistry removes every locked ref of the registry with the given name.
+// It returns true if any entries were removed.
+func (l Lock) removeRegistry(name string) bool {
+	removed := false
+	for k := range l.Registries {
+		if k == name || strings.HasPrefix(k, name+"@") {
+			delete(l.Registries, k)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// LockedRegistry records the commit a registry is locked to.
+type LockedRegistry struct {
+	// Ref is the ref from the registry config that was resolved to Commit.
+	Ref string `yaml:"ref,omitempty"`
+	// Commit is the SHA of the commit the registry is locked to.
+	Commit string `yaml:"commit"`
+}
+
+// ReadLock reads the lock file located in the given home directory.
+// If homedir is empty, it will be resolved from the environment.
+// If the lock file does not exist, an empty Lock is returned.
+func ReadLock(homedir string) (Lock, error) {
+	const op = errors.Op("config.ReadLock")
+	lockPath, err := lockFilePath(homedir, op)
+	if err != nil {
+		return Lock{}, err
+	}
+	lock := Lock{Registries: make(map[string]LockedRegistry)}
+	if !file.Exists(lockPath) {
+		return lock, nil
+	}
+	b, err := os.ReadFile(lockPath)
+	if err != nil {
+		return Lock{}, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to read file %s", lockPath),
+			Op:     op,
+		})
+	}
+	if err := yaml.Unmarshal(b, &lock); err != nil {
+		return Lock{}, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Invalid,
+			Reason: fmt.Sprintf("couldn't read yaml file at %s", lockPath),
+			Op:     op,
+		})
+	}
+	if lock.Registries == nil {
+		lock.Registries = make(map[string]LockedRegistry)
+	}
+	// Older lock files keyed registries only by name, move those entries to their name@ref key.
+	for k, lr := range lock.Registries {
+		if lr.Ref != "" && !strings.Contains(k, "@") {
+			delete(lock.Registries, k)
+			lock.Registries[lockKey(k, lr.Ref)] = lr
+		}
+	}
+	return lock, nil
+}
+
+// WriteLock writes the lock file to the given home directory, replacing any existing lock file.
+// If homedir is empty, it will be resolved from the environment.
+func WriteLock(homedir string, lock Lock) error {
+	const op = errors.Op("config.WriteLock")
+	lockPath, err := lockFilePath(homedir, op)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY\n")
+	buf.WriteString("# Use 'tb registry update' to update the locked registries.\n\n")
+	if err := yaml.NewEncoder(&buf).Encode(lock); err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Internal,
+			Reason: "failed to encode lock file",
+			Op:     op,
+		})
+	}
+	if err := os.WriteFile(lockPath, buf.Bytes(), 0o644); err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to write file %s", lockPath),
+			Op:     op,
+		})
+	}
+	return nil
+}
+
+func lockFilePath(homedir string, op errors.Op) (string, error) {
+	if homedir == "" {
+		var err error
+		homedir, err = os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, errors.Meta{
+				Kind:   errkind.Internal,
+				Reason: "unable to find user home directory",
+				Op:     op,
+			})
+		}
+	}
+	return filepath.Join(homedir, lockName), nil
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/TouchBistro/goutils/errors"
+	"github.com/TouchBistro/tb/errkind"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the version of the tbrc format used by this version of tb.
+// It must be incremented whenever a migration is added.
+const CurrentVersion = 1
+
+// migration updates the layout of a tbrc to a new version of the format.
+type migration struct {
+	// version is the version of the format the migration updates to.
+	version int
+	// migrate updates the top level mapping of a tbrc in place. It returns a description
+	// of each change that was made.
+	migrate func(root *yaml.Node) []string
+}
+
+// migrations are all migrations in order of increasing version.
+var migrations = []migration{
+	{version: 1, migrate: migrateV1},
+}
+
+// MigrateOptions allows for customizing the behaviour of Migrate.
+// All fields are optional.
+type MigrateOptions struct {
+	// HomeDir is the home directory containing the main tbrc.
+	// If it is empty, it will be resolved from the environment.
+	HomeDir string
+	// File is the path of the tbrc file to migrate. If it is empty, the tbrc in HomeDir is migrated.
+	File string
+	// Check makes Migrate only determine which changes are needed without changing the file.
+	Check bool
+}
+
+// MigrateResult describes the migration of a tbrc file.
+type MigrateResult struct {
+	// Path is the path of the tbrc file.
+	Path string
+	// FromVersion is the version of the file before it was migrated.
+	// Files without a version are version 0.
+	FromVersion int
+	// ToVersion is the version of the file after it was migrated.
+	ToVersion int
+	// Changes describes each change made to the file. It is empty if the file did not need to be migrated.
+	Changes []string
+	// BackupPath is the path of the backup of the original file.
+	// It is empty if the file was not changed.
+	BackupPath string
+}
+
+// Needed reports whether the file needed to be migrated.
+func (r MigrateResult) Needed() bool {
+	return len(r.Changes) > 0
+}
+
+// Migrate migrates a tbrc file to the current version of the format. The file is changed in place
+// and comments are preserved. Before the file is changed, a backup of the original file is written
+// next to it, ex: .tbrc.yml.v0.bak. If opts.Check is set, the file is not changed.
+//
+// If the file does not need to be migrated or does not exist, it is not changed.
+func Migrate(opts MigrateOptions) (MigrateResult, error) {
+	const op = errors.Op("config.Migrate")
+	path, err := tbrcFile(op, opts.HomeDir, opts.File)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	result := MigrateResult{Path: path, ToVersion: CurrentVersion}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return result, nil
+	} else if err != nil {
+		return result, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to open file %s", path),
+			Op:     op,
+		})
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return result, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to read file %s", path),
+			Op:     op,
+		})
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode}
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(doc); err != nil && err != io.EOF {
+		return result, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("couldn't read yaml file at %s", path),
+			Op:     op,
+		})
+	}
+	result.FromVersion, result.Changes, err = migrateDocument(op, doc)
+	if err != nil {
+		return result, errors.Wrap(err, errors.Meta{Reason: path, Op: op})
+	}
+	if !result.Needed() {
+		result.ToVersion = result.FromVersion
+		return result, nil
+	}
+	if opts.Check {
+		return result, nil
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d.bak", path, result.FromVersion)
+	if err := os.WriteFile(backupPath, data, 0o644); err != nil {
+		return result, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to write backup of %s", path),
+			Op:     op,
+		})
+	}
+	result.BackupPath = backupPath
+	if err := overwriteYamlFile(f, doc); err != nil {
+		return result, errors.Wrap(err, errors.Meta{Op: op})
+	}
+	// Migrating a trusted project tbrc shouldn't make it untrusted.
+	if opts.File != "" {
+		if err := retrustFile(op, opts.HomeDir, path, data); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// migrateDocument migrates the tbrc document doc in place to the current version.
+// It returns the version of the document before it was migrated and the changes that were made.
+// If changes were made, the version of the document is set to the current version.
+func migrateDocument(op errors.Op, doc *yaml.Node) (int, []string, error) {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return 0, nil, nil
+	}
+	root := doc.Content[0]
+	version := 0
+	vi := childIndex(root, "version")
+	if vi != -1 {
+		v, err := strconv.Atoi(root.Content[vi].Value)
+		if err != nil || v < 0 {
+			msg := fmt.Sprintf("line %d: invalid version %q, must be a non-negative integer", root.Content[vi].Line, root.Content[vi].Value)
+			return 0, nil, errors.New(errkind.Invalid, msg, op)
+		}
+		version = v
+	}
+	if version > CurrentVersion {
+		msg := fmt.Sprintf("version %d is newer than the latest version supported by tb, %d, please upgrade tb", version, CurrentVersion)
+		return version, nil, errors.New(errkind.Invalid, msg, op)
+	}
+
+	var changes []string
+	for _, m := range migrations {
+		if m.version > version {
+			changes = append(changes, m.migrate(root)...)
+		}
+	}
+	if len(changes) == 0 {
+		return version, nil, nil
+	}
+	versionNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(CurrentVersion)}
+	if vi != -1 {
+		root.Content[vi].Value = versionNode.Value
+	} else {
+		// Put the version first so it is easy to find.
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+		root.Content = append([]*yaml.Node{keyNode, versionNode}, root.Content...)
+		if len(root.Content) > 2 {
+			// Keep any comment at the top of the file at the top.
+			keyNode.HeadComment, root.Content[2].HeadComment = root.Content[2].HeadComment, ""
+		}
+	}
+	return version, changes, nil
+}
+
+// migrateV1 only adds the version. Version 1 is the first version of the format, the layout
+// is the same as files without a version.
+func migrateV1(root *yaml.Node) []string {
+	return []string{"added version"}
+}
@@ -0,0 +1,160 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/goutils/errors"
+	"github.com/TouchBistro/tb/config"
+	"github.com/TouchBistro/tb/errkind"
+	"github.com/TouchBistro/tb/resource/service"
+	"github.com/matryer/is"
+)
+
+const oldTBRC = `# My tbrc
+experimental: true
+# Override service configuration
+overrides:
+  TouchBistro/tb-registry/postgres:
+    mode: remote
+    remote:
+      tag: "14"
+`
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name        string
+		tbrc        string
+		wantVersion int
+		wantChanges []string
+		wantTBRC    string
+	}{
+		{
+			name:        "no version",
+			tbrc:        oldTBRC,
+			wantVersion: 0,
+			wantChanges: []string{"added version"},
+			wantTBRC: `# My tbrc
+version: 1
+experimental: true
+# Override service configuration
+overrides:
+  TouchBistro/tb-registry/postgres:
+    mode: remote
+    remote:
+      tag: "14"
+`,
+		},
+		{
+			name:        "version 0",
+			tbrc:        "version: 0\nexperimental: true\n",
+			wantVersion: 0,
+			wantChanges: []string{"added version"},
+			wantTBRC:    "version: 1\nexperimental: true\n",
+		},
+		{
+			name:        "current version",
+			tbrc:        "version: 1\nexperimental: true\n",
+			wantVersion: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			tbrcPath := filepath.Join(t.TempDir(), ".tbrc.yml")
+			err := os.WriteFile(tbrcPath, []byte(tt.tbrc), 0o644)
+			is.NoErr(err)
+
+			// Check doesn't change anything.
+			result, err := config.Migrate(config.MigrateOptions{File: tbrcPath, Check: true})
+			is.NoErr(err)
+			is.Equal(result.FromVersion, tt.wantVersion)
+			is.Equal(result.Changes, tt.wantChanges)
+			is.Equal(result.BackupPath, "")
+			data, err := os.ReadFile(tbrcPath)
+			is.NoErr(err)
+			is.Equal(string(data), tt.tbrc)
+
+			result, err = config.Migrate(config.MigrateOptions{File: tbrcPath})
+			is.NoErr(err)
+			is.Equal(result.Changes, tt.wantChanges)
+			data, err = os.ReadFile(tbrcPath)
+			is.NoErr(err)
+			if !result.Needed() {
+				is.Equal(result.BackupPath, "")
+				is.Equal(string(data), tt.tbrc)
+				return
+			}
+			is.Equal(result.ToVersion, config.CurrentVersion)
+			is.Equal(string(data), tt.wantTBRC)
+			backup, err := os.ReadFile(result.BackupPath)
+			is.NoErr(err)
+			is.Equal(string(backup), tt.tbrc)
+
+			// Migrating again is a no-op.
+			result, err = config.Migrate(config.MigrateOptions{File: tbrcPath})
+			is.NoErr(err)
+			is.True(!result.Needed())
+		})
+	}
+}
+
+func TestMigrateInvalidVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		tbrc string
+	}{
+		{"newer version", "version: 100\n"},
+		{"not a number", "version: latest\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			tmpdir := t.TempDir()
+			err := os.WriteFile(filepath.Join(tmpdir, ".tbrc.yml"), []byte(tt.tbrc), 0o644)
+			is.NoErr(err)
+
+			_, err = config.Migrate(config.MigrateOptions{HomeDir: tmpdir})
+			var errsErr *errors.Error
+			is.True(errors.As(err, &errsErr))
+			is.Equal(errsErr.Kind, errkind.Invalid)
+
+			// Read fails too since the file can't be understood.
+			_, err = config.Read(config.ReadOptions{HomeDir: tmpdir, WorkingDir: tmpdir})
+			is.True(errors.As(err, &errsErr))
+			is.Equal(errsErr.Kind, errkind.Invalid)
+		})
+	}
+}
+
+func TestReadMigrates(t *testing.T) {
+	is := is.New(t)
+	tmpdir := t.TempDir()
+	tbrcPath := filepath.Join(tmpdir, ".tbrc.yml")
+	err := os.WriteFile(tbrcPath, []byte(oldTBRC), 0o644)
+	is.NoErr(err)
+
+	cfg, err := config.Read(config.ReadOptions{HomeDir: tmpdir, WorkingDir: tmpdir})
+	is.NoErr(err)
+	is.Equal(cfg.Version, config.CurrentVersion)
+	is.Equal(cfg.Overrides, map[string]service.ServiceOverride{
+		"TouchBistro/tb-registry/postgres": {Mode: "remote", Remote: service.RemoteOverride{Tag: "14"}},
+	})
+	// The file is not changed.
+	data, err := os.ReadFile(tbrcPath)
+	is.NoErr(err)
+	is.Equal(string(data), oldTBRC)
+}
+
+func TestMigrateDefaultTBRC(t *testing.T) {
+	is := is.New(t)
+	tmpdir := t.TempDir()
+	// Read creates the default tbrc which must use the current version.
+	_, err := config.Read(config.ReadOptions{HomeDir: tmpdir, WorkingDir: tmpdir})
+	is.NoErr(err)
+	result, err := config.Migrate(config.MigrateOptions{HomeDir: tmpdir, Check: true})
+	is.NoErr(err)
+	is.Equal(result.FromVersion, config.CurrentVersion)
+	is.True(!result.Needed())
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"github.com/TouchBistro/goutils/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrProfileNotFound indicates that the profile being used does not exist.
+var ErrProfileNotFound errors.String = "profile not found"
+
+// applyProfile applies the active profile, named by the profile setting, to root, which is the top level
+// mapping of the settings. If no profile is active or the profile does not exist, root is not changed.
+// The source of each setting changed by the profile is recorded in sources, ex: profiles.venue-core.
+//
+// Overrides and playlists in the profile are merged with the ones with the same name in root,
+// otherwise they are added. Only the fields set in the profile are changed, ex: a profile can change
+// the mode of a service override without removing its env vars. Registries in the profile are merged
+// with the registries with the same name in root, otherwise they are added. Settings are merged as yaml nodes
+// so that empty values are kept, ex: a profile can clear the entrypoint of a service with entrypoint: [].
+func applyProfile(root *yaml.Node, sources map[string]string) {
+	n := settingNode(root, []string{"profile"}, nil)
+	if n == nil || n.Kind != yaml.ScalarNode || n.Tag == "!!null" || n.Value == "" {
+		return
+	}
+	name := n.Value
+	p := settingNode(root, []string{"profiles", name}, nil)
+	if p == nil || p.Kind != yaml.MappingNode {
+		return
+	}
+	// Copy the profile so that it isn't changed by merging.
+	p = copyNode(p)
+	source := "profiles." + name
+	for i := 0; i+1 < len(p.Content); i += 2 {
+		key, value := p.Content[i].Value, p.Content[i+1]
+		if len(value.Content) == 0 {
+			continue
+		}
+		path := []string{key}
+		dst := settingNode(root, path, settingArrays(path))
+		if dst.Kind == yaml.ScalarNode && dst.Tag == "!!null" {
+			// Not set in the rest of the config.
+			*dst = yaml.Node{Kind: value.Kind, Tag: value.Tag}
+		}
+		if value.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				entry := value.Content[j].Value
+				if ci := childIndex(dst, entry); ci != -1 {
+					mergeNode(dst.Content[ci], value.Content[j+1])
+				} else {
+					dst.Content = append(dst.Content, value.Content[j], value.Content[j+1])
+				}
+				sources[key+"."+entry] = source
+			}
+			continue
+		}
+		// Merge entries of lists by name.
+		for _, elem := range value.Content {
+			entry := entryName(elem)
+			found := false
+			for _, existing := range dst.Content {
+				if entryName(existing) == entry {
+					mergeNode(existing, elem)
+					found = true
+					break
+				}
+			}
+			if !found {
+				dst.Content = append(dst.Content, elem)
+			}
+			sources[key+"."+entry] = source
+		}
+	}
+}
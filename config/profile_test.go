@@ -0,0 +1,174 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/tb/config"
+	"github.com/TouchBistro/tb/registry"
+	"github.com/TouchBistro/tb/resource/playlist"
+	"github.com/TouchBistro/tb/resource/service"
+	"github.com/matryer/is"
+)
+
+func TestApplyProfile(t *testing.T) {
+	homedir := t.TempDir()
+	homePath := filepath.Join(homedir, ".tbrc.yml")
+	data := `registries:
+  - name: TouchBistro/tb-registry
+    ref: v1.0.0
+  - name: TouchBistro/tb-registry-team
+    localPath: /code/tb-registry-team
+playlists:
+  core:
+    services: [postgres, redis]
+overrides:
+  TouchBistro/tb-registry/venue-core-service:
+    mode: remote
+    entrypoint: []
+    envVars:
+      LOG_LEVEL: debug
+      FOO: ""
+  TouchBistro/tb-registry/postgres:
+    mode: remote
+    entrypoint: [postgres]
+profiles:
+  venue-core:
+    overrides:
+      TouchBistro/tb-registry/venue-core-service:
+        mode: build
+        build:
+          target: dev
+      TouchBistro/tb-registry/postgres:
+        entrypoint: []
+      TouchBistro/tb-registry/redis:
+        mode: build
+    playlists:
+      core:
+        services: [postgres, redis, venue-core-service]
+      venue:
+        extends: core
+    registries:
+      - name: TouchBistro/tb-registry
+        ref: feat/venue
+      - name: ExampleZone/tb-registry
+`
+	if err := os.WriteFile(homePath, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write file %s: %v", homePath, err)
+	}
+
+	is := is.New(t)
+	cfg, err := config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: homedir, Profile: "venue-core"})
+	is.NoErr(err)
+	is.Equal(cfg.Registries, []registry.Registry{
+		{Name: "TouchBistro/tb-registry", Ref: "feat/venue"},
+		{Name: "TouchBistro/tb-registry-team", LocalPath: "/code/tb-registry-team"},
+		{Name: "ExampleZone/tb-registry"},
+	})
+	is.Equal(cfg.Playlists, map[string]playlist.Playlist{
+		"core":  {Services: []string{"postgres", "redis", "venue-core-service"}},
+		"venue": {Extends: "core"},
+	})
+	// Empty values are kept, both from the rest of the config and from the profile.
+	is.Equal(cfg.Overrides, map[string]service.ServiceOverride{
+		"TouchBistro/tb-registry/venue-core-service": {
+			Mode:       "build",
+			Entrypoint: []string{},
+			EnvVars:    map[string]string{"LOG_LEVEL": "debug", "FOO": ""},
+			Build:      service.BuildOverride{Target: "dev"},
+		},
+		"TouchBistro/tb-registry/postgres": {Mode: "remote", Entrypoint: []string{}},
+		"TouchBistro/tb-registry/redis":    {Mode: "build"},
+	})
+	is.Equal(cfg.Sources["overrides.TouchBistro/tb-registry/redis"], "profiles.venue-core")
+	is.Equal(cfg.Sources["registries.TouchBistro/tb-registry-team"], homePath)
+
+	// Environment variables and --set flags take priority over the profile.
+	t.Setenv("TB_PLAYLISTS", "{core: {services: [postgres]}}")
+	cfg, err = config.Read(config.ReadOptions{
+		HomeDir:    homedir,
+		WorkingDir: homedir,
+		Settings:   []string{"overrides.TouchBistro/tb-registry/venue-core-service.mode=remote"},
+		Profile:    "venue-core",
+	})
+	is.NoErr(err)
+	is.Equal(cfg.Overrides["TouchBistro/tb-registry/venue-core-service"].Mode, "remote")
+	is.Equal(cfg.Overrides["TouchBistro/tb-registry/venue-core-service"].Build.Target, "dev")
+	is.Equal(cfg.Playlists["core"].Services, []string{"postgres"})
+	is.Equal(cfg.Sources["overrides.TouchBistro/tb-registry/venue-core-service"], "--set")
+
+	// No active profile
+	cfg, err = config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: homedir})
+	is.NoErr(err)
+	is.Equal(cfg.Registries[0].Ref, "v1.0.0")
+	is.Equal(cfg.Overrides["TouchBistro/tb-registry/venue-core-service"].Mode, "remote")
+
+	// Profiles that don't exist are not applied, Init reports them.
+	cfg, err = config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: homedir, Profile: "remote"})
+	is.NoErr(err)
+	is.Equal(cfg.Profile, "remote")
+	is.Equal(cfg.Registries[0].Ref, "v1.0.0")
+}
+
+func TestLoadProfile(t *testing.T) {
+	homedir := t.TempDir()
+	workdir := filepath.Join(homedir, "code")
+	if err := os.MkdirAll(workdir, 0o755); err != nil {
+		t.Fatalf("failed to create dir %s: %v", workdir, err)
+	}
+	homePath := filepath.Join(homedir, ".tbrc.yml")
+	projectPath := filepath.Join(workdir, ".tbrc.yml")
+	files := map[string]string{
+		homePath: `profile: remote
+profiles:
+  remote:
+    overrides:
+      TouchBistro/tb-registry/postgres:
+        mode: remote
+`,
+		projectPath: `profiles:
+  local:
+    registries:
+      - name: TouchBistro/tb-registry
+        localPath: ../tb-registry
+`,
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("failed to write file %s: %v", path, err)
+		}
+	}
+
+	if err := config.TrustFile(projectPath, config.TrustOptions{HomeDir: homedir}); err != nil {
+		t.Fatalf("failed to trust file %s: %v", projectPath, err)
+	}
+
+	is := is.New(t)
+	cfg, err := config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: workdir})
+	is.NoErr(err)
+	is.Equal(cfg.Profile, "remote")
+	is.Equal(cfg.Sources["profile"], homePath)
+	is.Equal(cfg.Sources["profiles.local"], projectPath)
+	// Relative paths in project files are relative to the file.
+	is.Equal(cfg.Profiles["local"].Registries, []registry.Registry{
+		{Name: "TouchBistro/tb-registry", LocalPath: filepath.Join(homedir, "tb-registry")},
+	})
+
+	t.Setenv("TB_PROFILE", "local")
+	cfg, err = config.Read(config.ReadOptions{HomeDir: homedir, WorkingDir: workdir})
+	is.NoErr(err)
+	is.Equal(cfg.Profile, "local")
+	is.Equal(cfg.Sources["profile"], "$TB_PROFILE")
+
+	// The --profile flag takes priority over everything else.
+	cfg, err = config.Read(config.ReadOptions{
+		HomeDir:    homedir,
+		WorkingDir: workdir,
+		Settings:   []string{"profile=local"},
+		Profile:    "remote",
+	})
+	is.NoErr(err)
+	is.Equal(cfg.Profile, "remote")
+	is.Equal(cfg.Sources["profile"], "--profile")
+}
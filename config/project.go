@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TouchBistro/goutils/file"
+	"github.com/TouchBistro/tb/internal/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// findProjectFiles returns the paths of all project tbrc files in workdir and its parents.
+// The search stops at homedir, whose tbrc is not a project tbrc, or at the root of the filesystem.
+// Paths are returned in order of increasing priority, i.e. the file closest to workdir is last.
+func findProjectFiles(workdir, homedir string) ([]string, error) {
+	if workdir == "" {
+		var err error
+		workdir, err = os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+	}
+	dir, err := filepath.Abs(workdir)
+	if err != nil {
+		return nil, err
+	}
+	homedir, err = filepath.Abs(homedir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for dir != homedir {
+		if p := filepath.Join(dir, tbrcName); file.Exists(p) {
+			paths = append([]string{p}, paths...)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return paths, nil
+}
+
+// merge merges the tbrc document node, which was read from the file at path, into root. root is the
+// top level mapping of the settings merged so far and settings in the file take priority over them.
+// The file is recorded in c.Files and the source of each setting it sets in c.Sources. If project is true,
+// relative local paths of registries, including the ones in profiles, are resolved relative to the
+// directory containing the file.
+//
+// Scalar settings are replaced if they are set in the file. Entries of playlists, overrides,
+// profiles, variables, and workspaces are replaced by entries with the same name, and registries are
+// replaced by registries with the same name, otherwise they are added. Settings are merged as yaml nodes
+// so that empty values, ex: entrypoint: [] in an override, are kept as they were written.
+func (c *Config) merge(root, node *yaml.Node, path string, project bool) {
+	c.Files = append(c.Files, path)
+	if c.Sources == nil {
+		c.Sources = make(map[string]string)
+	}
+	if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+	// Copy the file so that changes to the merged settings don't affect it.
+	fileRoot := copyNode(node.Content[0])
+	if project {
+		resolveLocalPaths(fileRoot, path)
+	}
+
+	properties := Schema().Properties
+	for i := 0; i+1 < len(fileRoot.Content); i += 2 {
+		keyNode, value := fileRoot.Content[i], fileRoot.Content[i+1]
+		key := keyNode.Value
+		s, ok := properties[key]
+		if !ok || key == "version" {
+			// Unknown keys are reported by Read in strict mode and the version is set by Read.
+			continue
+		}
+		_, isMap := s.AdditionalProperties.(*jsonschema.Schema)
+		isList := s.Type == "array"
+		if !isMap && !isList {
+			setChild(root, keyNode, value)
+			c.Sources[key] = path
+			continue
+		}
+		if len(value.Content) == 0 {
+			continue
+		}
+		ci := childIndex(root, key)
+		if ci == -1 {
+			root.Content = append(root.Content, keyNode, &yaml.Node{Kind: value.Kind, Tag: value.Tag})
+			ci = len(root.Content) - 1
+		}
+		dst := root.Content[ci]
+		if isMap {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				setChild(dst, value.Content[j], value.Content[j+1])
+				c.Sources[key+"."+value.Content[j].Value] = path
+			}
+			continue
+		}
+		for _, elem := range value.Content {
+			name := entryName(elem)
+			c.Sources[key+"."+name] = path
+			found := false
+			for j, existing := range dst.Content {
+				if entryName(existing) == name {
+					dst.Content[j] = elem
+					found = true
+					break
+				}
+			}
+			if !found {
+				dst.Content = append(dst.Content, elem)
+			}
+		}
+	}
+}
+
+// resolveLocalPaths makes relative local paths of the registries in root, including the ones in profiles,
+// relative to the directory containing the file at path.
+func resolveLocalPaths(root *yaml.Node, path string) {
+	resolve := func(registries *yaml.Node) {
+		if registries == nil || registries.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, r := range registries.Content {
+			i := childIndex(r, "localPath")
+			if i == -1 {
+				continue
+			}
+			lp := r.Content[i]
+			if lp.Kind == yaml.ScalarNode && lp.Value != "" && !filepath.IsAbs(lp.Value) && !strings.HasPrefix(lp.Value, "~") {
+				lp.Value = filepath.Join(filepath.Dir(path), lp.Value)
+			}
+		}
+	}
+	resolve(settingNode(root, []string{"registries"}, nil))
+	profiles := settingNode(root, []string{"profiles"}, nil)
+	if profiles == nil || profiles.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(profiles.Content); i += 2 {
+		resolve(settingNode(profiles.Content[i], []string{"registries"}, nil))
+	}
+}
+
+// setChild sets the value of the key in the mapping m to value. If the key does not exist, it is added.
+func setChild(m, key, value *yaml.Node) {
+	if ci := childIndex(m, key.Value); ci != -1 {
+		m.Content[ci] = value
+		return
+	}
+	m.Content = append(m.Content, key, value)
+}
+
+// copyNode returns a deep copy of n.
+func copyNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
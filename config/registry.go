@@ -0,0 +1,336 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TouchBistro/goutils/color"
+	"github.com/TouchBistro/goutils/errors"
+	"github.com/TouchBistro/goutils/file"
+	"github.com/TouchBistro/goutils/progress"
+	"github.com/TouchBistro/tb/errkind"
+	"github.com/TouchBistro/tb/integrations/git"
+	"github.com/TouchBistro/tb/registry"
+)
+
+// RegistryUpdate describes how a registry was changed by UpdateRegistries.
+type RegistryUpdate struct {
+	// Name is the name of the registry.
+	Name string
+	// OldCommit is the commit the registry was locked to before the update.
+	// It is empty if the registry was not locked.
+	OldCommit string
+	// NewCommit is the commit the registry is now locked to.
+	NewCommit string
+}
+
+// RegistryOptions customizes how UpdateRegistries and ListRegistries work with registries.
+// All fields are optional.
+type RegistryOptions struct {
+	// HomeDir is the home directory used to find the lock file and registries.
+	// If it is empty, it will be resolved from the environment.
+	HomeDir string
+	// GitClient is used to work with the git repos of registries.
+	// Defaults to git.New() if omitted.
+	GitClient git.Git
+}
+
+// UpdateRegistries fetches the latest version of registries, resolves their refs to commits,
+// and updates the lock file to the resolved commits. If registryNames is empty, all registries
+// in config will be updated, otherwise only the named registries will be updated.
+// Local registries are never locked so they are skipped.
+func UpdateRegistries(ctx context.Context, config Config, registryNames []string, opts RegistryOptions) ([]RegistryUpdate, error) {
+	const op = errors.Op("config.UpdateRegistries")
+	homedir, err := opts.homeDir(op)
+	if err != nil {
+		return nil, err
+	}
+
+	update := make(map[string]bool)
+	for _, name := range registryNames {
+		found := false
+		for _, r := range config.Registries {
+			if r.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New(errkind.Invalid, fmt.Sprintf("no such registry %s", name), op)
+		}
+		update[name] = true
+	}
+	if len(update) == 0 {
+		for _, r := range config.Registries {
+			update[r.Name] = true
+		}
+	}
+
+	tbRoot := filepath.Join(homedir, rootDir)
+	if err := resolveRegistryPaths(ctx, config.Registries, homedir, tbRoot, op); err != nil {
+		return nil, err
+	}
+	return syncRegistries(ctx, config.Registries, homedir, syncOptions{update: update, gitClient: opts.GitClient}, op)
+}
+
+func (opts RegistryOptions) homeDir(op errors.Op) (string, error) {
+	if opts.HomeDir != "" {
+		return opts.HomeDir, nil
+	}
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Internal,
+			Reason: "unable to find user home directory",
+			Op:     op,
+		})
+	}
+	return homedir, nil
+}
+
+// RegistryInfo contains details about a registry.
+type RegistryInfo struct {
+	// Name is the name of the registry.
+	Name string
+	// Path is the path to the registry on the local filesystem.
+	Path string
+	// Local is true if the registry is a local registry, i.e. it has a LocalPath.
+	Local bool
+	// Ref is the ref configured for the registry. Empty means the default branch.
+	Ref string
+	// Commit is the commit that is currently checked out.
+	Commit git.Commit
+	// Services, Playlists, and Apps are the number of each resource in the registry.
+	Services  int
+	Playlists int
+	Apps      int
+	// Err is set if the details about the registry could not be determined,
+	// for example if it has not been cloned yet.
+	Err error
+}
+
+// ListRegistries returns details about each registry in config. Errors with individual
+// registries do not cause ListRegistries to fail, instead they are reported in RegistryInfo.Err.
+func ListRegistries(ctx context.Context, config Config, opts RegistryOptions) ([]RegistryInfo, error) {
+	const op = errors.Op("config.ListRegistries")
+	homedir, err := opts.homeDir(op)
+	if err != nil {
+		return nil, err
+	}
+	tbRoot := filepath.Join(homedir, rootDir)
+	if err := resolveRegistryPaths(ctx, config.Registries, homedir, tbRoot, op); err != nil {
+		return nil, err
+	}
+
+	gitClient := opts.GitClient
+	if gitClient == nil {
+		gitClient = git.New()
+	}
+	infos := make([]RegistryInfo, len(config.Registries))
+	for i, r := range config.Registries {
+		infos[i] = RegistryInfo{Name: r.Name, Path: r.Path, Local: r.LocalPath != "", Ref: r.Ref}
+		if !file.Exists(r.Path) {
+			infos[i].Err = errors.New(errkind.Invalid, fmt.Sprintf("registry does not exist at %s", r.Path), op)
+			continue
+		}
+		commit, err := gitClient.HeadCommit(ctx, r.Path)
+		if err != nil && !infos[i].Local {
+			infos[i].Err = err
+			continue
+		}
+		// Local registries don't need to be a git repo, so the commit is only shown if there is one.
+		infos[i].Commit = commit
+		result, err := registry.ReadAll([]registry.Registry{r}, registry.ReadAllOptions{
+			ReadServices: true,
+			ReadApps:     true,
+			HomeDir:      homedir,
+			RootPath:     tbRoot,
+			ReposPath:    filepath.Join(tbRoot, "repos"),
+			Logger:       progress.TrackerFromContext(ctx),
+		})
+		if err != nil {
+			infos[i].Err = err
+			continue
+		}
+		infos[i].Services = result.Services.Len()
+		infos[i].Playlists = len(result.Playlists.Names())
+		infos[i].Apps = result.IOSApps.Len() + result.DesktopApps.Len()
+	}
+	return infos, nil
+}
+
+// resolveRegistryPaths sets the path of each registry to where it is located on the filesystem.
+func resolveRegistryPaths(ctx context.Context, registries []registry.Registry, homedir, tbRoot string, op errors.Op) error {
+	tracker := progress.TrackerFromContext(ctx)
+	for i, r := range registries {
+		// Resolve true registry path
+		if r.LocalPath != "" {
+			// Remind people they are using a local version in case they forgot
+			tracker.Infof("❗ Using a local version of the %s registry ❗", color.Cyan(r.Name))
+
+			// Local paths can be prefixed with ~ for convenience
+			if strings.HasPrefix(r.LocalPath, "~") {
+				r.Path = filepath.Join(homedir, strings.TrimPrefix(r.LocalPath, "~"))
+			} else {
+				path, err := filepath.Abs(r.LocalPath)
+				if err != nil {
+					return errors.Wrap(err, errors.Meta{
+						Kind:   errkind.IO,
+						Reason: fmt.Sprintf("failed to resolve absolute path to local registry %s", r.Name),
+						Op:     op,
+					})
+				}
+				r.Path = path
+			}
+		} else {
+			// If not local, the path will be where the registry is/will be cloned.
+			r.Path = filepath.Join(tbRoot, registriesDir, r.Name)
+		}
+		registries[i] = r
+	}
+	return nil
+}
+
+// syncOptions customizes how syncRegistries syncs registries.
+type syncOptions struct {
+	// update contains the names of the registries to resolve to a new commit.
+	update map[string]bool
+	// noFetch makes registries only use the commits that were already fetched.
+	noFetch bool
+	// gitClient defaults to git.New() if omitted.
+	gitClient git.Git
+}
+
+// syncRegistries makes sure each registry is cloned and checked out at its locked commit.
+// Registries in opts.update, as well as registries whose name and ref are not locked yet, are
+// fetched and resolved to a new commit. If opts.noFetch is set, registries are not fetched and refs are
+// resolved using the commits that were last fetched, missing registries are still cloned.
+// The lock file is updated if any commits changed, entries of other registries are never removed.
+// The returned updates contain each registry that was locked to a new commit.
+func syncRegistries(ctx context.Context, registries []registry.Registry, homedir string, opts syncOptions, op errors.Op) ([]RegistryUpdate, error) {
+	gitClient := opts.gitClient
+	if gitClient == nil {
+		gitClient = git.New()
+	}
+	lock, err := ReadLock(homedir)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.Meta{Reason: "failed to read lock file", Op: op})
+	}
+
+	tracker := progress.TrackerFromContext(ctx)
+	locked := make([]LockedRegistry, len(registries))
+	err = progress.RunParallel(ctx, progress.RunParallelOptions{
+		Message: "Cloning/updating registries",
+		Count:   len(registries),
+	}, func(ctx context.Context, i int) error {
+		r := registries[i]
+		if r.LocalPath != "" {
+			// User's are responsible for local registries so we just assume they are good to go.
+			tracker.Debugf("Skipping local registry %s", r.Name)
+			return nil
+		}
+
+		cloned := false
+		// Clone if missing, otherwise we can't actually use it which would be pretty useless.
+		if !file.Exists(r.Path) {
+			tracker.Debugf("Registry %s is missing, cloning", r.Name)
+			if err := gitClient.Clone(ctx, r.Name, r.Path); err != nil {
+				return errors.Wrap(err, errors.Meta{
+					Reason: fmt.Sprintf("failed to clone registry %s", r.Name),
+					Op:     op,
+				})
+			}
+			cloned = true
+		}
+
+		lr, ok := lock.Registries[lockKey(r.Name, r.Ref)]
+		if ok && !opts.update[r.Name] {
+			tracker.Debugf("Checking out registry %s at locked commit %s", r.Name, lr.Commit)
+			if err := gitClient.Checkout(ctx, r.Path, lr.Commit); err == nil {
+				locked[i] = lr
+				return nil
+			} else if opts.noFetch {
+				return errors.Wrap(err, errors.Meta{
+					Reason: fmt.Sprintf("failed to check out registry %s at locked commit %s without fetching it", r.Name, lr.Commit),
+					Op:     op,
+				})
+			}
+			// The commit might have been pushed after the registry was last fetched, ex: if the
+			// lock file was updated on another machine. Fetch and try again.
+			if err := gitClient.Fetch(ctx, r.Path); err != nil {
+				return errors.Wrap(err, errors.Meta{
+					Reason: fmt.Sprintf("failed to fetch registry %s", r.Name),
+					Op:     op,
+				})
+			}
+			if err := gitClient.Checkout(ctx, r.Path, lr.Commit); err != nil {
+				return errors.Wrap(err, errors.Meta{
+					Reason: fmt.Sprintf("failed to check out registry %s at locked commit %s", r.Name, lr.Commit),
+					Op:     op,
+				})
+			}
+			locked[i] = lr
+			return nil
+		}
+
+		// Registry needs to be resolved to a new commit.
+		if !cloned && !opts.noFetch {
+			tracker.Debugf("Fetching registry %s", r.Name)
+			if err := gitClient.Fetch(ctx, r.Path); err != nil {
+				return errors.Wrap(err, errors.Meta{
+					Reason: fmt.Sprintf("failed to fetch registry %s", r.Name),
+					Op:     op,
+				})
+			}
+		}
+		commit, err := gitClient.ResolveCommit(ctx, r.Path, r.Ref)
+		if err != nil {
+			return errors.Wrap(err, errors.Meta{
+				Reason: fmt.Sprintf("failed to resolve ref of registry %s", r.Name),
+				Op:     op,
+			})
+		}
+		if err := gitClient.Checkout(ctx, r.Path, commit); err != nil {
+			return errors.Wrap(err, errors.Meta{
+				Reason: fmt.Sprintf("failed to check out registry %s at commit %s", r.Name, commit),
+				Op:     op,
+			})
+		}
+		tracker.Debugf("Locked registry %s to commit %s", r.Name, commit)
+		locked[i] = LockedRegistry{Ref: r.Ref, Commit: commit}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.Meta{
+			Reason: "failed to clone/update registries",
+			Op:     op,
+		})
+	}
+
+	// Only add or replace the entries of the synced registries. Entries of registries that are not in
+	// the current config are kept, since the config can depend on the working directory and profile.
+	var updates []RegistryUpdate
+	for i, r := range registries {
+		if r.LocalPath != "" {
+			// Local registries are not locked, any existing entry is kept so it can be used
+			// when the registry is no longer local.
+			continue
+		}
+		key := lockKey(r.Name, r.Ref)
+		old := lock.Registries[key]
+		if old == locked[i] {
+			continue
+		}
+		updates = append(updates, RegistryUpdate{Name: r.Name, OldCommit: old.Commit, NewCommit: locked[i].Commit})
+		lock.Registries[key] = locked[i]
+	}
+	if len(updates) > 0 {
+		if err := WriteLock(homedir, lock); err != nil {
+			return nil, errors.Wrap(err, errors.Meta{Reason: "failed to update lock file", Op: op})
+		}
+	}
+	return updates, nil
+}
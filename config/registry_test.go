@@ -0,0 +1,331 @@
+package config_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/TouchBistro/tb/config"
+	"github.com/TouchBistro/tb/integrations/git"
+	"github.com/TouchBistro/tb/registry"
+	"github.com/matryer/is"
+)
+
+func TestLock(t *testing.T) {
+	is := is.New(t)
+	homedir := t.TempDir()
+
+	// Missing lock file is the same as an empty one.
+	lock, err := config.ReadLock(homedir)
+	is.NoErr(err)
+	is.Equal(len(lock.Registries), 0)
+
+	want := config.Lock{Registries: map[string]config.LockedRegistry{
+		"TouchBistro/tb-registry@v1.2.0": {Ref: "v1.2.0", Commit: "0d4b5b1c8e4b4a3c8cc5ef7b8c4a3e1d2f3a4b5c"},
+		"ExampleZone/tb-registry":        {Commit: "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"},
+	}}
+	is.NoErr(config.WriteLock(homedir, want))
+	lock, err = config.ReadLock(homedir)
+	is.NoErr(err)
+	is.Equal(lock, want)
+
+	// Entries that are only keyed by name are moved to their name@ref key.
+	data := `registries:
+  TouchBistro/tb-registry:
+    ref: v1.2.0
+    commit: 0d4b5b1c8e4b4a3c8cc5ef7b8c4a3e1d2f3a4b5c
+  ExampleZone/tb-registry:
+    commit: a1b2c3d4e5f60718293a4b5c6d7e8f9012345678
+`
+	if err := os.WriteFile(filepath.Join(homedir, ".tb.lock"), []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write lock file: %v", err)
+	}
+	lock, err = config.ReadLock(homedir)
+	is.NoErr(err)
+	is.Equal(lock, want)
+}
+
+func TestUpdateRegistries(t *testing.T) {
+	is := is.New(t)
+	homedir := t.TempDir()
+	const registryName = "TouchBistro/tb-registry"
+	registryPath := filepath.Join(homedir, ".tb", "registries", registryName)
+	gitClient := newFakeGit()
+	gitClient.setRemote(registryName, map[string]string{"": "second", "v1": "first"})
+	opts := config.RegistryOptions{HomeDir: homedir, GitClient: gitClient}
+
+	// Missing registries are cloned and locked to the default branch.
+	ctx := context.Background()
+	cfg := config.Config{Registries: []registry.Registry{{Name: registryName}}}
+	updates, err := config.UpdateRegistries(ctx, cfg, nil, opts)
+	is.NoErr(err)
+	is.Equal(updates, []config.RegistryUpdate{{Name: registryName, NewCommit: "second"}})
+	is.Equal(gitClient.head(registryPath), "second")
+
+	// Each ref is locked separately.
+	cfg.Registries[0].Ref = "v1"
+	updates, err = config.UpdateRegistries(ctx, cfg, []string{registryName}, opts)
+	is.NoErr(err)
+	is.Equal(updates, []config.RegistryUpdate{{Name: registryName, NewCommit: "first"}})
+	is.Equal(gitClient.head(registryPath), "first")
+	lock, err := config.ReadLock(homedir)
+	is.NoErr(err)
+	is.Equal(lock.Registries[registryName+"@v1"], config.LockedRegistry{Ref: "v1", Commit: "first"})
+	is.Equal(lock.Registries[registryName], config.LockedRegistry{Commit: "second"})
+
+	// Nothing to update since the tag didn't move.
+	updates, err = config.UpdateRegistries(ctx, cfg, nil, opts)
+	is.NoErr(err)
+	is.Equal(len(updates), 0)
+
+	// Unknown registries are an error.
+	_, err = config.UpdateRegistries(ctx, cfg, []string{"TouchBistro/missing"}, opts)
+	is.True(err != nil)
+}
+
+func TestInitKeepsLock(t *testing.T) {
+	is := is.New(t)
+	homedir := t.TempDir()
+	t.Setenv("HOME", homedir)
+	const registryName = "TouchBistro/tb-registry"
+	const otherName = "ExampleZone/tb-registry"
+	registryPath := filepath.Join(homedir, ".tb", "registries", registryName)
+	gitClient := newFakeGit()
+	gitClient.setRemote(registryName, map[string]string{"": "first", "v1": "tagged"})
+	gitClient.setRemote(otherName, map[string]string{"": "other"})
+	ctx := context.Background()
+
+	cfg := config.Config{Registries: []registry.Registry{{Name: registryName}}}
+	_, err := config.Init(ctx, cfg, config.InitOptions{GitClient: gitClient})
+	is.NoErr(err)
+	is.Equal(gitClient.head(registryPath), "first")
+
+	// Using a different set of registries, ex: from a profile or project tbrc, doesn't remove the lock of the first one.
+	gitClient.setRemote(registryName, map[string]string{"": "second", "v1": "tagged"})
+	cfg = config.Config{Registries: []registry.Registry{{Name: otherName}}}
+	_, err = config.Init(ctx, cfg, config.InitOptions{GitClient: gitClient})
+	is.NoErr(err)
+	lock, err := config.ReadLock(homedir)
+	is.NoErr(err)
+	is.Equal(lock.Registries[registryName], config.LockedRegistry{Commit: "first"})
+	is.Equal(lock.Registries[otherName], config.LockedRegistry{Commit: "other"})
+
+	// Using a different ref doesn't move the lock of the default branch.
+	cfg = config.Config{Registries: []registry.Registry{{Name: registryName, Ref: "v1"}}}
+	_, err = config.Init(ctx, cfg, config.InitOptions{GitClient: gitClient})
+	is.NoErr(err)
+	is.Equal(gitClient.head(registryPath), "tagged")
+
+	cfg = config.Config{Registries: []registry.Registry{{Name: registryName}}}
+	_, err = config.Init(ctx, cfg, config.InitOptions{GitClient: gitClient})
+	is.NoErr(err)
+	is.Equal(gitClient.head(registryPath), "first")
+	lock, err = config.ReadLock(homedir)
+	is.NoErr(err)
+	is.Equal(len(lock.Registries), 3)
+	is.Equal(lock.Registries[registryName], config.LockedRegistry{Commit: "first"})
+}
+
+func TestInitNoRegistryPull(t *testing.T) {
+	is := is.New(t)
+	homedir := t.TempDir()
+	t.Setenv("HOME", homedir)
+	const registryName = "TouchBistro/tb-registry"
+	registryPath := filepath.Join(homedir, ".tb", "registries", registryName)
+	gitClient := newFakeGit()
+	gitClient.setRemote(registryName, map[string]string{"": "first"})
+
+	// Missing registries are still cloned.
+	ctx := context.Background()
+	cfg := config.Config{Registries: []registry.Registry{{Name: registryName}}}
+	_, err := config.Init(ctx, cfg, config.InitOptions{NoRegistryPull: true, GitClient: gitClient})
+	is.NoErr(err)
+	is.Equal(gitClient.head(registryPath), "first")
+
+	// The registry is not fetched when a new ref is used, the commits that were last fetched are used.
+	gitClient.setRemote(registryName, map[string]string{"": "second", "v1": "first"})
+	cfg.Registries[0].Ref = "v1"
+	_, err = config.Init(ctx, cfg, config.InitOptions{NoRegistryPull: true, GitClient: gitClient})
+	is.True(err != nil) // v1 was never fetched
+	is.Equal(gitClient.fetches, 0)
+
+	// Without the option the registry is fetched.
+	_, err = config.Init(ctx, cfg, config.InitOptions{GitClient: gitClient})
+	is.NoErr(err)
+	is.Equal(gitClient.fetches, 1)
+	is.Equal(gitClient.head(registryPath), "first")
+}
+
+func TestListRegistries(t *testing.T) {
+	is := is.New(t)
+	homedir := t.TempDir()
+	localPath := filepath.Join(t.TempDir(), "tb-registry")
+	if err := os.MkdirAll(localPath, 0o755); err != nil {
+		t.Fatalf("failed to create dir %s: %v", localPath, err)
+	}
+	files := map[string]string{
+		"services.yml": `services:
+  postgres:
+    mode: remote
+    remote:
+      image: postgres
+  redis:
+    mode: remote
+    remote:
+      image: redis
+`,
+		"playlists.yml": `playlists:
+  db:
+    services:
+      - postgres
+`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(localPath, name), []byte(data), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	gitClient := newFakeGit()
+	gitClient.repos[localPath] = &fakeRepo{head: "local"}
+
+	cfg := config.Config{Registries: []registry.Registry{
+		{Name: "TouchBistro/tb-registry", LocalPath: localPath},
+		{Name: "TouchBistro/missing"},
+	}}
+	infos, err := config.ListRegistries(context.Background(), cfg, config.RegistryOptions{HomeDir: homedir, GitClient: gitClient})
+	is.NoErr(err)
+	is.Equal(len(infos), 2)
+	is.Equal(infos[0].Name, "TouchBistro/tb-registry")
+	is.True(infos[0].Local)
+	is.NoErr(infos[0].Err)
+	is.Equal(infos[0].Commit.SHA, "local")
+	is.Equal(infos[0].Services, 2)
+	is.Equal(infos[0].Playlists, 1)
+	is.Equal(infos[0].Apps, 0)
+	// Registry hasn't been cloned.
+	is.True(infos[1].Err != nil)
+}
+
+// fakeGit is a git.Git that keeps repos in memory so that tests don't need git to be installed.
+// Refs are resolved using the refs of the remote as of the last clone or fetch, and any commit that
+// was ever fetched can be checked out.
+type fakeGit struct {
+	git.Git
+	mu      sync.Mutex
+	remotes map[string]map[string]string // repo name -> ref -> commit, "" is the default branch
+	repos   map[string]*fakeRepo         // path -> repo
+	fetches int
+}
+
+type fakeRepo struct {
+	name    string
+	refs    map[string]string
+	commits map[string]bool
+	head    string
+}
+
+func (r *fakeRepo) setRefs(refs map[string]string) {
+	r.refs = copyRefs(refs)
+	if r.commits == nil {
+		r.commits = make(map[string]bool)
+	}
+	for _, commit := range refs {
+		r.commits[commit] = true
+	}
+}
+
+func newFakeGit() *fakeGit {
+	return &fakeGit{remotes: make(map[string]map[string]string), repos: make(map[string]*fakeRepo)}
+}
+
+func (g *fakeGit) setRemote(repo string, refs map[string]string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.remotes[repo] = refs
+}
+
+func (g *fakeGit) head(path string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if r, ok := g.repos[path]; ok {
+		return r.head
+	}
+	return ""
+}
+
+func (g *fakeGit) Clone(ctx context.Context, repo, path string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	refs, ok := g.remotes[repo]
+	if !ok {
+		return fmt.Errorf("no such repo %s", repo)
+	}
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return err
+	}
+	r := &fakeRepo{name: repo, head: refs[""]}
+	r.setRefs(refs)
+	g.repos[path] = r
+	return nil
+}
+
+func (g *fakeGit) Fetch(ctx context.Context, path string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r, ok := g.repos[path]
+	if !ok {
+		return fmt.Errorf("no repo at %s", path)
+	}
+	r.setRefs(g.remotes[r.name])
+	g.fetches++
+	return nil
+}
+
+func (g *fakeGit) Checkout(ctx context.Context, path, ref string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r, ok := g.repos[path]
+	if !ok {
+		return fmt.Errorf("no repo at %s", path)
+	}
+	if !r.commits[ref] {
+		return fmt.Errorf("unknown commit %s", ref)
+	}
+	r.head = ref
+	return nil
+}
+
+func (g *fakeGit) ResolveCommit(ctx context.Context, path, ref string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r, ok := g.repos[path]
+	if !ok {
+		return "", fmt.Errorf("no repo at %s", path)
+	}
+	commit, ok := r.refs[ref]
+	if !ok {
+		return "", fmt.Errorf("unable to resolve ref %q", ref)
+	}
+	return commit, nil
+}
+
+func (g *fakeGit) HeadCommit(ctx context.Context, path string) (git.Commit, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r, ok := g.repos[path]
+	if !ok {
+		return git.Commit{}, fmt.Errorf("no repo at %s", path)
+	}
+	return git.Commit{SHA: r.head}, nil
+}
+
+func copyRefs(refs map[string]string) map[string]string {
+	c := make(map[string]string, len(refs))
+	for k, v := range refs {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/TouchBistro/goutils/errors"
+	"github.com/TouchBistro/tb/errkind"
+	"github.com/TouchBistro/tb/internal/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings are resolved from the following sources, in order of increasing priority:
+//
+//  1. The tbrc in the home directory.
+//  2. Project tbrc files, with files closer to the working directory taking priority.
+//  3. The active profile, see applyProfile.
+//  4. Environment variables, one for each top level setting, ex: TB_EXPERIMENTAL or TB_OVERRIDES.
+//  5. Settings passed to Read, ex: from the --set flag, applied in the order they are given.
+//
+// Environment variables and settings are applied on top of the settings from tbrc files. Their values
+// are yaml. If a value is an object, it is merged with the existing object, which allows changing a single
+// service override through TB_OVERRIDES, otherwise the existing value is replaced.
+//
+// Environment variables and settings that change the profile or profiles settings are applied before
+// the profile, since they determine which profile is active and what it contains. The profile passed
+// to Read takes priority over all of them.
+
+// envPrefix is the prefix of environment variables used to override settings.
+const envPrefix = "TB_"
+
+// envVar returns the name of the environment variable used to override the top level setting
+// with the given key, ex: TB_EXPERIMENTAL for experimental.
+func envVar(key string) string {
+	var sb strings.Builder
+	sb.WriteString(envPrefix)
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			sb.WriteByte('_')
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+	return sb.String()
+}
+
+// settingOverride is a setting that overrides the value from tbrc files.
+type settingOverride struct {
+	key   string
+	value string
+	// source is where the override comes from, ex: $TB_EXPERIMENTAL or --set.
+	source string
+}
+
+// envOverrides returns the overrides set through environment variables.
+func envOverrides() []settingOverride {
+	properties := Schema().Properties
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		// The version describes the format of tbrc files, it isn't a setting.
+		// TB_VERSION is also likely to be used for other purposes, ex: in CI.
+		if key != "version" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var overrides []settingOverride
+	for _, key := range keys {
+		name := envVar(key)
+		if value, ok := os.LookupEnv(name); ok {
+			overrides = append(overrides, settingOverride{key: key, value: value, source: "$" + name})
+		}
+	}
+	return overrides
+}
+
+// resolve applies the active profile, environment variables, and opts.Settings, which are of the form
+// key=value, to root, which is the top level mapping of the settings from tbrc files. The source of each
+// overridden setting is recorded in sources.
+func resolve(op errors.Op, root *yaml.Node, opts ReadOptions, sources map[string]string) error {
+	var profileOverrides, overrides []settingOverride
+	addOverride := func(o settingOverride) {
+		if path, _ := splitSettingKey(o.key); len(path) > 0 && (path[0] == "profile" || path[0] == "profiles") {
+			profileOverrides = append(profileOverrides, o)
+			return
+		}
+		overrides = append(overrides, o)
+	}
+	for _, o := range envOverrides() {
+		addOverride(o)
+	}
+	for _, s := range opts.Settings {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok {
+			msg := fmt.Sprintf("invalid setting %q, must be of the form key=value", s)
+			return errors.New(errkind.Invalid, msg, op)
+		}
+		addOverride(settingOverride{key: key, value: value, source: "--set"})
+	}
+
+	if err := applyOverrides(op, root, profileOverrides, sources); err != nil {
+		return err
+	}
+	if opts.Profile != "" {
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "profile"}
+		setChild(root, keyNode, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: opts.Profile})
+		sources["profile"] = "--profile"
+	}
+	applyProfile(root, sources)
+	return applyOverrides(op, root, overrides, sources)
+}
+
+// applyOverrides applies each override in overrides to root in order.
+func applyOverrides(op errors.Op, root *yaml.Node, overrides []settingOverride, sources map[string]string) error {
+	for _, o := range overrides {
+		if err := applyOverride(op, root, o, sources); err != nil {
+			return errors.Wrap(err, errors.Meta{Reason: o.source, Op: op})
+		}
+	}
+	return nil
+}
+
+// applyOverride applies o to the settings in root and records the source of each setting it changes in sources.
+func applyOverride(op errors.Op, root *yaml.Node, o settingOverride, sources map[string]string) error {
+	path, schema, err := parseSettingKey(op, o.key)
+	if err != nil {
+		return err
+	}
+	valueNode, err := parseSettingValue(op, o.key, o.value, schema)
+	if err != nil {
+		return err
+	}
+	n := settingNode(root, path, settingArrays(path))
+	if n == nil {
+		return errors.New(errkind.Invalid, fmt.Sprintf("cannot set %s", o.key), op)
+	}
+	mergeNode(n, valueNode)
+	if len(path) == 1 && valueNode.Kind != yaml.MappingNode {
+		// All entries were replaced.
+		for key := range sources {
+			if strings.HasPrefix(key, path[0]+".") {
+				delete(sources, key)
+			}
+		}
+	}
+
+	// Make sure the new value has the right type.
+	if err := root.Decode(&Config{}); err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Invalid,
+			Reason: fmt.Sprintf("invalid value for %s", o.key),
+			Op:     op,
+		})
+	}
+	for _, key := range overrideSources(root, path, valueNode) {
+		sources[key] = o.source
+	}
+	return nil
+}
+
+// mergeNode merges src into dst. If both are mappings, each entry of src is merged into the entry of dst
+// with the same key, or added if there is none. Otherwise dst is replaced by src.
+func mergeNode(dst, src *yaml.Node) {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		*dst = *src
+		return
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		if ci := childIndex(dst, src.Content[i].Value); ci != -1 {
+			mergeNode(dst.Content[ci], src.Content[i+1])
+			continue
+		}
+		dst.Content = append(dst.Content, src.Content[i], src.Content[i+1])
+	}
+}
+
+// overrideSources returns the keys of Config.Sources for the settings changed by setting
+// the value at path in root to valueNode.
+func overrideSources(root *yaml.Node, path []string, valueNode *yaml.Node) []string {
+	top := path[0]
+	s, err := jsonschema.Lookup(Schema(), path[:1])
+	if err != nil {
+		return []string{top}
+	}
+	_, isMap := s.AdditionalProperties.(*jsonschema.Schema)
+	isList := s.Type == "array"
+	if !isMap && !isList {
+		return []string{top}
+	}
+	if len(path) > 1 {
+		if isMap {
+			return []string{top + "." + path[1]}
+		}
+		// Entries of lists are identified by their name, see Config.Sources.
+		if name := entryName(settingNode(root, path[:2], nil)); name != "" {
+			return []string{top + "." + name}
+		}
+		return nil
+	}
+
+	var keys []string
+	switch valueNode.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(valueNode.Content); i += 2 {
+			keys = append(keys, top+"."+valueNode.Content[i].Value)
+		}
+	case yaml.SequenceNode:
+		for _, n := range valueNode.Content {
+			if name := entryName(n); name != "" {
+				keys = append(keys, top+"."+name)
+			}
+		}
+	}
+	return keys
+}
+
+// entryName returns the name of the list entry n. If n is nil or has no name, an empty string is returned.
+func entryName(n *yaml.Node) string {
+	if n == nil {
+		return ""
+	}
+	if i := childIndex(n, "name"); i != -1 {
+		return n.Content[i].Value
+	}
+	return ""
+}
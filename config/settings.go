@@ -0,0 +1,433 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/TouchBistro/goutils/errors"
+	"github.com/TouchBistro/tb/errkind"
+	"github.com/TouchBistro/tb/internal/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrSettingNotFound indicates that a setting is not set.
+var ErrSettingNotFound errors.String = "setting not found"
+
+// Settings are identified by keys, which are paths of yaml keys separated by dots, ex: experimental or
+// overrides.TouchBistro/tb-registry/postgres.remote.tag. Elements of lists are identified by their index,
+// or for lists of objects with a name, like registries, by their name, ex: registries.TouchBistro/tb-registry.ref.
+// Elements that contain dots must be quoted with double quotes, ex: registries."ExampleZone/tb-registry.v2".ref.
+
+// EditOptions customizes which tbrc file is edited by SetSetting and UnsetSetting.
+// All fields are optional.
+type EditOptions struct {
+	// HomeDir is the home directory containing the main tbrc.
+	// If it is empty, it will be resolved from the environment.
+	HomeDir string
+	// File is the path of the tbrc file to edit. If it is empty, the tbrc in HomeDir is edited.
+	File string
+}
+
+// EncodeSettings encodes config to a yaml node. Settings that are not set,
+// i.e. that have an empty value, are omitted.
+func EncodeSettings(config Config) (*yaml.Node, error) {
+	const op = errors.Op("config.EncodeSettings")
+	var root yaml.Node
+	if err := root.Encode(config); err != nil {
+		return nil, errors.Wrap(err, errors.Meta{Kind: errkind.Internal, Reason: "failed to encode config", Op: op})
+	}
+	pruneEmpty(&root)
+	return &root, nil
+}
+
+// GetSetting returns the value of the setting with the given key in config.
+// If the setting is not set, ErrSettingNotFound is returned.
+func GetSetting(config Config, key string) (*yaml.Node, error) {
+	const op = errors.Op("config.GetSetting")
+	path, _, err := parseSettingKey(op, key)
+	if err != nil {
+		return nil, err
+	}
+	root, err := EncodeSettings(config)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.Meta{Op: op})
+	}
+	n := settingNode(root, path, nil)
+	if n == nil {
+		return nil, errors.Wrap(ErrSettingNotFound, errors.Meta{Kind: errkind.Invalid, Reason: key, Op: op})
+	}
+	return n, nil
+}
+
+// SetSetting sets the setting with the given key to value in a tbrc file. value is parsed as yaml,
+// which allows setting lists and objects, ex: [a, b]. The value is validated before the file is changed.
+// Any settings that are part of key that don't exist yet are created. Comments in the file are preserved.
+func SetSetting(key, value string, opts EditOptions) error {
+	const op = errors.Op("config.SetSetting")
+	path, schema, err := parseSettingKey(op, key)
+	if err != nil {
+		return err
+	}
+	valueNode, err := parseSettingValue(op, key, value, schema)
+	if err != nil {
+		return err
+	}
+	return editSettings(op, opts, true, func(doc *yaml.Node) error {
+		n := settingNode(doc, path, settingArrays(path))
+		if n == nil {
+			return errors.New(errkind.Invalid, fmt.Sprintf("cannot set %s", key), op)
+		}
+		// Keep any comments attached to the existing value.
+		head, line, foot := n.HeadComment, n.LineComment, n.FootComment
+		*n = *valueNode
+		n.HeadComment, n.LineComment, n.FootComment = head, line, foot
+
+		// Make sure the new value has the right type by decoding the whole file.
+		var config Config
+		if err := doc.Decode(&config); err != nil {
+			return errors.Wrap(err, errors.Meta{
+				Kind:   errkind.Invalid,
+				Reason: fmt.Sprintf("invalid value for %s", key),
+				Op:     op,
+			})
+		}
+		return nil
+	})
+}
+
+// UnsetSetting removes the setting with the given key from a tbrc file. Comments in the file are preserved.
+// If the setting is not set in the file, ErrSettingNotFound is returned.
+func UnsetSetting(key string, opts EditOptions) error {
+	const op = errors.Op("config.UnsetSetting")
+	path, _, err := parseSettingKey(op, key)
+	if err != nil {
+		return err
+	}
+	// There is nothing to remove from a file that doesn't exist so don't create it.
+	return editSettings(op, opts, false, func(doc *yaml.Node) error {
+		notFoundErr := errors.Wrap(ErrSettingNotFound, errors.Meta{Kind: errkind.Invalid, Reason: key, Op: op})
+		parent := settingNode(doc, path[:len(path)-1], nil)
+		if parent == nil {
+			return notFoundErr
+		}
+		i := childIndex(parent, path[len(path)-1])
+		switch {
+		case i == -1:
+			return notFoundErr
+		case parent.Kind == yaml.MappingNode:
+			parent.Content = append(parent.Content[:i-1], parent.Content[i+1:]...)
+		default:
+			parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+		}
+		return nil
+	})
+}
+
+// parseSettingKey splits key into its path and makes sure it is a valid setting.
+// It returns the schema of the setting.
+func parseSettingKey(op errors.Op, key string) ([]string, *jsonschema.Schema, error) {
+	path, ok := splitSettingKey(key)
+	if !ok {
+		return nil, nil, errors.New(errkind.Invalid, fmt.Sprintf("invalid key %q", key), op)
+	}
+	schema, err := jsonschema.Lookup(Schema(), path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Invalid,
+			Reason: fmt.Sprintf("invalid key %q", key),
+			Op:     op,
+		})
+	}
+	return path, schema, nil
+}
+
+// splitSettingKey splits key into the elements of its path, which are separated by dots.
+// Elements can be quoted with double quotes to include dots, ex: the name of a registry like
+// registries."ExampleZone/tb-registry.v2".ref. It returns false if the key has an empty element
+// or an invalid quote, ex: one that is not closed.
+func splitSettingKey(key string) ([]string, bool) {
+	var path []string
+	rest := key
+	for {
+		var elem string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end == -1 {
+				return nil, false
+			}
+			elem, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexByte(rest, '.')
+			if end == -1 {
+				end = len(rest)
+			}
+			elem, rest = rest[:end], rest[end:]
+		}
+		if elem == "" {
+			return nil, false
+		}
+		path = append(path, elem)
+		if rest == "" {
+			return path, true
+		}
+		if rest[0] != '.' {
+			// Text after a closing quote.
+			return nil, false
+		}
+		rest = rest[1:]
+	}
+}
+
+// parseSettingValue parses value, which is yaml, and makes sure it is valid for the setting
+// with the given key and schema. It returns the node of the value.
+func parseSettingValue(op errors.Op, key, value string, schema *jsonschema.Schema) (*yaml.Node, error) {
+	var valueDoc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &valueDoc); err != nil {
+		return nil, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Invalid,
+			Reason: fmt.Sprintf("invalid value for %s", key),
+			Op:     op,
+		})
+	}
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	if len(valueDoc.Content) > 0 {
+		valueNode = valueDoc.Content[0]
+	}
+	if uks := jsonschema.FindUnknownKeys(schema, valueNode); len(uks) > 0 {
+		return nil, errors.Wrap(uks[0], errors.Meta{
+			Kind:   errkind.Invalid,
+			Reason: fmt.Sprintf("invalid value for %s", key),
+			Op:     op,
+		})
+	}
+	if isStringSchema(schema) && valueNode.Kind == yaml.ScalarNode && valueNode.Tag != "!!null" {
+		// Make sure values like 14 or true are written as strings.
+		valueNode.Tag = "!!str"
+	}
+	if len(schema.Enum) > 0 && valueNode.Kind == yaml.ScalarNode {
+		valid := false
+		for _, e := range schema.Enum {
+			if e == valueNode.Value {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			msg := fmt.Sprintf("invalid value %q for %s, must be one of: %s", valueNode.Value, key, strings.Join(schema.Enum, ", "))
+			return nil, errors.New(errkind.Invalid, msg, op)
+		}
+	}
+	return valueNode, nil
+}
+
+// isStringSchema reports whether s is the schema of a string value.
+func isStringSchema(s *jsonschema.Schema) bool {
+	if s.Type == "string" {
+		return true
+	}
+	types, ok := s.Type.([]string)
+	return ok && len(types) > 0 && types[0] == "string"
+}
+
+// settingArrays returns whether the value at each prefix of path is a list.
+// It is used to know what kind of node to create for settings that don't exist yet.
+func settingArrays(path []string) []bool {
+	arrays := make([]bool, len(path))
+	for i := range path {
+		if s, err := jsonschema.Lookup(Schema(), path[:i]); err == nil {
+			arrays[i] = s.Type == "array"
+		}
+	}
+	return arrays
+}
+
+// editSettings reads the tbrc file specified by opts, calls edit with the document node
+// and then writes the changed document back to the file. If create is true, the file is
+// created if it does not exist, otherwise an error is returned.
+func editSettings(op errors.Op, opts EditOptions, create bool, edit func(doc *yaml.Node) error) error {
+	tbrcPath, err := tbrcFile(op, opts.HomeDir, opts.File)
+	if err != nil {
+		return err
+	}
+
+	// Read into a node to preserve comments, same as AddRegistry.
+	flag := os.O_RDWR
+	if create {
+		flag |= os.O_CREATE
+	}
+	f, err := os.OpenFile(tbrcPath, flag, 0o644)
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to open file %s", tbrcPath),
+			Op:     op,
+		})
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to read file %s", tbrcPath),
+			Op:     op,
+		})
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode}
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(doc); err != nil && err != io.EOF {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("couldn't read yaml file at %s", tbrcPath),
+			Op:     op,
+		})
+	}
+	if err := edit(doc); err != nil {
+		return err
+	}
+	if err := overwriteYamlFile(f, doc); err != nil {
+		return errors.Wrap(err, errors.Meta{Op: op})
+	}
+	// Editing a trusted project tbrc with tb shouldn't make it untrusted.
+	if opts.File != "" {
+		return retrustFile(op, opts.HomeDir, tbrcPath, data)
+	}
+	return nil
+}
+
+// tbrcFile returns the path of the tbrc file to change. If file is empty, it is the tbrc in homedir.
+// If homedir is also empty, it will be resolved from the environment.
+func tbrcFile(op errors.Op, homedir, file string) (string, error) {
+	if file != "" {
+		return file, nil
+	}
+	if homedir == "" {
+		var err error
+		homedir, err = os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, errors.Meta{
+				Kind:   errkind.Internal,
+				Reason: "unable to find user home directory",
+				Op:     op,
+			})
+		}
+	}
+	return filepath.Join(homedir, tbrcName), nil
+}
+
+// settingNode returns the value node at path in n. If arrays is not nil, any missing nodes along
+// the path are created, where arrays specifies if the node at each prefix of path is a list.
+// If the node does not exist, nil is returned.
+func settingNode(n *yaml.Node, path []string, arrays []bool) *yaml.Node {
+	create := arrays != nil
+	if n.Kind == yaml.DocumentNode {
+		if len(n.Content) == 0 {
+			if !create {
+				return nil
+			}
+			n.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+		}
+		n = n.Content[0]
+	}
+	for i, elem := range path {
+		if create && n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+			// Key without a value, ex: 'overrides:', same as in AddRegistry.
+			n.Kind, n.Tag, n.Value = yaml.MappingNode, "!!map", ""
+			if arrays[i] {
+				n.Kind, n.Tag = yaml.SequenceNode, "!!seq"
+			}
+		}
+		ci := childIndex(n, elem)
+		if ci != -1 {
+			n = n.Content[ci]
+			continue
+		}
+		if !create {
+			return nil
+		}
+		child := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+		switch n.Kind {
+		case yaml.MappingNode:
+			key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: elem}
+			n.Content = append(n.Content, key, child)
+		case yaml.SequenceNode:
+			if _, err := strconv.Atoi(elem); err == nil {
+				// Can't create an element at an index that doesn't exist.
+				return nil
+			}
+			// Lists of objects are identified by their name.
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: "name"},
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: elem},
+			}}
+			n.Content = append(n.Content, child)
+		default:
+			return nil
+		}
+		n = child
+	}
+	return n
+}
+
+// pruneEmpty removes entries of mappings in n that have an empty value, i.e. null,
+// an empty string, or an empty mapping or sequence. It reports whether n itself is empty.
+func pruneEmpty(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			pruneEmpty(c)
+		}
+		return false
+	case yaml.MappingNode:
+		content := n.Content[:0]
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if !pruneEmpty(n.Content[i+1]) {
+				content = append(content, n.Content[i], n.Content[i+1])
+			}
+		}
+		n.Content = content
+		return len(n.Content) == 0
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			pruneEmpty(c)
+		}
+		return len(n.Content) == 0
+	case yaml.ScalarNode:
+		return n.Tag == "!!null" || (n.Tag == "!!str" && n.Value == "")
+	}
+	return false
+}
+
+// childIndex returns the index in n.Content of the value node identified by elem.
+// For mappings elem is a key, for sequences it is an index or the name of an element.
+// If there is no such node, -1 is returned.
+func childIndex(n *yaml.Node, elem string) int {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == elem {
+				return i + 1
+			}
+		}
+	case yaml.SequenceNode:
+		if i, err := strconv.Atoi(elem); err == nil {
+			if i >= 0 && i < len(n.Content) {
+				return i
+			}
+			return -1
+		}
+		for i, c := range n.Content {
+			if c.Kind != yaml.MappingNode {
+				continue
+			}
+			if ni := childIndex(c, "name"); ni != -1 && c.Content[ni].Value == elem {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,247 @@
+package config_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TouchBistro/goutils/errors"
+	"github.com/TouchBistro/tb/config"
+	"github.com/TouchBistro/tb/registry"
+	"github.com/TouchBistro/tb/resource/service"
+	"github.com/matryer/is"
+)
+
+func TestGetSetting(t *testing.T) {
+	cfg := config.Config{
+		ExperimentalMode: true,
+		Registries:       []registry.Registry{{Name: "TouchBistro/tb-registry", Ref: "v1.2.0"}},
+		Overrides: map[string]service.ServiceOverride{
+			"TouchBistro/tb-registry/postgres": {Remote: service.RemoteOverride{Tag: "14-alpine"}},
+		},
+	}
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr error
+	}{
+		{"scalar", "experimental", "true", nil},
+		{"override", "overrides.TouchBistro/tb-registry/postgres.remote.tag", "14-alpine", nil},
+		{"registry by name", "registries.TouchBistro/tb-registry.ref", "v1.2.0", nil},
+		{"registry by index", "registries.0.name", "TouchBistro/tb-registry", nil},
+		{"not set", "overrides.TouchBistro/tb-registry/redis.mode", "", config.ErrSettingNotFound},
+		{"empty", "overrides.TouchBistro/tb-registry/postgres.remote.command", "", config.ErrSettingNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			n, err := config.GetSetting(cfg, tt.key)
+			if tt.wantErr != nil {
+				is.True(errors.Is(err, tt.wantErr))
+				return
+			}
+			is.NoErr(err)
+			is.Equal(n.Value, tt.want)
+		})
+	}
+}
+
+func TestSetSetting(t *testing.T) {
+	tests := []struct {
+		name  string
+		tbrc  string
+		key   string
+		value string
+		want  string
+	}{
+		{
+			name: "replace scalar",
+			tbrc: `# Toggle experimental mode
+experimental: false # not yet
+`,
+			key:   "experimental",
+			value: "true",
+			want: `# Toggle experimental mode
+experimental: true # not yet
+`,
+		},
+		{
+			name: "create nested",
+			tbrc: `experimental: false
+# Override service configuration
+overrides:
+`,
+			key:   "overrides.TouchBistro/tb-registry/postgres.remote.tag",
+			value: "14-alpine",
+			want: `experimental: false
+# Override service configuration
+overrides:
+  TouchBistro/tb-registry/postgres:
+    remote:
+      tag: 14-alpine
+`,
+		},
+		{
+			name: "registry by name",
+			tbrc: `registries:
+  - name: TouchBistro/tb-registry
+`,
+			key:   "registries.ExampleZone/tb-registry.ref",
+			value: "main",
+			want: `registries:
+  - name: TouchBistro/tb-registry
+  - name: ExampleZone/tb-registry
+    ref: main
+`,
+		},
+		{
+			name: "string value",
+			tbrc: `registries:
+  - name: TouchBistro/tb-registry
+    ref: main
+`,
+			key:   "registries.TouchBistro/tb-registry.ref",
+			value: "2",
+			want: `registries:
+  - name: TouchBistro/tb-registry
+    ref: "2"
+`,
+		},
+		{
+			name:  "empty file",
+			key:   "workspaces.feature-x.portOffset",
+			value: "100",
+			want: `workspaces:
+  feature-x:
+    portOffset: 100
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			tbrcPath := filepath.Join(t.TempDir(), ".tbrc.yml")
+			err := os.WriteFile(tbrcPath, []byte(tt.tbrc), 0o644)
+			is.NoErr(err)
+
+			err = config.SetSetting(tt.key, tt.value, config.EditOptions{File: tbrcPath})
+			is.NoErr(err)
+			data, err := os.ReadFile(tbrcPath)
+			is.NoErr(err)
+			is.Equal(string(data), tt.want)
+		})
+	}
+}
+
+func TestSetSettingInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"unknown key", "experimentl", "true"},
+		{"unknown nested key", "overrides.TouchBistro/tb-registry/postgres.remote.tagg", "latest"},
+		{"invalid enum", "overrides.TouchBistro/tb-registry/postgres.mode", "local"},
+		{"invalid type", "workspaces.feature-x.portOffset", "lots"},
+		{"unknown key in value", "overrides.TouchBistro/tb-registry/postgres", "{mod: remote}"},
+		{"empty key element", "overrides..mode", "remote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			tbrc := "experimental: false\n"
+			tbrcPath := filepath.Join(t.TempDir(), ".tbrc.yml")
+			err := os.WriteFile(tbrcPath, []byte(tbrc), 0o644)
+			is.NoErr(err)
+
+			err = config.SetSetting(tt.key, tt.value, config.EditOptions{File: tbrcPath})
+			is.True(err != nil)
+			// File is not changed
+			data, err := os.ReadFile(tbrcPath)
+			is.NoErr(err)
+			is.Equal(string(data), tbrc)
+		})
+	}
+}
+
+func TestUnsetSetting(t *testing.T) {
+	is := is.New(t)
+	tbrcPath := filepath.Join(t.TempDir(), ".tbrc.yml")
+	err := os.WriteFile(tbrcPath, []byte(`experimental: true
+registries:
+  - name: TouchBistro/tb-registry
+  - name: ExampleZone/tb-registry
+# Override service configuration
+overrides:
+  TouchBistro/tb-registry/postgres:
+    mode: remote
+    remote:
+      tag: 14-alpine
+`), 0o644)
+	is.NoErr(err)
+
+	opts := config.EditOptions{File: tbrcPath}
+	is.NoErr(config.UnsetSetting("experimental", opts))
+	is.NoErr(config.UnsetSetting("registries.ExampleZone/tb-registry", opts))
+	is.NoErr(config.UnsetSetting("overrides.TouchBistro/tb-registry/postgres.remote", opts))
+	err = config.UnsetSetting("overrides.TouchBistro/tb-registry/redis", opts)
+	is.True(errors.Is(err, config.ErrSettingNotFound))
+
+	data, err := os.ReadFile(tbrcPath)
+	is.NoErr(err)
+	is.Equal(string(data), `registries:
+  - name: TouchBistro/tb-registry
+# Override service configuration
+overrides:
+  TouchBistro/tb-registry/postgres:
+    mode: remote
+`)
+}
+
+func TestUnsetSettingMissingFile(t *testing.T) {
+	is := is.New(t)
+	tbrcPath := filepath.Join(t.TempDir(), ".tbrc.yml")
+	err := config.UnsetSetting("experimental", config.EditOptions{File: tbrcPath})
+	is.True(errors.Is(err, fs.ErrNotExist))
+	_, err = os.Stat(tbrcPath)
+	is.True(errors.Is(err, fs.ErrNotExist)) // file must not be created
+}
+
+func TestSettingKeyQuoted(t *testing.T) {
+	is := is.New(t)
+	tbrcPath := filepath.Join(t.TempDir(), ".tbrc.yml")
+	err := os.WriteFile(tbrcPath, []byte("registries:\n  - name: ExampleZone/tb-registry.v2\n"), 0o644)
+	is.NoErr(err)
+
+	opts := config.EditOptions{File: tbrcPath}
+	is.NoErr(config.SetSetting(`registries."ExampleZone/tb-registry.v2".ref`, "v1.0.0", opts))
+	is.NoErr(config.SetSetting(`overrides."ExampleZone/tb-registry.v2/postgres".remote.tag`, "14", opts))
+	data, err := os.ReadFile(tbrcPath)
+	is.NoErr(err)
+	is.Equal(string(data), `registries:
+  - name: ExampleZone/tb-registry.v2
+    ref: v1.0.0
+overrides:
+  ExampleZone/tb-registry.v2/postgres:
+    remote:
+      tag: "14"
+`)
+
+	cfg, err := config.Read(config.ReadOptions{
+		HomeDir:    filepath.Dir(tbrcPath),
+		WorkingDir: filepath.Dir(tbrcPath),
+		Settings:   []string{`registries."ExampleZone/tb-registry.v2".ref=v2.0.0`},
+	})
+	is.NoErr(err)
+	is.Equal(cfg.Registries[0].Ref, "v2.0.0")
+	n, err := config.GetSetting(cfg, `overrides."ExampleZone/tb-registry.v2/postgres".remote.tag`)
+	is.NoErr(err)
+	is.Equal(n.Value, "14")
+
+	for _, key := range []string{`registries."ExampleZone/tb-registry.v2.ref`, `registries."ExampleZone"v2.ref`, `registries."".ref`, "registries..ref", "experimental."} {
+		err = config.SetSetting(key, "v1.0.0", opts)
+		is.True(err != nil) // invalid key
+	}
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/TouchBistro/goutils/errors"
+	"github.com/TouchBistro/goutils/file"
+	"github.com/TouchBistro/tb/errkind"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrFileNotTrusted indicates that a project tbrc file has not been trusted.
+var ErrFileNotTrusted errors.String = "file not trusted"
+
+// trustName is the name of the file in the tb root dir that records which project tbrc files are trusted.
+const trustName = "trusted.yml"
+
+// Project tbrc files can add registries and overrides, which control what is run on the machine,
+// so a project tbrc is only used once the user has explicitly trusted it, similar to 'direnv allow'.
+// This prevents cloning a repo and running tb in it from running arbitrary containers.
+// A hash of the contents is recorded when a file is trusted, so a file needs to be trusted again
+// after it changes, ex: when a 'git pull' changed it.
+
+type trustFile struct {
+	// Files maps the absolute paths of the trusted project tbrc files to the SHA-256 hash of their contents.
+	Files map[string]string `yaml:"files"`
+}
+
+// TrustOptions customizes where trusted project tbrc files are recorded.
+// All fields are optional.
+type TrustOptions struct {
+	// HomeDir is the home directory containing the main tbrc.
+	// If it is empty, it will be resolved from the environment.
+	HomeDir string
+}
+
+// TrustFile marks the current contents of the project tbrc at path as trusted so that Read will use it.
+// If the file changes, it is no longer trusted until TrustFile is called again.
+func TrustFile(path string, opts TrustOptions) error {
+	const op = errors.Op("config.TrustFile")
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{Kind: errkind.Internal, Op: op})
+	}
+	if !file.Exists(path) {
+		return errors.New(errkind.Invalid, fmt.Sprintf("%s does not exist", path), op)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to read file %s", path),
+			Op:     op,
+		})
+	}
+	trustPath, err := trustFilePath(op, opts.HomeDir)
+	if err != nil {
+		return err
+	}
+	trusted, err := readTrustFile(op, trustPath)
+	if err != nil {
+		return err
+	}
+	if trusted.trusts(path, data) {
+		return nil
+	}
+	trusted.Files[path] = hashContents(data)
+	return writeTrustFile(op, trustPath, trusted)
+}
+
+// UntrustFile removes the project tbrc at path from the trusted files so that Read will no longer use it.
+// If the file is not trusted, ErrFileNotTrusted is returned.
+func UntrustFile(path string, opts TrustOptions) error {
+	const op = errors.Op("config.UntrustFile")
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{Kind: errkind.Internal, Op: op})
+	}
+	trustPath, err := trustFilePath(op, opts.HomeDir)
+	if err != nil {
+		return err
+	}
+	trusted, err := readTrustFile(op, trustPath)
+	if err != nil {
+		return err
+	}
+	if _, ok := trusted.Files[path]; ok {
+		delete(trusted.Files, path)
+		return writeTrustFile(op, trustPath, trusted)
+	}
+	return errors.Wrap(ErrFileNotTrusted, errors.Meta{
+		Kind:   errkind.Invalid,
+		Reason: path,
+		Op:     op,
+	})
+}
+
+// retrustFile keeps the project tbrc at path trusted after tb changed it, ex: with 'tb config set --file'.
+// before is the contents of the file before it was changed, if those weren't trusted nothing is done.
+func retrustFile(op errors.Op, homedir, path string, before []byte) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{Kind: errkind.Internal, Op: op})
+	}
+	trustPath, err := trustFilePath(op, homedir)
+	if err != nil {
+		return err
+	}
+	trusted, err := readTrustFile(op, trustPath)
+	if err != nil {
+		return err
+	}
+	if !trusted.trusts(path, before) {
+		return nil
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to read file %s", path),
+			Op:     op,
+		})
+	}
+	trusted.Files[path] = hashContents(after)
+	return writeTrustFile(op, trustPath, trusted)
+}
+
+// trusts reports whether the file at path is trusted with the given contents.
+func (t trustFile) trusts(path string, data []byte) bool {
+	hash, ok := t.Files[path]
+	return ok && hash == hashContents(data)
+}
+
+func hashContents(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func trustFilePath(op errors.Op, homedir string) (string, error) {
+	if homedir == "" {
+		var err error
+		homedir, err = os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, errors.Meta{
+				Kind:   errkind.Internal,
+				Reason: "unable to find user home directory",
+				Op:     op,
+			})
+		}
+	}
+	return filepath.Join(homedir, rootDir, trustName), nil
+}
+
+// readTrustFile reads the trusted files recorded at path. If the file does not exist, no files are trusted.
+func readTrustFile(op errors.Op, path string) (trustFile, error) {
+	trusted := trustFile{Files: make(map[string]string)}
+	if !file.Exists(path) {
+		return trusted, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return trusted, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to read file %s", path),
+			Op:     op,
+		})
+	}
+	if err := yaml.Unmarshal(b, &trusted); err != nil {
+		return trusted, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Invalid,
+			Reason: fmt.Sprintf("couldn't read yaml file at %s", path),
+			Op:     op,
+		})
+	}
+	if trusted.Files == nil {
+		trusted.Files = make(map[string]string)
+	}
+	return trusted, nil
+}
+
+func writeTrustFile(op errors.Op, path string, trusted trustFile) error {
+	var buf bytes.Buffer
+	buf.WriteString("# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY\n")
+	buf.WriteString("# Use 'tb config trust' and 'tb config untrust' to change the trusted project tbrc files.\n\n")
+	if err := yaml.NewEncoder(&buf).Encode(trusted); err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Internal,
+			Reason: "failed to encode trusted files",
+			Op:     op,
+		})
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to create directory %s", filepath.Dir(path)),
+			Op:     op,
+		})
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to write file %s", path),
+			Op:     op,
+		})
+	}
+	return nil
+}
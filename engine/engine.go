@@ -1,8 +1,10 @@
 package engine
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 
 	"github.com/TouchBistro/goutils/errors"
 	"github.com/TouchBistro/tb/errkind"
@@ -19,6 +21,9 @@ import (
 // Engine provides the API for performing actions on services, playlists, and apps.
 type Engine struct {
 	workdir          string // Path to root dir where data is stored
+	workspace        string
+	composeDir       string // Path to dir where the compose file for the workspace is stored
+	portOffset       int
 	experimentalMode bool
 	services         *resource.Collection[service.Service]
 	playlists        *playlist.Collection
@@ -39,6 +44,14 @@ type Options struct {
 	// Workdir is the working directory on the OS filesystem where the engine can store data.
 	// Defaults to ~/.tb if omitted.
 	Workdir string
+	// Workspace is the name of the workspace to use. Each workspace is an isolated set of
+	// containers, networks, and volumes with its own compose project, allowing multiple
+	// copies of services to be run side by side. Git repos, apps, and registries are shared
+	// between workspaces. If omitted, the default workspace is used.
+	Workspace string
+	// PortOffset is added to each host port published by services. It allows services in
+	// different workspaces to run at the same time without their ports conflicting.
+	PortOffset int
 	// ExperimentalMode controls if experimental mode is enabled, which gives access
 	// to new features that aren't generally available.
 	ExperimentalMode bool
@@ -76,7 +89,6 @@ type Options struct {
 // New creates a new Engine instance.
 func New(opts Options) (*Engine, error) {
 	const op = errors.Op("engine.New")
-	const projectName = "tb"
 
 	// Set defaults
 	if opts.Workdir == "" {
@@ -94,17 +106,47 @@ func New(opts Options) (*Engine, error) {
 		opts.Playlists = &playlist.Collection{}
 	}
 
+	// Resolve the workspace. The default workspace uses the workdir directly for backwards compatibility.
+	projectName := defaultProjectName
+	composeDir := opts.Workdir
+	if opts.Workspace != "" {
+		if !workspaceNameRegex.MatchString(opts.Workspace) {
+			msg := fmt.Sprintf("invalid workspace name %q, must only contain lowercase letters, numbers, and dashes", opts.Workspace)
+			return nil, errors.New(errkind.Invalid, msg, op)
+		}
+		projectName += "-" + opts.Workspace
+		composeDir = filepath.Join(opts.Workdir, workspacesDir, opts.Workspace)
+		opts.DockerOptions.ContainerNamePrefix = opts.Workspace + "-"
+	}
+	if opts.PortOffset != 0 && opts.Services != nil {
+		// Make sure all ports are still valid with the offset so the compose file can be generated.
+		for it := opts.Services.Iter(); it.Next(); {
+			s := it.Value()
+			for _, p := range s.Ports {
+				if _, err := service.OffsetPort(p, opts.PortOffset); err != nil {
+					return nil, errors.Wrap(err, errors.Meta{
+						Reason: fmt.Sprintf("invalid port offset for service %s", s.FullName()),
+						Op:     op,
+					})
+				}
+			}
+		}
+	}
+
 	// Initialize clients
 	if opts.GitClient == nil {
 		opts.GitClient = git.New()
 	}
-	dockerClient, err := docker.New(projectName, opts.Workdir, opts.DockerOptions)
+	dockerClient, err := docker.New(projectName, composeDir, opts.DockerOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, errors.Meta{Op: op})
 	}
 
 	return &Engine{
 		workdir:          opts.Workdir,
+		workspace:        opts.Workspace,
+		composeDir:       composeDir,
+		portOffset:       opts.PortOffset,
 		experimentalMode: opts.ExperimentalMode,
 		services:         opts.Services,
 		playlists:        opts.Playlists,
@@ -125,6 +167,40 @@ func (e *Engine) Workdir() string {
 	return e.workdir
 }
 
+// Workspace returns the name of the workspace being used.
+// An empty string means the default workspace.
+func (e *Engine) Workspace() string {
+	return e.workspace
+}
+
+// Workspaces returns the names of the non-default workspaces that have data stored in the workdir.
+func (e *Engine) Workspaces() ([]string, error) {
+	const op = errors.Op("engine.Engine.Workspaces")
+	dir := filepath.Join(e.workdir, workspacesDir)
+	items, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to read directory %s", dir),
+			Op:     op,
+		})
+	}
+	var workspaces []string
+	for _, item := range items {
+		if item.IsDir() {
+			workspaces = append(workspaces, item.Name())
+		}
+	}
+	return workspaces, nil
+}
+
+// PortOffset returns the offset added to host ports published by services.
+func (e *Engine) PortOffset() int {
+	return e.portOffset
+}
+
 // ExperimentalMode returns whether or not experimental mode is enabled.
 func (e *Engine) ExperimentalMode() bool {
 	return e.experimentalMode
@@ -136,8 +212,29 @@ const (
 	iosDir        = "ios"
 	desktopDir    = "desktop"
 	registriesDir = "registries"
+	// workspacesDir contains a directory for each non-default workspace where its
+	// compose file and fingerprints are stored.
+	workspacesDir = "workspaces"
+	// fingerprintsFile stores the fingerprints of started services. See Engine.Up.
+	fingerprintsFile = "fingerprints.json"
 )
 
+// defaultProjectName is the compose project name of the default workspace.
+// Other workspaces use it as a prefix.
+const defaultProjectName = "tb"
+
+// workspaceNameRegex matches valid workspace names. Names are restricted since they are
+// used in compose project names, container names, and paths.
+var workspaceNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// composeOptions returns the options for generating the compose config for the workspace.
+func (e *Engine) composeOptions() service.ComposeOptions {
+	return service.ComposeOptions{
+		ContainerNamePrefix: e.dockerClient.ContainerName(""),
+		PortOffset:          e.portOffset,
+	}
+}
+
 // getStorageProvider returns a storage.Provider for the given provider name.
 // Providers are lazily-initialized the first time they are retrieved and are
 // cached for reuse.
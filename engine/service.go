@@ -4,10 +4,17 @@ package engine
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/TouchBistro/goutils/errors"
 	"github.com/TouchBistro/goutils/file"
@@ -15,6 +22,7 @@ import (
 	"github.com/TouchBistro/tb/errkind"
 	"github.com/TouchBistro/tb/integrations/docker"
 	"github.com/TouchBistro/tb/integrations/login"
+	"github.com/TouchBistro/tb/internal/watch"
 	"github.com/TouchBistro/tb/resource/service"
 	"gopkg.in/yaml.v3"
 )
@@ -37,6 +45,11 @@ type UpOptions struct {
 	ServiceNames []string
 	// PlaylistName is the name of a playlist to start.
 	PlaylistName string
+	// SkipDependencies skips resolving the dependencies of the services being started.
+	// By default, dependencies are started as well and go through all the same steps.
+	// Note that docker compose will still start the containers of any dependencies, however,
+	// they will not be pulled, built, or have their pre-run steps performed.
+	SkipDependencies bool
 	// SkipPreRun skips running the pre-run step for services.
 	SkipPreRun bool
 	// SkipDockerPull skips pulling both base images and service images if they already exist.
@@ -45,42 +58,142 @@ type UpOptions struct {
 	// SkipGitPull skips pulling existing git repos to update them.
 	// Missing repos will still be cloned however.
 	SkipGitPull bool
+	// ReadyTimeout is how long to wait for each service to become ready after it is started.
+	// Defaults to 5min if omitted.
+	ReadyTimeout time.Duration
+	// DryRun causes Up to only determine the actions it would perform without performing them.
+	// Nothing is changed, the plan is returned so it can be inspected.
+	DryRun bool
 }
 
+// UpPlan describes the actions performed by Up.
+type UpPlan struct {
+	// Services is the names of the services that will be started, including any dependencies.
+	Services []string
+	// Logins is the names of the login strategies that will be used.
+	Logins []string
+	// GitRepos is the git repos that will be cloned or pulled.
+	GitRepos []GitRepoAction
+	// BaseImages is the docker base images used to build services.
+	BaseImages []ImageAction
+	// ServiceImages is the docker images of services in remote mode.
+	ServiceImages []ImageAction
+	// Builds is the names of the services whose images will be built.
+	Builds []string
+	// PreRuns is the pre-run steps that will be performed, grouped into waves.
+	// The steps in a wave run concurrently, and each wave only runs once the previous one has succeeded.
+	PreRuns [][]PreRunAction
+	// StopContainers is the names of existing containers that will be stopped and removed.
+	StopContainers []string
+	// Unchanged is the names of the services that are already running and have not changed
+	// since they were last started. They are left running and their pre-run steps are skipped.
+	Unchanged []string
+}
+
+// GitRepoAction describes an action performed on a git repo.
+type GitRepoAction struct {
+	// Repo is the name of the repo, ex: TouchBistro/tb.
+	Repo string
+	// Path is the path on the OS filesystem where the repo is located.
+	Path string
+	// Clone is true if the repo will be cloned, otherwise it will be pulled.
+	Clone bool
+}
+
+// ImageAction describes an action performed on a docker image.
+type ImageAction struct {
+	// Image is the name of the image.
+	Image string
+	// Present reports whether the image already exists locally.
+	Present bool
+	// Pull reports whether the image will be pulled.
+	Pull bool
+}
+
+// PreRunAction describes a pre-run step performed for a service.
+type PreRunAction struct {
+	// Service is the name of the service.
+	Service string
+	// Command is the pre-run command that will be run.
+	Command string
+}
+
+// DefaultReadyTimeout is the default amount of time to wait for services to become ready.
+// It is used if UpOptions.ReadyTimeout is not set.
+const DefaultReadyTimeout = 5 * time.Minute
+
 // Up performs all necessary actions to prepare services and then starts them.
 //
 // Up will:
 //
-// - Stop and remove any services that are already running.
+// - Resolve the dependencies of services so they are started as well, unless opts.SkipDependencies is set.
 //
 // - Pull base images and service images.
 //
 // - Build any services with mode build.
 //
-// - Run pre-run steps for services.
+// - Stop and remove any services that are already running and have changed.
+//
+// - Run pre-run steps for services that have changed.
+//
+// When a service is started, Up records a fingerprint of its rendered compose config and image.
+// If a service is already running, its fingerprint has not changed, and none of its dependencies
+// have changed, it is left running and its pre-run step is skipped. This allows Up to be run again
+// to add services without restarting the ones already running. If opts.SkipPreRun is set, the
+// fingerprints of services with a pre-run step are not recorded so that their pre-run step is
+// performed the next time they are started.
+//
+// Once services are started, Up waits until each service is ready. A service with a healthcheck
+// is ready once it reports healthy, otherwise it is ready once its container is running.
+//
+// Up returns the plan of actions it performed. If opts.DryRun is set, Up stops after
+// determining the plan and no actions are performed.
 //
 // Exactly one of opts.ServiceNames or opts.PlaylistName must be provided to determine
 // which services to start.
-func (e *Engine) Up(ctx context.Context, opts UpOptions) error {
+func (e *Engine) Up(ctx context.Context, opts UpOptions) (UpPlan, error) {
 	const op = errors.Op("engine.Engine.Up")
-	services, err := e.resolveServices(op, opts.ServiceNames, opts.PlaylistName, true)
+	services, err := e.resolveServices(op, resolveServicesOptions{
+		serviceNames:     opts.ServiceNames,
+		playlistName:     opts.PlaylistName,
+		requireOne:       true,
+		withDependencies: !opts.SkipDependencies,
+	})
 	if err != nil {
-		return err
+		return UpPlan{}, err
 	}
-	if err := e.prepareGitRepos(ctx, op, opts.SkipGitPull); err != nil {
-		return err
+	// Determine the order of pre-run steps up front so that dependency cycles are
+	// reported before any work is done.
+	waves, err := service.DependencyWaves(services)
+	if err != nil {
+		return UpPlan{}, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Invalid,
+			Reason: "unable to resolve service dependencies",
+			Op:     op,
+		})
+	}
+	plan, err := e.planUp(ctx, op, services, waves, opts)
+	if err != nil {
+		return plan, err
+	}
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	if err := e.prepareGitRepos(ctx, op, plan.GitRepos); err != nil {
+		return plan, err
 	}
 	if err := e.writeComposeFile(ctx, op); err != nil {
-		return err
+		return plan, err
 	}
 
 	tracker := progress.TrackerFromContext(ctx)
-	if len(e.loginStrategies) > 0 {
-		loginStrategies := make([]login.Strategy, len(e.loginStrategies))
-		for i, name := range e.loginStrategies {
+	if len(plan.Logins) > 0 {
+		loginStrategies := make([]login.Strategy, len(plan.Logins))
+		for i, name := range plan.Logins {
 			s, err := login.ParseStrategy(name)
 			if err != nil {
-				return errors.Wrap(err, errors.Meta{Op: op})
+				return plan, errors.Wrap(err, errors.Meta{Op: op})
 			}
 			loginStrategies[i] = s
 		}
@@ -103,30 +216,19 @@ func (e *Engine) Up(ctx context.Context, opts UpOptions) error {
 			return nil
 		})
 		if err != nil {
-			return err
+			return plan, err
 		}
 		tracker.Debug("Finished logging into services")
 	}
 
-	// Cleanup previous docker state
-	err = progress.Run(ctx, progress.RunOptions{
-		Message: "Cleaning up previous docker state",
-	}, func(ctx context.Context) error {
-		return e.stopServices(ctx, op, services)
-	})
-	if err != nil {
-		return errors.Wrap(err, errors.Meta{Reason: "failed to clean up previous docker state", Op: op})
-	}
-	tracker.Info("✔ Cleaned up previous docker state")
-
 	// Pull base images
-	if !opts.SkipDockerPull && len(e.baseImages) > 0 {
+	if baseImages := imagesToPull(plan.BaseImages); len(baseImages) > 0 {
 		err := progress.RunParallel(ctx, progress.RunParallelOptions{
 			Message:     "Pulling docker base images",
-			Count:       len(e.baseImages),
+			Count:       len(baseImages),
 			Concurrency: e.concurrency,
 		}, func(ctx context.Context, i int) error {
-			img := e.baseImages[i]
+			img := baseImages[i]
 			if err := e.dockerClient.PullImage(ctx, img); err != nil {
 				return err
 			}
@@ -134,104 +236,357 @@ func (e *Engine) Up(ctx context.Context, opts UpOptions) error {
 			return nil
 		})
 		if err != nil {
-			return errors.Wrap(err, errors.Meta{Reason: "failed to pull docker base images", Op: op})
+			return plan, errors.Wrap(err, errors.Meta{Reason: "failed to pull docker base images", Op: op})
 		}
 		tracker.Info("✔ Pulled docker base images")
 	}
 
 	// Pull service images
-	if !opts.SkipDockerPull {
-		var images []string
-		for _, s := range services {
-			if s.Mode == service.ModeRemote {
-				images = append(images, s.ImageURI())
-			}
-		}
-		if len(images) > 0 {
-			err := progress.RunParallel(ctx, progress.RunParallelOptions{
-				Message:     "Pulling docker service images",
-				Count:       len(images),
-				Concurrency: e.concurrency,
-			}, func(ctx context.Context, i int) error {
-				img := images[i]
-				if err := e.dockerClient.PullImage(ctx, img); err != nil {
-					return err
-				}
-				tracker.Debugf("Pulled service image %s", img)
-				return nil
-			})
-			if err != nil {
-				return errors.Wrap(err, errors.Meta{Reason: "failed to pull docker service images", Op: op})
+	if images := imagesToPull(plan.ServiceImages); len(images) > 0 {
+		err := progress.RunParallel(ctx, progress.RunParallelOptions{
+			Message:     "Pulling docker service images",
+			Count:       len(images),
+			Concurrency: e.concurrency,
+		}, func(ctx context.Context, i int) error {
+			img := images[i]
+			if err := e.dockerClient.PullImage(ctx, img); err != nil {
+				return err
 			}
-			tracker.Info("✔ Pulled docker service images")
+			tracker.Debugf("Pulled service image %s", img)
+			return nil
+		})
+		if err != nil {
+			return plan, errors.Wrap(err, errors.Meta{Reason: "failed to pull docker service images", Op: op})
 		}
+		tracker.Info("✔ Pulled docker service images")
 	}
 
 	// Build necessary services
-	var buildServices []string
-	for _, s := range services {
-		if s.Mode == service.ModeBuild {
-			buildServices = append(buildServices, s.FullName())
-		}
-	}
-	if len(buildServices) > 0 {
+	if len(plan.Builds) > 0 {
 		err := progress.Run(ctx, progress.RunOptions{
 			Message: "Building docker images for services",
 		}, func(ctx context.Context) error {
-			return e.dockerClient.BuildServices(ctx, buildServices)
+			return e.dockerClient.BuildServices(ctx, plan.Builds)
 		})
 		if err != nil {
-			return errors.Wrap(err, errors.Meta{Reason: "failed to build docker images for services", Op: op})
+			return plan, errors.Wrap(err, errors.Meta{Reason: "failed to build docker images for services", Op: op})
 		}
 		tracker.Info("✔ Built docker service images")
 	}
 
+	// Now that images are up to date, determine which services actually changed.
+	// Services that are running and unchanged are left alone.
+	changes, err := e.planServiceChanges(ctx, op, services, waves, opts.SkipPreRun, &plan)
+	if err != nil {
+		return plan, err
+	}
+	if len(changes.unchanged) > 0 {
+		tracker.Infof("✔ Services already running and unchanged: %s", strings.Join(plan.Unchanged, ", "))
+	}
+
+	// Cleanup previous docker state
+	if len(changes.changed) > 0 {
+		err = progress.Run(ctx, progress.RunOptions{
+			Message: "Cleaning up previous docker state",
+		}, func(ctx context.Context) error {
+			return e.stopServices(ctx, op, changes.changed)
+		})
+		if err != nil {
+			return plan, errors.Wrap(err, errors.Meta{Reason: "failed to clean up previous docker state", Op: op})
+		}
+		tracker.Info("✔ Cleaned up previous docker state")
+	}
+
 	// Perform service pre-run
-	if !opts.SkipPreRun {
-		// Do this serially since we had issues before when trying to do it in parallel.
-		// TODO(@cszatmary): Should scope what the deal was and see if we do these in parallel.
-		// We might need to rethink the whole way pre-run works.
-		err := progress.Run(ctx, progress.RunOptions{
-			Message: "Performing pre-run step for services (this may take a long time)",
-			Count:   len(services),
+	if err := e.runPreRuns(ctx, op, changes.preRunWaves); err != nil {
+		return plan, err
+	}
+
+	// Start services
+	if len(changes.changed) > 0 {
+		err = progress.Run(ctx, progress.RunOptions{
+			Message: "Starting services in the background",
 		}, func(ctx context.Context) error {
-			for _, s := range services {
-				if s.PreRun == "" {
-					tracker.Debugf("No pre-run for %s, skipping", s.FullName())
-					tracker.Inc()
-					continue
-				}
+			return e.dockerClient.UpServices(ctx, getServiceNames(changes.changed))
+		})
+		if err != nil {
+			return plan, errors.Wrap(err, errors.Meta{Reason: "failed to start services", Op: op})
+		}
+	}
+	if opts.ReadyTimeout == 0 {
+		opts.ReadyTimeout = DefaultReadyTimeout
+	}
+	if err := e.waitForServices(ctx, op, services, opts.ReadyTimeout); err != nil {
+		return plan, err
+	}
+	// Only record fingerprints once services are ready, otherwise a broken service
+	// would be considered unchanged and left alone the next time.
+	return plan, e.saveFingerprints(op, changes.fingerprints)
+}
+
+// planUp determines the actions Up will perform to start services.
+// It does not modify anything, it only inspects the current state.
+func (e *Engine) planUp(ctx context.Context, op errors.Op, services []service.Service, waves [][]service.Service, opts UpOptions) (UpPlan, error) {
+	plan := UpPlan{
+		Services: getServiceNames(services),
+		Logins:   e.loginStrategies,
+	}
+	var err error
+	plan.GitRepos, err = e.planGitRepos(op, opts.SkipGitPull)
+	if err != nil {
+		return plan, err
+	}
+
+	var serviceImages []string
+	for _, s := range services {
+		switch s.Mode {
+		case service.ModeRemote:
+			serviceImages = append(serviceImages, s.ImageURI())
+		case service.ModeBuild:
+			plan.Builds = append(plan.Builds, s.FullName())
+		}
+	}
+	plan.BaseImages, err = e.planImages(ctx, op, e.baseImages, opts.SkipDockerPull)
+	if err != nil {
+		return plan, err
+	}
+	plan.ServiceImages, err = e.planImages(ctx, op, serviceImages, opts.SkipDockerPull)
+	if err != nil {
+		return plan, err
+	}
+
+	// Images have not been pulled or built yet so this is based on the images that
+	// currently exist. Up will check again once images are up to date.
+	_, err = e.planServiceChanges(ctx, op, services, waves, opts.SkipPreRun, &plan)
+	return plan, err
+}
+
+// serviceChanges describes which services have changed since they were last started by Up.
+type serviceChanges struct {
+	changed   []service.Service
+	unchanged []service.Service
+	// preRunWaves only contains changed services. It is empty if pre-run steps are skipped.
+	preRunWaves [][]service.Service
+	// fingerprints is the fingerprint of each service to record once it has been started, keyed by name.
+	// Changed services whose pre-run steps are skipped have an empty fingerprint, which removes the
+	// recorded fingerprint, so that their pre-run steps are performed the next time they are started.
+	fingerprints map[string]string
+}
 
-				tracker.Debugf("Running pre-run for %s", s.FullName())
-				if err := e.dockerClient.RunService(ctx, s.FullName(), s.PreRun); err != nil {
-					return errors.Wrap(err, errors.Meta{
-						Reason: fmt.Sprintf("failed to run pre-run command for %s", s.FullName()),
-						Op:     op,
-					})
+// planServiceChanges determines which services have changed and updates plan accordingly.
+// A service is unchanged if its container is running and its fingerprint matches the
+// fingerprint recorded when it was last started, and none of its dependencies have changed.
+// Unchanged services do not need to be recreated and their pre-run steps can be skipped.
+//
+// waves is the services grouped into dependency waves, see service.DependencyWaves.
+// If skipPreRun is true, no pre-run steps are planned.
+func (e *Engine) planServiceChanges(ctx context.Context, op errors.Op, services []service.Service, waves [][]service.Service, skipPreRun bool, plan *UpPlan) (serviceChanges, error) {
+	changes := serviceChanges{}
+	containers, err := e.dockerClient.ListServiceContainers(ctx, getServiceNames(services)...)
+	if err != nil {
+		return changes, errors.Wrap(err, errors.Meta{Reason: "failed to get service containers", Op: op})
+	}
+	containersByName := make(map[string]docker.ServiceContainer, len(containers))
+	for _, c := range containers {
+		containersByName[c.Name] = c
+	}
+	changes.fingerprints, err = e.serviceFingerprints(ctx, op, services)
+	if err != nil {
+		return changes, err
+	}
+	prevFingerprints, err := e.readFingerprints(op)
+	if err != nil {
+		return changes, err
+	}
+
+	// Waves are in dependency order, so each service's dependencies have already been checked.
+	// A service whose dependency changed is changed as well since the dependency will be recreated.
+	changed := make(map[string]bool)
+	for _, wave := range waves {
+		for _, s := range wave {
+			name := s.FullName()
+			c, ok := containersByName[e.dockerClient.ContainerName(name)]
+			fp := changes.fingerprints[name]
+			isChanged := !ok || !c.Running() || fp == "" || fp != prevFingerprints[name]
+			for _, d := range s.Dependencies {
+				if changed[docker.NormalizeName(d)] {
+					isChanged = true
+					break
 				}
-				tracker.Debugf("Ran pre-run for %s", s.FullName())
-				tracker.Inc()
 			}
-			return nil
-		})
+			changed[docker.NormalizeName(name)] = isChanged
+		}
+	}
+
+	plan.Unchanged = nil
+	plan.StopContainers = nil
+	for _, s := range services {
+		name := s.FullName()
+		if !changed[docker.NormalizeName(name)] {
+			changes.unchanged = append(changes.unchanged, s)
+			plan.Unchanged = append(plan.Unchanged, name)
+			continue
+		}
+		changes.changed = append(changes.changed, s)
+		if c, ok := containersByName[e.dockerClient.ContainerName(name)]; ok {
+			plan.StopContainers = append(plan.StopContainers, c.Name)
+		}
+		if skipPreRun && s.PreRun != "" {
+			changes.fingerprints[name] = ""
+		}
+	}
+
+	plan.PreRuns = nil
+	if skipPreRun {
+		return changes, nil
+	}
+	for _, wave := range waves {
+		var changedWave []service.Service
+		var actions []PreRunAction
+		for _, s := range wave {
+			if !changed[docker.NormalizeName(s.FullName())] {
+				continue
+			}
+			changedWave = append(changedWave, s)
+			if s.PreRun != "" {
+				actions = append(actions, PreRunAction{Service: s.FullName(), Command: s.PreRun})
+			}
+		}
+		if len(changedWave) > 0 {
+			changes.preRunWaves = append(changes.preRunWaves, changedWave)
+		}
+		if len(actions) > 0 {
+			plan.PreRuns = append(plan.PreRuns, actions)
+		}
+	}
+	return changes, nil
+}
+
+// serviceFingerprints returns a fingerprint for each service which is a hash of its rendered
+// compose config and the ID of its image. A service whose image does not exist locally has no
+// fingerprint since it cannot be running the current image.
+func (e *Engine) serviceFingerprints(ctx context.Context, op errors.Op, services []service.Service) (map[string]string, error) {
+	composeConfig := service.ComposeConfig(e.services, e.composeOptions())
+	fingerprints := make(map[string]string, len(services))
+	for _, s := range services {
+		search := docker.ImageSearch{Name: s.ImageURI()}
+		if s.Mode == service.ModeBuild {
+			search = docker.ImageSearch{Name: s.FullName(), LocalBuild: true}
+		}
+		imageID, err := e.dockerClient.ImageID(ctx, search)
 		if err != nil {
-			return err
+			return nil, errors.Wrap(err, errors.Meta{
+				Reason: fmt.Sprintf("failed to get image for service %s", s.FullName()),
+				Op:     op,
+			})
+		}
+		if imageID == "" {
+			continue
 		}
-		tracker.Info("✔ Performed pre-run step for services")
+		b, err := yaml.Marshal(composeConfig.Services[docker.NormalizeName(s.FullName())])
+		if err != nil {
+			return nil, errors.Wrap(err, errors.Meta{
+				Kind:   errkind.Internal,
+				Reason: fmt.Sprintf("failed to encode compose config for service %s", s.FullName()),
+				Op:     op,
+			})
+		}
+		h := sha256.New()
+		h.Write(b)
+		io.WriteString(h, imageID)
+		fingerprints[s.FullName()] = hex.EncodeToString(h.Sum(nil))
 	}
+	return fingerprints, nil
+}
 
-	// Start services
-	err = progress.Run(ctx, progress.RunOptions{
-		Message: "Starting services in the background",
-	}, func(ctx context.Context) error {
-		return e.dockerClient.UpServices(ctx, getServiceNames(services))
-	})
+// readFingerprints reads the fingerprints of services recorded by the last Up.
+// If no fingerprints have been recorded, an empty map is returned.
+func (e *Engine) readFingerprints(op errors.Op) (map[string]string, error) {
+	fingerprints := make(map[string]string)
+	fp := filepath.Join(e.composeDir, fingerprintsFile)
+	b, err := os.ReadFile(fp)
+	if errors.Is(err, os.ErrNotExist) {
+		return fingerprints, nil
+	}
 	if err != nil {
-		return errors.Wrap(err, errors.Meta{Reason: "failed to start services", Op: op})
+		return nil, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to read %s", fp),
+			Op:     op,
+		})
+	}
+	if err := json.Unmarshal(b, &fingerprints); err != nil {
+		return nil, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Internal,
+			Reason: fmt.Sprintf("failed to parse %s", fp),
+			Op:     op,
+		})
+	}
+	return fingerprints, nil
+}
+
+// saveFingerprints records the given service fingerprints so subsequent calls to Up
+// can determine if the services changed. An empty fingerprint removes the recorded fingerprint
+// of the service. Fingerprints of other services are preserved.
+func (e *Engine) saveFingerprints(op errors.Op, fingerprints map[string]string) error {
+	allFingerprints, err := e.readFingerprints(op)
+	if err != nil {
+		return err
+	}
+	for name, fp := range fingerprints {
+		if fp == "" {
+			delete(allFingerprints, name)
+			continue
+		}
+		allFingerprints[name] = fp
+	}
+	b, err := json.MarshalIndent(allFingerprints, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Internal,
+			Reason: "failed to encode service fingerprints",
+			Op:     op,
+		})
+	}
+	fp := filepath.Join(e.composeDir, fingerprintsFile)
+	if err := os.WriteFile(fp, b, 0o644); err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to write %s", fp),
+			Op:     op,
+		})
 	}
 	return nil
 }
 
+// planImages determines which of the given images need to be pulled. Images are always pulled
+// unless skipPull is true, in which case only images that are not present are pulled.
+func (e *Engine) planImages(ctx context.Context, op errors.Op, images []string, skipPull bool) ([]ImageAction, error) {
+	var actions []ImageAction
+	for _, img := range images {
+		present, err := e.dockerClient.ImageExists(ctx, img)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.Meta{
+				Reason: fmt.Sprintf("failed to check if image %s exists", img),
+				Op:     op,
+			})
+		}
+		actions = append(actions, ImageAction{Image: img, Present: present, Pull: !skipPull || !present})
+	}
+	return actions, nil
+}
+
+// imagesToPull returns the names of the images in actions that need to be pulled.
+func imagesToPull(actions []ImageAction) []string {
+	var images []string
+	for _, a := range actions {
+		if a.Pull {
+			images = append(images, a.Image)
+		}
+	}
+	return images
+}
+
 // DownOptions customizes the behaviour of Down.
 type DownOptions struct {
 	// ServiceNames is a list of services names to stop.
@@ -242,7 +597,7 @@ type DownOptions struct {
 // Down stops services and removes the containers.
 func (e *Engine) Down(ctx context.Context, opts DownOptions) error {
 	const op = errors.Op("engine.Engine.Down")
-	services, err := e.resolveServices(op, opts.ServiceNames, "", false)
+	services, err := e.resolveServices(op, resolveServicesOptions{serviceNames: opts.ServiceNames})
 	if err != nil {
 		return err
 	}
@@ -257,6 +612,135 @@ func (e *Engine) Down(ctx context.Context, opts DownOptions) error {
 	return nil
 }
 
+// StopOptions customizes the behaviour of Stop.
+type StopOptions struct {
+	// ServiceNames is a list of services names to stop.
+	ServiceNames []string
+	// PlaylistName is the name of a playlist to stop.
+	// If neither ServiceNames nor PlaylistName are provided, all running services will be stopped.
+	PlaylistName string
+}
+
+// Stop stops services without removing the containers. The containers can be started again with Start.
+func (e *Engine) Stop(ctx context.Context, opts StopOptions) error {
+	const op = errors.Op("engine.Engine.Stop")
+	services, err := e.resolveServices(op, resolveServicesOptions{
+		serviceNames: opts.ServiceNames,
+		playlistName: opts.PlaylistName,
+	})
+	if err != nil {
+		return err
+	}
+	err = progress.Run(ctx, progress.RunOptions{
+		Message: "Stopping services",
+	}, func(ctx context.Context) error {
+		return e.dockerClient.StopContainers(ctx, getServiceNames(services)...)
+	})
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{Reason: "failed to stop services", Op: op})
+	}
+	return nil
+}
+
+// StartOptions customizes the behaviour of Start.
+type StartOptions struct {
+	// ServiceNames is a list of services names to start.
+	ServiceNames []string
+	// PlaylistName is the name of a playlist to start.
+	// If neither ServiceNames nor PlaylistName are provided, all stopped services will be started.
+	PlaylistName string
+}
+
+// Start starts the existing containers of services that were stopped.
+// Unlike Up, Start does not create containers, each service must already have one.
+func (e *Engine) Start(ctx context.Context, opts StartOptions) error {
+	const op = errors.Op("engine.Engine.Start")
+	services, err := e.resolveServices(op, resolveServicesOptions{
+		serviceNames: opts.ServiceNames,
+		playlistName: opts.PlaylistName,
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.requireContainers(ctx, op, services); err != nil {
+		return err
+	}
+	err = progress.Run(ctx, progress.RunOptions{
+		Message: "Starting services",
+	}, func(ctx context.Context) error {
+		return e.dockerClient.StartContainers(ctx, getServiceNames(services)...)
+	})
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{Reason: "failed to start services", Op: op})
+	}
+	return nil
+}
+
+// RestartOptions customizes the behaviour of Restart.
+type RestartOptions struct {
+	// ServiceNames is a list of services names to restart.
+	ServiceNames []string
+	// PlaylistName is the name of a playlist to restart.
+	// If neither ServiceNames nor PlaylistName are provided, all services will be restarted.
+	PlaylistName string
+}
+
+// Restart restarts the existing containers of services. Stopped containers are started.
+// Unlike Up, Restart does not recreate containers so any changes to the container's
+// filesystem are kept.
+func (e *Engine) Restart(ctx context.Context, opts RestartOptions) error {
+	const op = errors.Op("engine.Engine.Restart")
+	services, err := e.resolveServices(op, resolveServicesOptions{
+		serviceNames: opts.ServiceNames,
+		playlistName: opts.PlaylistName,
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.requireContainers(ctx, op, services); err != nil {
+		return err
+	}
+	err = progress.Run(ctx, progress.RunOptions{
+		Message: "Restarting services",
+	}, func(ctx context.Context) error {
+		return e.dockerClient.RestartContainers(ctx, getServiceNames(services)...)
+	})
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{Reason: "failed to restart services", Op: op})
+	}
+	return nil
+}
+
+// requireContainers returns an error if any of the given services do not have a container.
+func (e *Engine) requireContainers(ctx context.Context, op errors.Op, services []service.Service) error {
+	if len(services) == 0 {
+		return nil
+	}
+	containers, err := e.dockerClient.ListServiceContainers(ctx, getServiceNames(services)...)
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{Reason: "failed to get service containers", Op: op})
+	}
+	containerNames := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		containerNames[c.Name] = true
+	}
+	var errs errors.List
+	for _, s := range services {
+		if !containerNames[e.dockerClient.ContainerName(s.FullName())] {
+			msg := fmt.Sprintf("service %s has no container, it must be started with up first", s.FullName())
+			errs = append(errs, errors.New(errkind.Invalid, msg, op))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Wrap(errs, errors.Meta{
+			Kind:   errkind.Invalid,
+			Reason: "services are missing containers",
+			Op:     op,
+		})
+	}
+	return nil
+}
+
 // LogsOptions customizes the behaviour of Logs.
 type LogsOptions struct {
 	// ServiceNames is a list of services names for which to retrieve logs.
@@ -272,7 +756,7 @@ type LogsOptions struct {
 // Logs retrieves the logs from one or more service containers and writes it to w.
 func (e *Engine) Logs(ctx context.Context, w io.Writer, opts LogsOptions) error {
 	const op = errors.Op("engine.Engine.Logs")
-	services, err := e.resolveServices(op, opts.ServiceNames, "", false)
+	services, err := e.resolveServices(op, resolveServicesOptions{serviceNames: opts.ServiceNames})
 	if err != nil {
 		return err
 	}
@@ -288,6 +772,218 @@ func (e *Engine) Logs(ctx context.Context, w io.Writer, opts LogsOptions) error
 	return nil
 }
 
+// StatusOptions customizes the behaviour of Status.
+type StatusOptions struct {
+	// ServiceNames is a list of services names to get the status of.
+	// If empty, the status of all services with a container will be returned.
+	ServiceNames []string
+	// All includes services that do not have a container. It has no effect
+	// if ServiceNames is provided since those services are always included.
+	All bool
+}
+
+// ServiceStatus describes the live state of a service.
+type ServiceStatus struct {
+	// Name is the full name of the service.
+	Name string
+	// Mode is the mode the service is configured to run in.
+	Mode string
+	// Image is the image of the service's container. If there is no container, it is the image that would
+	// be used if the service was started. It is empty if there is no container and the service is in build mode.
+	Image string
+	// HasContainer reports whether a container exists for the service.
+	// If false, all the container related fields are zero values.
+	HasContainer bool
+	// State is the state of the container, ex: running or exited.
+	State string
+	// Health is the health status of the container.
+	Health string
+	// StartedAt is the time the container was last started.
+	StartedAt time.Time
+	// Ports are the ports published by the container.
+	Ports []string
+	// RestartCount is the number of times docker has restarted the container.
+	RestartCount int
+}
+
+// Status returns the live state of services. Only containers managed by tb are considered.
+// The returned statuses are sorted by service name.
+func (e *Engine) Status(ctx context.Context, opts StatusOptions) ([]ServiceStatus, error) {
+	const op = errors.Op("engine.Engine.Status")
+	services, err := e.resolveServices(op, resolveServicesOptions{serviceNames: opts.ServiceNames})
+	if err != nil {
+		return nil, err
+	}
+	// Only filter containers if specific services were requested, otherwise list all containers
+	// in the project since we will need to check every service.
+	containerFilter := getServiceNames(services)
+	if services == nil {
+		for it := e.services.Iter(); it.Next(); {
+			services = append(services, it.Value())
+		}
+	}
+	containers, err := e.dockerClient.ListServiceContainers(ctx, containerFilter...)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.Meta{Reason: "failed to get service containers", Op: op})
+	}
+	containersByName := make(map[string]docker.ServiceContainer, len(containers))
+	for _, c := range containers {
+		containersByName[c.Name] = c
+	}
+
+	var statuses []ServiceStatus
+	for _, s := range services {
+		st := ServiceStatus{Name: s.FullName(), Mode: s.Mode}
+		if s.Mode == service.ModeRemote {
+			st.Image = s.ImageURI()
+		}
+		c, ok := containersByName[e.dockerClient.ContainerName(s.FullName())]
+		if ok {
+			st.HasContainer = true
+			st.Image = c.Image
+			st.State = c.State
+			st.Health = c.Health
+			st.StartedAt = c.StartedAt
+			st.Ports = c.Ports
+			st.RestartCount = c.RestartCount
+		} else if len(opts.ServiceNames) == 0 && !opts.All {
+			continue
+		}
+		statuses = append(statuses, st)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses, nil
+}
+
+// WatchOptions customizes the behaviour of Watch.
+type WatchOptions struct {
+	// ServiceNames is a list of services names to watch. Each service must be in build mode.
+	ServiceNames []string
+	// Ignore is a list of glob patterns for files to ignore in the build context of each service.
+	// See watch.Options for details on the pattern syntax.
+	Ignore []string
+	// Debounce is how long to wait for changes to stop before rebuilding a service.
+	// Defaults to 1s if omitted.
+	Debounce time.Duration
+}
+
+// Watch watches the build context of each service for changes. When a change is detected,
+// the image for the service is rebuilt and its container is recreated.
+//
+// Failing to rebuild a service is not fatal, the error is logged and Watch continues to
+// wait for more changes so that the problem can be fixed. Watch blocks until ctx is cancelled.
+func (e *Engine) Watch(ctx context.Context, opts WatchOptions) error {
+	const op = errors.Op("engine.Engine.Watch")
+	services, err := e.resolveServices(op, resolveServicesOptions{
+		serviceNames: opts.ServiceNames,
+		requireOne:   true,
+	})
+	if err != nil {
+		return err
+	}
+	dirs := make([]string, len(services))
+	for i, s := range services {
+		if s.Mode != service.ModeBuild {
+			msg := fmt.Sprintf("service %s cannot be watched since it is not in build mode", s.FullName())
+			return errors.New(errkind.Invalid, msg, op)
+		}
+		// The build context is relative to the compose file if it is not absolute.
+		dir := s.Build.DockerfilePath
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(e.composeDir, dir)
+		}
+		if !file.Exists(dir) {
+			msg := fmt.Sprintf("build context %s for service %s does not exist", dir, s.FullName())
+			return errors.New(errkind.Invalid, msg, op)
+		}
+		dirs[i] = dir
+	}
+	// Make sure the compose file is up to date so rebuilt services use the current config.
+	if err := e.writeComposeFile(ctx, op); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tracker := progress.TrackerFromContext(ctx)
+	// Only rebuild one service at a time so progress output isn't interleaved.
+	var mu sync.Mutex
+	errCh := make(chan error, len(services))
+	for i, s := range services {
+		s := s
+		dir := dirs[i]
+		tracker.Infof("👀 Watching %s for changes to %s", dir, s.FullName())
+		go func() {
+			err := watch.Watch(ctx, dir, watch.Options{
+				Ignore:   opts.Ignore,
+				Debounce: opts.Debounce,
+			}, func(ctx context.Context, changed []string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				tracker.Debugf("Changed files for %s: %s", s.FullName(), strings.Join(changed, ", "))
+				e.rebuildService(ctx, op, s)
+				return nil
+			})
+			if err != nil {
+				// Stop the other watchers since something is seriously wrong.
+				cancel()
+			}
+			errCh <- err
+		}()
+	}
+	var errs errors.List
+	for range services {
+		if err := <-errCh; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Wrap(errs, errors.Meta{Reason: "failed to watch services", Op: op})
+	}
+	return nil
+}
+
+// rebuildService rebuilds the image for a service and recreates its container.
+// Errors are logged instead of returned so that Watch keeps waiting for changes.
+func (e *Engine) rebuildService(ctx context.Context, op errors.Op, s service.Service) {
+	tracker := progress.TrackerFromContext(ctx)
+	err := progress.Run(ctx, progress.RunOptions{
+		Message: fmt.Sprintf("Rebuilding %s", s.FullName()),
+	}, func(ctx context.Context) error {
+		if err := e.dockerClient.BuildServices(ctx, []string{s.FullName()}); err != nil {
+			return errors.Wrap(err, errors.Meta{Reason: "failed to build docker image", Op: op})
+		}
+		if err := e.stopServices(ctx, op, []service.Service{s}); err != nil {
+			return err
+		}
+		if err := e.dockerClient.UpServices(ctx, []string{s.FullName()}); err != nil {
+			return errors.Wrap(err, errors.Meta{Reason: "failed to start service", Op: op})
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		// Cancelled, Watch will stop so there's nothing to do.
+		return
+	}
+	if err != nil {
+		tracker.WithFields(progress.Fields{"error": err}).Errorf("Failed to rebuild %s, waiting for more changes", s.FullName())
+		return
+	}
+	tracker.Infof("✔ Rebuilt and restarted %s", s.FullName())
+
+	// Record the new fingerprint so Up sees that the service is up to date. The service was rebuilt
+	// so failing to record it isn't a reason to stop watching, Up will just recreate the service.
+	fingerprints, err := e.serviceFingerprints(ctx, op, []service.Service{s})
+	if err == nil {
+		err = e.saveFingerprints(op, fingerprints)
+	}
+	if err != nil {
+		tracker.WithFields(progress.Fields{"error": err}).Warnf("Failed to record the fingerprint of %s", s.FullName())
+	}
+}
+
 // ExecOptions customizes the behaviour of Exec.
 type ExecOptions struct {
 	// Cmd is the command to execute. It must have at
@@ -335,22 +1031,36 @@ type ListOptions struct {
 }
 
 type ListResult struct {
-	Services        []string
-	Playlists       []PlaylistSummary
-	CustomPlaylists []PlaylistSummary
+	Services []string
+	// ShadowedServices maps the full name of each service that is shadowed to the full name of
+	// the service its short name resolves to. A service is shadowed if it was replaced by another
+	// service or if a service with the same name is in a registry with a higher priority.
+	ShadowedServices map[string]string
+	Playlists        []PlaylistSummary
+	CustomPlaylists  []PlaylistSummary
 }
 
 // PlaylistSummary provides a summary of a playlist produced by List.
 type PlaylistSummary struct {
 	Name     string
 	Services []string
+	// ShadowedBy is the name of the playlist the short name of this playlist resolves to,
+	// if it is a different playlist. It is empty if the playlist is not shadowed.
+	ShadowedBy string
 }
 
 func (e *Engine) List(opts ListOptions) ListResult {
 	var lr ListResult
 	if opts.ListServices {
 		for it := e.services.Iter(); it.Next(); {
-			lr.Services = append(lr.Services, it.Value().FullName())
+			name := it.Value().FullName()
+			lr.Services = append(lr.Services, name)
+			if by, ok := e.services.ShadowedBy(name); ok {
+				if lr.ShadowedServices == nil {
+					lr.ShadowedServices = make(map[string]string)
+				}
+				lr.ShadowedServices[name] = by
+			}
 		}
 	}
 	if opts.ListPlaylists {
@@ -366,6 +1076,7 @@ func (e *Engine) listPlaylists(names []string, tree bool) []PlaylistSummary {
 	var summaries []PlaylistSummary
 	for _, n := range names {
 		summary := PlaylistSummary{Name: n}
+		summary.ShadowedBy, _ = e.playlists.ShadowedBy(n)
 		if tree {
 			list, err := e.playlists.ServiceNames(n)
 			if err != nil {
@@ -405,8 +1116,17 @@ type NukeOptions struct {
 
 // Nuke cleans up resources based on the given options. Nuke only touches resources
 // created by tb with the exception of images as dangling images will also be removed.
+//
+// If the Engine is using a workspace other than the default one, only the resources that belong
+// to the workspace are removed. This means containers, networks, volumes, and locally built images.
+// Remote images, git repos, apps, and registries are shared between workspaces so they cannot be
+// removed for a single workspace.
 func (e *Engine) Nuke(ctx context.Context, opts NukeOptions) error {
 	const op = errors.Op("engine.Engine.Nuke")
+	if e.workspace != "" && (opts.RemoveRepos || opts.RemoveDesktopApps || opts.RemoveiOSApps || opts.RemoveRegistries) {
+		msg := fmt.Sprintf("cannot remove repos, apps, or registries for workspace %s since they are shared by all workspaces", e.workspace)
+		return errors.New(errkind.Invalid, msg, op)
+	}
 	return progress.Run(ctx, progress.RunOptions{
 		Message: "Cleaning up tb data",
 	}, func(ctx context.Context) error {
@@ -438,15 +1158,18 @@ func (e *Engine) nuke(ctx context.Context, opts NukeOptions, op errors.Op) error
 			s := it.Value()
 			// Search for both remote and locally built images since the user might have switched
 			// between build and remote mode in their tbrc.
-			if s.Remote.Image != "" {
+			// Remote images are shared by all workspaces so only remove them for the default one.
+			if s.Remote.Image != "" && e.workspace == "" {
 				imageSearches = append(imageSearches, docker.ImageSearch{Name: s.Remote.Image})
 			}
 			if s.CanBuild() {
 				imageSearches = append(imageSearches, docker.ImageSearch{Name: s.FullName(), LocalBuild: true})
 			}
 		}
-		for _, bi := range e.baseImages {
-			imageSearches = append(imageSearches, docker.ImageSearch{Name: bi})
+		if e.workspace == "" {
+			for _, bi := range e.baseImages {
+				imageSearches = append(imageSearches, docker.ImageSearch{Name: bi})
+			}
 		}
 		tracker.UpdateMessage("Removing docker images")
 		if err := e.dockerClient.RemoveImages(ctx, imageSearches); err != nil {
@@ -519,8 +1242,20 @@ func (e *Engine) nuke(ctx context.Context, opts NukeOptions, op errors.Op) error
 		tracker.Infof("✔ Removed %s", dir.name)
 	}
 
-	// Check workdir and remove any files/dirs that shouldn't be there.
 	tracker.UpdateMessage("Removing any remaining files")
+	if e.workspace != "" {
+		// Workspaces only have their own compose dir, everything else is shared.
+		if err := os.RemoveAll(e.composeDir); err != nil {
+			return errors.Wrap(err, errors.Meta{
+				Kind:   errkind.IO,
+				Reason: fmt.Sprintf("failed to remove %s", e.composeDir),
+				Op:     op,
+			})
+		}
+		return nil
+	}
+
+	// Check workdir and remove any files/dirs that shouldn't be there.
 	items, err := os.ReadDir(e.workdir)
 	if err != nil {
 		return errors.Wrap(err, errors.Meta{
@@ -534,7 +1269,7 @@ func (e *Engine) nuke(ctx context.Context, opts NukeOptions, op errors.Op) error
 		// options weren't specified. If they were specified to be removed
 		// they would have already been removed above.
 		switch item.Name() {
-		case reposDir, iosDir, desktopDir, registriesDir:
+		case reposDir, iosDir, desktopDir, registriesDir, workspacesDir:
 			continue
 		}
 		p := filepath.Join(e.workdir, item.Name())
@@ -549,6 +1284,17 @@ func (e *Engine) nuke(ctx context.Context, opts NukeOptions, op errors.Op) error
 	return nil
 }
 
+// resolveServicesOptions customizes the behaviour of resolveServices.
+type resolveServicesOptions struct {
+	serviceNames []string
+	playlistName string
+	// requireOne requires that at least one of serviceNames or playlistName is provided.
+	requireOne bool
+	// withDependencies causes the dependencies of each service to be resolved transitively
+	// and included in the returned services.
+	withDependencies bool
+}
+
 // resolveServices resolves a list of services from either a list of service names or a playlist name.
 //
 // If both serivceNames and playlistName are provided, an error will be returned. Mixing service names
@@ -558,51 +1304,86 @@ func (e *Engine) nuke(ctx context.Context, opts NukeOptions, op errors.Op) error
 // In this case, if requireOne is true, an error will be returned since at least one of serviceNames or playlistName
 // was required. Otherwise, both the returned slice and error will be nil, which can be treated as an empty slice
 // of services.
-func (e *Engine) resolveServices(op errors.Op, serviceNames []string, playlistName string, requireOne bool) ([]service.Service, error) {
-	if len(serviceNames) > 0 && playlistName != "" {
+//
+// If withDependencies is true, the dependencies of each service are also resolved, including the dependencies
+// of those dependencies. They are added after the requested services and each service is only included once.
+func (e *Engine) resolveServices(op errors.Op, opts resolveServicesOptions) ([]service.Service, error) {
+	if len(opts.serviceNames) > 0 && opts.playlistName != "" {
 		return nil, errors.New(errkind.Invalid, "both service names and playlist name provided", op)
 	}
-	if len(serviceNames) > 0 {
-		services := make([]service.Service, len(serviceNames))
-		for i, name := range serviceNames {
+	if len(opts.serviceNames) > 0 {
+		services := make([]service.Service, len(opts.serviceNames))
+		for i, name := range opts.serviceNames {
 			s, err := e.services.Get(name)
 			if err != nil {
 				return nil, errors.Wrap(err, errors.Meta{Reason: "unable to resolve service", Op: op})
 			}
 			services[i] = s
 		}
+		if opts.withDependencies {
+			return e.resolveDependencies(op, services)
+		}
 		return services, nil
 	}
-	if playlistName != "" {
-		serviceNames, err := e.playlists.ServiceNames(playlistName)
+	if opts.playlistName != "" {
+		serviceNames, err := e.playlists.ServiceNames(opts.playlistName)
 		if err != nil {
 			return nil, errors.Wrap(err, errors.Meta{Reason: "unable to resolve playlist", Op: op})
 		}
 		// Can just run resolveServices again with the service names to get the actual services.
-		return e.resolveServices(op, serviceNames, "", true)
+		return e.resolveServices(op, resolveServicesOptions{
+			serviceNames:     serviceNames,
+			requireOne:       true,
+			withDependencies: opts.withDependencies,
+		})
 	}
-	if requireOne {
+	if opts.requireOne {
 		return nil, errors.New(errkind.Invalid, "neither service names nor playlist name was provided", op)
 	}
 	// nil will be treated as an empty slice, which is fine since the caller said that no services is ok.
 	return nil, nil
 }
 
-// prepareGitRepos prepares the git repos for all services. Missing repos will always be cloned
-// to ensure that any files referenced in the docker-compose.yml file exist.
-// Repos will be pulled if skipPull is false.
-func (e *Engine) prepareGitRepos(ctx context.Context, op errors.Op, skipPull bool) error {
-	tracker := progress.TrackerFromContext(ctx)
-	tracker.Debug("Preparing Git repos for services")
+// resolveDependencies returns services along with all the services they depend on transitively.
+// Each service will only be included once, even if it is depended on by multiple services.
+func (e *Engine) resolveDependencies(op errors.Op, services []service.Service) ([]service.Service, error) {
+	// Dependencies are usually container names since that is what the @<service> variable
+	// expands to, so create a lookup of container name to service.
+	containerNames := make(map[string]service.Service, e.services.Len())
+	for it := e.services.Iter(); it.Next(); {
+		s := it.Value()
+		containerNames[docker.NormalizeName(s.FullName())] = s
+	}
 
-	// action determins the type of action to take for a repo. If clone is true, it is cloned,
-	// otherwise it is pulled.
-	type action struct {
-		repo  string
-		path  string
-		clone bool
+	seen := make(map[string]bool)
+	for _, s := range services {
+		seen[s.FullName()] = true
 	}
-	var actions []action
+	// services is used as a queue, dependencies get appended to the end as they are found
+	// which will cause their dependencies to be resolved as well.
+	for i := 0; i < len(services); i++ {
+		s := services[i]
+		for _, dep := range s.Dependencies {
+			ds, ok := containerNames[docker.NormalizeName(dep)]
+			if !ok {
+				msg := fmt.Sprintf("service %s has unknown dependency %s", s.FullName(), dep)
+				return nil, errors.New(errkind.Invalid, msg, op)
+			}
+			if seen[ds.FullName()] {
+				continue
+			}
+			seen[ds.FullName()] = true
+			services = append(services, ds)
+		}
+	}
+	return services, nil
+}
+
+// planGitRepos determines the actions needed to prepare the git repos for all services.
+// Missing repos will always be cloned to ensure that any files referenced in the docker-compose.yml
+// file exist. Repos will be pulled if skipPull is false.
+func (e *Engine) planGitRepos(op errors.Op, skipPull bool) ([]GitRepoAction, error) {
+	var actions []GitRepoAction
 	// Used to remove duplicates since multiple services could use the same repo, so we only
 	// want to clone/pull it once
 	seenRepos := make(map[string]bool)
@@ -619,7 +1400,7 @@ func (e *Engine) prepareGitRepos(ctx context.Context, op errors.Op, skipPull boo
 
 		repoPath := filepath.Join(e.workdir, reposDir, repo)
 		if !file.Exists(repoPath) {
-			actions = append(actions, action{repo, repoPath, true})
+			actions = append(actions, GitRepoAction{repo, repoPath, true})
 			continue
 		}
 
@@ -628,7 +1409,7 @@ func (e *Engine) prepareGitRepos(ctx context.Context, op errors.Op, skipPull boo
 		// Figure out a better way to do this
 		dirlen, err := file.DirLen(repoPath)
 		if err != nil {
-			return errors.Wrap(err, errors.Meta{
+			return nil, errors.Wrap(err, errors.Meta{
 				Kind:   errkind.IO,
 				Reason: fmt.Sprintf("could not read directory for git repo %s (%q)", repo, repoPath),
 				Op:     op,
@@ -636,21 +1417,21 @@ func (e *Engine) prepareGitRepos(ctx context.Context, op errors.Op, skipPull boo
 		}
 		// TODO(@cszatmary): Why 2? Is `.` returned by DirLen? Otherwise should be 1 since only .git
 		if dirlen <= 2 {
-			// Directory exists but only contains .git subdirectory, rm and clone again below
-			if err := os.RemoveAll(repoPath); err != nil {
-				return errors.Wrap(err, errors.Meta{
-					Kind:   errkind.IO,
-					Reason: fmt.Sprintf("could not remove directory for git repo %s (%q)", repo, repoPath),
-					Op:     op,
-				})
-			}
-			actions = append(actions, action{repo, repoPath, true})
+			// Directory exists but only contains .git subdirectory, it will be removed and cloned again
+			actions = append(actions, GitRepoAction{repo, repoPath, true})
 			continue
 		}
 		if !skipPull {
-			actions = append(actions, action{repo, repoPath, false})
+			actions = append(actions, GitRepoAction{repo, repoPath, false})
 		}
 	}
+	return actions, nil
+}
+
+// prepareGitRepos performs the given actions to prepare the git repos for services.
+func (e *Engine) prepareGitRepos(ctx context.Context, op errors.Op, actions []GitRepoAction) error {
+	tracker := progress.TrackerFromContext(ctx)
+	tracker.Debug("Preparing Git repos for services")
 	if len(actions) == 0 {
 		return nil
 	}
@@ -661,27 +1442,35 @@ func (e *Engine) prepareGitRepos(ctx context.Context, op errors.Op, skipPull boo
 		Concurrency: e.concurrency,
 	}, func(ctx context.Context, i int) error {
 		a := actions[i]
-		if a.clone {
-			tracker.Debugf("Cloning git repo %s", a.repo)
-			err := e.gitClient.Clone(ctx, a.repo, a.path)
+		if a.Clone {
+			// Remove any partially cloned repo first, this is a no-op if it doesn't exist.
+			if err := os.RemoveAll(a.Path); err != nil {
+				return errors.Wrap(err, errors.Meta{
+					Kind:   errkind.IO,
+					Reason: fmt.Sprintf("could not remove directory for git repo %s (%q)", a.Repo, a.Path),
+					Op:     op,
+				})
+			}
+			tracker.Debugf("Cloning git repo %s", a.Repo)
+			err := e.gitClient.Clone(ctx, a.Repo, a.Path)
 			if err != nil {
 				return errors.Wrap(err, errors.Meta{
-					Reason: fmt.Sprintf("failed to clone git repo %s", a.repo),
+					Reason: fmt.Sprintf("failed to clone git repo %s", a.Repo),
 					Op:     op,
 				})
 			}
-			tracker.Debugf("Cloned git repo %s", a.repo)
+			tracker.Debugf("Cloned git repo %s", a.Repo)
 			return nil
 		}
-		tracker.Debugf("Pulling git repo %s", a.repo)
-		err := e.gitClient.Pull(ctx, a.path)
+		tracker.Debugf("Pulling git repo %s", a.Repo)
+		err := e.gitClient.Pull(ctx, a.Path)
 		if err != nil {
 			return errors.Wrap(err, errors.Meta{
-				Reason: fmt.Sprintf("failed to pull git repo %s", a.repo),
+				Reason: fmt.Sprintf("failed to pull git repo %s", a.Repo),
 				Op:     op,
 			})
 		}
-		tracker.Debugf("Pulled git repo %s", a.repo)
+		tracker.Debugf("Pulled git repo %s", a.Repo)
 		return nil
 	})
 	if err != nil {
@@ -694,7 +1483,14 @@ func (e *Engine) prepareGitRepos(ctx context.Context, op errors.Op, skipPull boo
 func (e *Engine) writeComposeFile(ctx context.Context, op errors.Op) error {
 	tracker := progress.TrackerFromContext(ctx)
 	tracker.Debug("Generating docker-compose.yml file")
-	composePath := filepath.Join(e.workdir, docker.ComposeFilename)
+	if err := os.MkdirAll(e.composeDir, 0o755); err != nil {
+		return errors.Wrap(err, errors.Meta{
+			Kind:   errkind.IO,
+			Reason: fmt.Sprintf("failed to create directory %s", e.composeDir),
+			Op:     op,
+		})
+	}
+	composePath := filepath.Join(e.composeDir, docker.ComposeFilename)
 	f, err := os.OpenFile(composePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
 	if err != nil {
 		return errors.Wrap(err, errors.Meta{
@@ -714,7 +1510,7 @@ func (e *Engine) writeComposeFile(ctx context.Context, op errors.Op) error {
 		})
 	}
 
-	composeConfig := service.ComposeConfig(e.services)
+	composeConfig := service.ComposeConfig(e.services, e.composeOptions())
 	if err := yaml.NewEncoder(f).Encode(composeConfig); err != nil {
 		return errors.Wrap(err, errors.Meta{
 			Kind:   errkind.IO,
@@ -741,6 +1537,111 @@ func (e *Engine) stopServices(ctx context.Context, op errors.Op, services []serv
 	return nil
 }
 
+// runPreRuns runs the pre-run steps for each wave of services. Services within a wave
+// run concurrently, and a wave is only started once all the previous waves have succeeded.
+// This ensures a service's pre-run only runs after the pre-runs of its dependencies.
+func (e *Engine) runPreRuns(ctx context.Context, op errors.Op, waves [][]service.Service) error {
+	tracker := progress.TrackerFromContext(ctx)
+	var preRunWaves [][]service.Service
+	for _, wave := range waves {
+		var preRunServices []service.Service
+		for _, s := range wave {
+			if s.PreRun == "" {
+				tracker.Debugf("No pre-run for %s, skipping", s.FullName())
+				continue
+			}
+			preRunServices = append(preRunServices, s)
+		}
+		if len(preRunServices) > 0 {
+			preRunWaves = append(preRunWaves, preRunServices)
+		}
+	}
+	if len(preRunWaves) == 0 {
+		return nil
+	}
+
+	for i, wave := range preRunWaves {
+		err := progress.RunParallel(ctx, progress.RunParallelOptions{
+			Message:     fmt.Sprintf("Performing pre-run step for services [%d/%d] (this may take a long time)", i+1, len(preRunWaves)),
+			Count:       len(wave),
+			Concurrency: e.concurrency,
+		}, func(ctx context.Context, i int) error {
+			s := wave[i]
+			tracker.Debugf("Running pre-run for %s", s.FullName())
+			if err := e.dockerClient.RunService(ctx, s.FullName(), s.PreRun); err != nil {
+				return errors.Wrap(err, errors.Meta{
+					Reason: fmt.Sprintf("failed to run pre-run command for %s", s.FullName()),
+					Op:     op,
+				})
+			}
+			tracker.Debugf("Ran pre-run for %s", s.FullName())
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	tracker.Info("✔ Performed pre-run step for services")
+	return nil
+}
+
+// readyPollInterval is how often to check if a service is ready.
+const readyPollInterval = time.Second
+
+// waitForServices waits until each service is ready. If a service does not become ready
+// within timeout or it fails, an error naming the service will be returned.
+func (e *Engine) waitForServices(ctx context.Context, op errors.Op, services []service.Service, timeout time.Duration) error {
+	tracker := progress.TrackerFromContext(ctx)
+	err := progress.RunParallel(ctx, progress.RunParallelOptions{
+		Message: "Waiting for services to become ready",
+		Count:   len(services),
+		// Don't limit concurrency since this is just polling and we want
+		// all services to be checked against the same deadline.
+		Concurrency: len(services),
+		// Give RunParallel some leeway so that each service's deadline is hit first
+		// and a useful error naming the service is returned.
+		Timeout: timeout + time.Minute,
+	}, func(ctx context.Context, i int) error {
+		s := services[i]
+		deadline := time.Now().Add(timeout)
+		for {
+			c, err := e.dockerClient.InspectServiceContainer(ctx, s.FullName())
+			if err != nil {
+				return errors.Wrap(err, errors.Meta{
+					Reason: fmt.Sprintf("unable to determine if %s is ready", s.FullName()),
+					Op:     op,
+				})
+			}
+			tracker.Debugf("Service %s is %s (health: %s)", s.FullName(), c.State, c.Health)
+			if strings.EqualFold(c.State, docker.ContainerStateExited) || strings.EqualFold(c.State, "dead") {
+				msg := fmt.Sprintf("service %s stopped before becoming ready, container is %s", s.FullName(), c.State)
+				return errors.New(errkind.Docker, msg, op)
+			}
+			switch {
+			case c.Health == docker.HealthUnhealthy:
+				msg := fmt.Sprintf("service %s is unhealthy, check its logs with 'tb logs %s'", s.FullName(), s.Name)
+				return errors.New(errkind.Docker, msg, op)
+			case c.Running() && (c.Health == docker.HealthHealthy || c.Health == docker.HealthNone):
+				tracker.Infof("✔ %s is ready", s.FullName())
+				return nil
+			}
+			if time.Now().After(deadline) {
+				msg := fmt.Sprintf("timed out after %s waiting for service %s to become ready", timeout, s.FullName())
+				return errors.New(errkind.Docker, msg, op)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(readyPollInterval):
+			}
+		}
+	})
+	if err != nil {
+		return errors.Wrap(err, errors.Meta{Reason: "services failed to become ready", Op: op})
+	}
+	return nil
+}
+
 func getServiceNames(services []service.Service) []string {
 	sn := make([]string, len(services))
 	for i, s := range services {
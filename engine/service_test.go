@@ -2,10 +2,17 @@ package engine_test
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/TouchBistro/goutils/errors"
 	"github.com/TouchBistro/tb/engine"
+	"github.com/TouchBistro/tb/errkind"
 	"github.com/TouchBistro/tb/integrations/docker"
 	"github.com/TouchBistro/tb/integrations/git"
 	"github.com/TouchBistro/tb/resource"
@@ -132,6 +139,595 @@ func TestDown(t *testing.T) {
 	}
 }
 
+func TestStopStartRestart(t *testing.T) {
+	dockerAPIClient := docker.NewMockAPIClient(docker.MockAPIClientOptions{
+		Containers: []dockertypes.Container{
+			{
+				ID:    "32ce4d8d9c648dd5fce39cf48319da8d55b195513b6fe0cef4a425de9380590c",
+				Names: []string{"touchbistro-tb-registry-postgres"},
+				Labels: map[string]string{
+					docker.ProjectLabel: "tb",
+				},
+				State: docker.ContainerStateRunning,
+			},
+		},
+	})
+	e := newEngine(t, engine.Options{
+		Services: newServiceCollection(t, nil),
+		DockerOptions: docker.Options{
+			APIClient: dockerAPIClient,
+		},
+	})
+	containerStates := func() map[string]string {
+		t.Helper()
+		containers, err := dockerAPIClient.ContainerList(context.Background(), dockertypes.ContainerListOptions{All: true})
+		if err != nil {
+			t.Fatalf("failed to list containers: %v", err)
+		}
+		states := make(map[string]string)
+		for _, c := range containers {
+			states[c.Names[0]] = c.State
+		}
+		return states
+	}
+
+	ctx := context.Background()
+	is := is.New(t)
+	err := e.Stop(ctx, engine.StopOptions{ServiceNames: []string{"TouchBistro/tb-registry/postgres"}})
+	is.NoErr(err)
+	is.Equal(containerStates(), map[string]string{"touchbistro-tb-registry-postgres": docker.ContainerStateExited})
+
+	err = e.Start(ctx, engine.StartOptions{ServiceNames: []string{"TouchBistro/tb-registry/postgres"}})
+	is.NoErr(err)
+	is.Equal(containerStates(), map[string]string{"touchbistro-tb-registry-postgres": docker.ContainerStateRunning})
+
+	err = e.Restart(ctx, engine.RestartOptions{})
+	is.NoErr(err)
+	is.Equal(containerStates(), map[string]string{"touchbistro-tb-registry-postgres": docker.ContainerStateRunning})
+
+	// Services without a container cannot be started or restarted since they need to be created by Up.
+	err = e.Restart(ctx, engine.RestartOptions{ServiceNames: []string{"touchbistro-node-boilerplate"}})
+	var errsErr *errors.Error
+	is.True(errors.As(err, &errsErr))
+	is.Equal(errsErr.Kind, errkind.Invalid)
+}
+
+func TestUpInvalidDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []service.Service
+	}{
+		{
+			name: "unknown dependency",
+			services: []service.Service{
+				{
+					Dependencies: []string{"touchbistro-tb-registry-redis"},
+					Mode:         service.ModeRemote,
+					Remote:       service.Remote{Image: "venue-core-service"},
+					Name:         "venue-core-service",
+					RegistryName: "TouchBistro/tb-registry",
+				},
+			},
+		},
+		{
+			name: "transitive dependency cycle",
+			services: []service.Service{
+				{
+					Dependencies: []string{"touchbistro-tb-registry-postgres"},
+					Mode:         service.ModeRemote,
+					Remote:       service.Remote{Image: "venue-core-service"},
+					Name:         "venue-core-service",
+					RegistryName: "TouchBistro/tb-registry",
+				},
+				{
+					Dependencies: []string{"touchbistro-tb-registry-localstack"},
+					Mode:         service.ModeRemote,
+					Remote:       service.Remote{Image: "postgres"},
+					Name:         "postgres",
+					RegistryName: "TouchBistro/tb-registry",
+				},
+				{
+					Dependencies: []string{"touchbistro-tb-registry-postgres"},
+					Mode:         service.ModeRemote,
+					Remote:       service.Remote{Image: "localstack"},
+					Name:         "localstack",
+					RegistryName: "TouchBistro/tb-registry",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEngine(t, engine.Options{
+				Services: newServiceCollection(t, tt.services),
+			})
+			// Only the first service is requested, the problem must be found by resolving dependencies.
+			_, err := e.Up(context.Background(), engine.UpOptions{
+				ServiceNames: []string{"venue-core-service"},
+			})
+			is := is.New(t)
+			is.True(err != nil)
+			var errsErr *errors.Error
+			is.True(errors.As(err, &errsErr))
+			is.Equal(errsErr.Kind, errkind.Invalid)
+		})
+	}
+}
+
+func TestUpUnchangedServices(t *testing.T) {
+	dockerAPIClient := docker.NewMockAPIClient(docker.MockAPIClientOptions{
+		Images: []dockertypes.ImageSummary{
+			{
+				ID:       "sha256:ed83a64c4a7bbd5aa8e2dbb1dd2aa1ba2bf4d1ec4c4deaf4fa62ba8bcae3a1c9",
+				RepoTags: []string{"postgres:12"},
+			},
+		},
+	})
+	workdir := t.TempDir()
+	newServices := func(httpPort, postgresUser string) *resource.Collection[service.Service] {
+		return newServiceCollection(t, []service.Service{
+			{
+				Dependencies: []string{"touchbistro-tb-registry-postgres"},
+				EnvVars:      map[string]string{"HTTP_PORT": httpPort},
+				Mode:         service.ModeBuild,
+				PreRun:       "yarn db:prepare",
+				Build:        service.Build{DockerfilePath: ".tb/repos/TouchBistro/venue-core-service"},
+				Name:         "venue-core-service",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+			{
+				EnvVars:      map[string]string{"POSTGRES_USER": postgresUser},
+				Mode:         service.ModeRemote,
+				PreRun:       "psql -c 'select 1'",
+				Remote:       service.Remote{Image: "postgres", Tag: "12"},
+				Name:         "postgres",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+		})
+	}
+	up := func(services *resource.Collection[service.Service], skipPreRun bool) engine.UpPlan {
+		t.Helper()
+		e := newEngine(t, engine.Options{
+			Workdir:  workdir,
+			Services: services,
+			DockerOptions: docker.Options{
+				APIClient: dockerAPIClient,
+			},
+		})
+		plan, err := e.Up(context.Background(), engine.UpOptions{
+			ServiceNames:   []string{"venue-core-service"},
+			SkipDockerPull: true,
+			SkipPreRun:     skipPreRun,
+		})
+		if err != nil {
+			t.Fatalf("failed to start services: %v", err)
+		}
+		return plan
+	}
+	containerIDs := func() map[string]string {
+		t.Helper()
+		containers, err := dockerAPIClient.ContainerList(context.Background(), dockertypes.ContainerListOptions{All: true})
+		if err != nil {
+			t.Fatalf("failed to list containers: %v", err)
+		}
+		ids := make(map[string]string)
+		for _, c := range containers {
+			ids[c.Names[0]] = c.ID
+		}
+		return ids
+	}
+
+	is := is.New(t)
+	plan := up(newServices("8080", "core"), false)
+	is.Equal(plan.Unchanged, nil)
+	is.Equal(len(plan.PreRuns), 2)
+	firstIDs := containerIDs()
+	is.Equal(len(firstIDs), 2)
+
+	// Nothing changed so everything should be left alone.
+	plan = up(newServices("8080", "core"), false)
+	is.Equal(plan.Unchanged, []string{
+		"TouchBistro/tb-registry/venue-core-service",
+		"TouchBistro/tb-registry/postgres",
+	})
+	is.Equal(plan.StopContainers, nil)
+	is.Equal(plan.PreRuns, nil)
+	is.Equal(containerIDs(), firstIDs)
+
+	// Changing the config of a service should only cause it to be recreated.
+	plan = up(newServices("8081", "core"), false)
+	is.Equal(plan.Unchanged, []string{"TouchBistro/tb-registry/postgres"})
+	is.Equal(plan.StopContainers, []string{"touchbistro-tb-registry-venue-core-service"})
+	is.Equal(plan.PreRuns, [][]engine.PreRunAction{
+		{{Service: "TouchBistro/tb-registry/venue-core-service", Command: "yarn db:prepare"}},
+	})
+	ids := containerIDs()
+	is.Equal(ids["touchbistro-tb-registry-postgres"], firstIDs["touchbistro-tb-registry-postgres"])
+	is.True(ids["touchbistro-tb-registry-venue-core-service"] != firstIDs["touchbistro-tb-registry-venue-core-service"])
+
+	// Changing a dependency should cause the services that depend on it to be recreated too.
+	plan = up(newServices("8081", "admin"), false)
+	is.Equal(plan.Unchanged, nil)
+	is.Equal(plan.PreRuns, [][]engine.PreRunAction{
+		{{Service: "TouchBistro/tb-registry/postgres", Command: "psql -c 'select 1'"}},
+		{{Service: "TouchBistro/tb-registry/venue-core-service", Command: "yarn db:prepare"}},
+	})
+
+	// Services started without running their pre-run steps should run them the next time.
+	plan = up(newServices("8082", "admin"), true)
+	is.Equal(plan.Unchanged, []string{"TouchBistro/tb-registry/postgres"})
+	is.Equal(plan.PreRuns, nil)
+	plan = up(newServices("8082", "admin"), false)
+	is.Equal(plan.Unchanged, []string{"TouchBistro/tb-registry/postgres"})
+	is.Equal(plan.PreRuns, [][]engine.PreRunAction{
+		{{Service: "TouchBistro/tb-registry/venue-core-service", Command: "yarn db:prepare"}},
+	})
+}
+
+func TestUpDryRun(t *testing.T) {
+	dockerAPIClient := docker.NewMockAPIClient(docker.MockAPIClientOptions{
+		Containers: []dockertypes.Container{
+			{
+				ID:    "32ce4d8d9c648dd5fce39cf48319da8d55b195513b6fe0cef4a425de9380590c",
+				Names: []string{"touchbistro-tb-registry-postgres"},
+				Labels: map[string]string{
+					docker.ProjectLabel: "tb",
+				},
+				State: docker.ContainerStateRunning,
+			},
+		},
+		Images: []dockertypes.ImageSummary{
+			{
+				ID:       "sha256:ed83a64c4a7bbd5aa8e2dbb1dd2aa1ba2bf4d1ec4c4deaf4fa62ba8bcae3a1c9",
+				RepoTags: []string{"postgres:12"},
+			},
+		},
+	})
+	workdir := t.TempDir()
+	e := newEngine(t, engine.Options{
+		Workdir: workdir,
+		Services: newServiceCollection(t, []service.Service{
+			{
+				Dependencies: []string{"touchbistro-tb-registry-postgres"},
+				Mode:         service.ModeBuild,
+				PreRun:       "yarn db:prepare",
+				GitRepo:      service.GitRepo{Name: "TouchBistro/venue-core-service"},
+				Build:        service.Build{DockerfilePath: ".tb/repos/TouchBistro/venue-core-service"},
+				Name:         "venue-core-service",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+			{
+				Dependencies: []string{"touchbistro-tb-registry-localstack"},
+				Mode:         service.ModeRemote,
+				PreRun:       "psql -c 'select 1'",
+				Remote:       service.Remote{Image: "postgres", Tag: "12"},
+				Name:         "postgres",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+			{
+				Mode:         service.ModeRemote,
+				Remote:       service.Remote{Image: "localstack/localstack"},
+				Name:         "localstack",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+		}),
+		BaseImages:      []string{"alpine:3.15"},
+		LoginStrategies: []string{"ecr"},
+		DockerOptions: docker.Options{
+			APIClient: dockerAPIClient,
+		},
+	})
+
+	is := is.New(t)
+	plan, err := e.Up(context.Background(), engine.UpOptions{
+		ServiceNames:   []string{"venue-core-service"},
+		SkipDockerPull: true,
+		DryRun:         true,
+	})
+	is.NoErr(err)
+	is.Equal(plan, engine.UpPlan{
+		Services: []string{
+			"TouchBistro/tb-registry/venue-core-service",
+			"TouchBistro/tb-registry/postgres",
+			"TouchBistro/tb-registry/localstack",
+		},
+		Logins: []string{"ecr"},
+		GitRepos: []engine.GitRepoAction{
+			{
+				Repo:  "TouchBistro/venue-core-service",
+				Path:  filepath.Join(workdir, "repos", "TouchBistro/venue-core-service"),
+				Clone: true,
+			},
+		},
+		BaseImages: []engine.ImageAction{
+			{Image: "alpine:3.15", Present: false, Pull: true},
+		},
+		ServiceImages: []engine.ImageAction{
+			{Image: "postgres:12", Present: true, Pull: false},
+			{Image: "localstack/localstack", Present: false, Pull: true},
+		},
+		Builds: []string{"TouchBistro/tb-registry/venue-core-service"},
+		PreRuns: [][]engine.PreRunAction{
+			{{Service: "TouchBistro/tb-registry/postgres", Command: "psql -c 'select 1'"}},
+			{{Service: "TouchBistro/tb-registry/venue-core-service", Command: "yarn db:prepare"}},
+		},
+		StopContainers: []string{"touchbistro-tb-registry-postgres"},
+	})
+
+	// Make sure nothing was changed
+	containers, err := dockerAPIClient.ContainerList(context.Background(), dockertypes.ContainerListOptions{All: true})
+	is.NoErr(err)
+	is.Equal(len(containers), 1)
+	_, err = os.Stat(filepath.Join(workdir, docker.ComposeFilename))
+	is.True(os.IsNotExist(err))
+}
+
+func TestWorkspaces(t *testing.T) {
+	dockerAPIClient := docker.NewMockAPIClient(docker.MockAPIClientOptions{
+		Images: []dockertypes.ImageSummary{
+			{
+				ID:       "sha256:ed83a64c4a7bbd5aa8e2dbb1dd2aa1ba2bf4d1ec4c4deaf4fa62ba8bcae3a1c9",
+				RepoTags: []string{"postgres:12"},
+			},
+		},
+	})
+	workdir := t.TempDir()
+	newWorkspaceEngine := func(workspace string, portOffset int) *engine.Engine {
+		return newEngine(t, engine.Options{
+			Workdir:    workdir,
+			Workspace:  workspace,
+			PortOffset: portOffset,
+			Services: newServiceCollection(t, []service.Service{
+				{
+					Mode:         service.ModeRemote,
+					Ports:        []string{"5432:5432"},
+					Remote:       service.Remote{Image: "postgres", Tag: "12"},
+					Name:         "postgres",
+					RegistryName: "TouchBistro/tb-registry",
+				},
+			}),
+			DockerOptions: docker.Options{APIClient: dockerAPIClient},
+		})
+	}
+	defaultEngine := newWorkspaceEngine("", 0)
+	featureEngine := newWorkspaceEngine("feature", 100)
+
+	is := is.New(t)
+	ctx := context.Background()
+	for _, e := range []*engine.Engine{defaultEngine, featureEngine} {
+		_, err := e.Up(ctx, engine.UpOptions{
+			ServiceNames:   []string{"postgres"},
+			SkipDockerPull: true,
+		})
+		is.NoErr(err)
+	}
+
+	// Each workspace has its own compose file.
+	b, err := os.ReadFile(filepath.Join(workdir, "workspaces", "feature", docker.ComposeFilename))
+	is.NoErr(err)
+	is.True(strings.Contains(string(b), "container_name: feature-touchbistro-tb-registry-postgres"))
+	is.True(strings.Contains(string(b), "5532:5432"))
+	b, err = os.ReadFile(filepath.Join(workdir, docker.ComposeFilename))
+	is.NoErr(err)
+	is.True(strings.Contains(string(b), "container_name: touchbistro-tb-registry-postgres"))
+	is.True(strings.Contains(string(b), "5432:5432"))
+
+	workspaces, err := defaultEngine.Workspaces()
+	is.NoErr(err)
+	is.Equal(workspaces, []string{"feature"})
+
+	// Each workspace only sees its own containers.
+	statuses, err := featureEngine.Status(ctx, engine.StatusOptions{})
+	is.NoErr(err)
+	is.Equal(len(statuses), 1)
+	is.True(statuses[0].HasContainer)
+
+	// Nuking a workspace leaves other workspaces alone.
+	err = featureEngine.Nuke(ctx, engine.NukeOptions{RemoveContainers: true})
+	is.NoErr(err)
+	_, err = os.Stat(filepath.Join(workdir, "workspaces", "feature"))
+	is.True(errors.Is(err, os.ErrNotExist))
+	statuses, err = featureEngine.Status(ctx, engine.StatusOptions{})
+	is.NoErr(err)
+	is.Equal(len(statuses), 0)
+	statuses, err = defaultEngine.Status(ctx, engine.StatusOptions{})
+	is.NoErr(err)
+	is.Equal(len(statuses), 1)
+	workspaces, err = defaultEngine.Workspaces()
+	is.NoErr(err)
+	is.Equal(len(workspaces), 0)
+
+	// Shared resources cannot be removed for a single workspace.
+	err = featureEngine.Nuke(ctx, engine.NukeOptions{RemoveRepos: true})
+	var errsErr *errors.Error
+	is.True(errors.As(err, &errsErr))
+	is.Equal(errsErr.Kind, errkind.Invalid)
+}
+
+func TestNewInvalidWorkspace(t *testing.T) {
+	tests := []struct {
+		name       string
+		workspace  string
+		portOffset int
+	}{
+		{"invalid name", "Feature/X", 0},
+		{"port out of range", "feature", 65000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			_, err := engine.New(engine.Options{
+				Workdir:    t.TempDir(),
+				Workspace:  tt.workspace,
+				PortOffset: tt.portOffset,
+				Services: newServiceCollection(t, []service.Service{
+					{
+						Mode:         service.ModeRemote,
+						Ports:        []string{"5432:5432"},
+						Remote:       service.Remote{Image: "postgres"},
+						Name:         "postgres",
+						RegistryName: "TouchBistro/tb-registry",
+					},
+				}),
+				GitClient: git.NewMock(),
+				DockerOptions: docker.Options{
+					APIClient: docker.NewMockAPIClient(docker.MockAPIClientOptions{}),
+					Config:    docker.NewMockConfig(nil),
+				},
+			})
+			var errsErr *errors.Error
+			is.True(errors.As(err, &errsErr))
+			is.Equal(errsErr.Kind, errkind.Invalid)
+		})
+	}
+}
+
+func TestWatchInvalidServices(t *testing.T) {
+	tests := []struct {
+		name         string
+		serviceNames []string
+	}{
+		{
+			name:         "service not in build mode",
+			serviceNames: []string{"TouchBistro/tb-registry/postgres"},
+		},
+		{
+			// The build context of the default services is not created.
+			name:         "missing build context",
+			serviceNames: []string{"touchbistro-node-boilerplate"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEngine(t, engine.Options{
+				Services: newServiceCollection(t, nil),
+			})
+			err := e.Watch(context.Background(), engine.WatchOptions{ServiceNames: tt.serviceNames})
+			is := is.New(t)
+			var errsErr *errors.Error
+			is.True(errors.As(err, &errsErr))
+			is.Equal(errsErr.Kind, errkind.Invalid)
+		})
+	}
+}
+
+func TestWatchRebuildsService(t *testing.T) {
+	workdir := t.TempDir()
+	buildDir := filepath.Join(workdir, ".tb/repos/TouchBistro/touchbistro-node-boilerplate")
+	if err := os.MkdirAll(buildDir, 0o755); err != nil {
+		t.Fatalf("failed to create build context %s: %v", buildDir, err)
+	}
+	e := newEngine(t, engine.Options{
+		Workdir:  workdir,
+		Services: newServiceCollection(t, nil),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- e.Watch(ctx, engine.WatchOptions{
+			ServiceNames: []string{"touchbistro-node-boilerplate"},
+			Debounce:     10 * time.Millisecond,
+		})
+	}()
+
+	// Change a file until the service has been rebuilt, since changes made before Watch has taken
+	// its first snapshot of the build context are not detected. The rebuild records the fingerprint.
+	fingerprintsPath := filepath.Join(workdir, "fingerprints.json")
+	rebuilt := func() bool {
+		b, err := os.ReadFile(fingerprintsPath)
+		return err == nil && strings.Contains(string(b), "TouchBistro/tb-registry/touchbistro-node-boilerplate")
+	}
+	for i := 0; !rebuilt(); i++ {
+		if ctx.Err() != nil {
+			t.Fatal("timed out waiting for the service to be rebuilt")
+		}
+		if err := os.WriteFile(filepath.Join(buildDir, "index.js"), []byte(fmt.Sprintf("console.log(%d)", i)), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		// Wait longer than the interval Watch polls at so that the change is picked up.
+		for j := 0; j < 20 && !rebuilt(); j++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	cancel()
+	is := is.New(t)
+	is.NoErr(<-done)
+
+	// The service was rebuilt and started.
+	statuses, err := e.Status(context.Background(), engine.StatusOptions{
+		ServiceNames: []string{"touchbistro-node-boilerplate"},
+	})
+	is.NoErr(err)
+	is.Equal(len(statuses), 1)
+	is.True(statuses[0].HasContainer)
+	is.Equal(statuses[0].State, "running")
+}
+
+func TestStatus(t *testing.T) {
+	dockerAPIClient := docker.NewMockAPIClient(docker.MockAPIClientOptions{
+		Containers: []dockertypes.Container{
+			{
+				ID:    "32ce4d8d9c648dd5fce39cf48319da8d55b195513b6fe0cef4a425de9380590c",
+				Names: []string{"touchbistro-tb-registry-postgres"},
+				Image: "postgres:12-alpine",
+				Labels: map[string]string{
+					docker.ProjectLabel: "tb",
+				},
+				Ports: []dockertypes.Port{
+					{IP: "0.0.0.0", PrivatePort: 5432, PublicPort: 5432, Type: "tcp"},
+					{IP: "::", PrivatePort: 5432, PublicPort: 5432, Type: "tcp"},
+				},
+				State:  docker.ContainerStateRunning,
+				Status: "Up 2 minutes (healthy)",
+			},
+			// Additional container not part of tb to make sure it is ignored.
+			{
+				ID:    "e8dc7c16f7dd4be23b96951a34b7ecc69cd727ed13a626a309a96b472646c5e9",
+				Names: []string{"test-ubuntu"},
+				State: docker.ContainerStateRunning,
+			},
+		},
+	})
+	e := newEngine(t, engine.Options{
+		Services: newServiceCollection(t, nil),
+		DockerOptions: docker.Options{
+			APIClient: dockerAPIClient,
+		},
+	})
+	postgresStatus := engine.ServiceStatus{
+		Name:         "TouchBistro/tb-registry/postgres",
+		Mode:         service.ModeRemote,
+		Image:        "postgres:12-alpine",
+		HasContainer: true,
+		State:        "running",
+		Health:       docker.HealthHealthy,
+		Ports:        []string{"5432:5432/tcp"},
+	}
+
+	ctx := context.Background()
+	is := is.New(t)
+	statuses, err := e.Status(ctx, engine.StatusOptions{})
+	is.NoErr(err)
+	is.Equal(statuses, []engine.ServiceStatus{postgresStatus})
+
+	statuses, err = e.Status(ctx, engine.StatusOptions{All: true})
+	is.NoErr(err)
+	is.Equal(statuses, []engine.ServiceStatus{
+		{
+			Name:  "ExampleZone/tb-registry/postgres",
+			Mode:  service.ModeRemote,
+			Image: "postgres:12",
+		},
+		postgresStatus,
+		{
+			Name: "TouchBistro/tb-registry/touchbistro-node-boilerplate",
+			Mode: service.ModeBuild,
+		},
+	})
+}
+
 func TestList(t *testing.T) {
 	tests := []struct {
 		name string
@@ -204,6 +800,25 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestListShadowed(t *testing.T) {
+	sc := newServiceCollection(t, nil)
+	sc.SetPriority("TouchBistro/tb-registry", 1)
+	pc := newPlaylistCollection(t, nil, []playlist.Playlist{{Name: "backend"}})
+	e := newEngine(t, engine.Options{
+		Services:  sc,
+		Playlists: pc,
+	})
+
+	result := e.List(engine.ListOptions{ListServices: true, ListPlaylists: true})
+	is := is.New(t)
+	is.Equal(result.ShadowedServices, map[string]string{
+		"ExampleZone/tb-registry/postgres": "TouchBistro/tb-registry/postgres",
+	})
+	is.Equal(result.Playlists, []engine.PlaylistSummary{
+		{Name: "TouchBistro/tb-registry/backend", ShadowedBy: "backend"},
+	})
+}
+
 func TestNuke(t *testing.T) {
 	tests := []struct {
 		name              string
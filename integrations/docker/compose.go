@@ -177,16 +177,17 @@ type ComposeConfig struct {
 }
 
 type ComposeServiceConfig struct {
-	Build         ComposeBuildConfig `yaml:"build,omitempty"` // non-remote
-	Command       string             `yaml:"command,omitempty"`
-	ContainerName string             `yaml:"container_name"`
-	DependsOn     []string           `yaml:"depends_on,omitempty"`
-	Entrypoint    []string           `yaml:"entrypoint,omitempty"`
-	EnvFile       []string           `yaml:"env_file,omitempty"`
-	Environment   map[string]string  `yaml:"environment,omitempty"`
-	Image         string             `yaml:"image,omitempty"` // remote
-	Ports         []string           `yaml:"ports,omitempty"`
-	Volumes       []string           `yaml:"volumes,omitempty"`
+	Build         ComposeBuildConfig       `yaml:"build,omitempty"` // non-remote
+	Command       string                   `yaml:"command,omitempty"`
+	ContainerName string                   `yaml:"container_name"`
+	DependsOn     []string                 `yaml:"depends_on,omitempty"`
+	Entrypoint    []string                 `yaml:"entrypoint,omitempty"`
+	EnvFile       []string                 `yaml:"env_file,omitempty"`
+	Environment   map[string]string        `yaml:"environment,omitempty"`
+	Healthcheck   ComposeHealthcheckConfig `yaml:"healthcheck,omitempty"`
+	Image         string                   `yaml:"image,omitempty"` // remote
+	Ports         []string                 `yaml:"ports,omitempty"`
+	Volumes       []string                 `yaml:"volumes,omitempty"`
 }
 
 type ComposeBuildConfig struct {
@@ -194,3 +195,10 @@ type ComposeBuildConfig struct {
 	Context string            `yaml:"context,omitempty"`
 	Target  string            `yaml:"target,omitempty"`
 }
+
+type ComposeHealthcheckConfig struct {
+	Test        []string `yaml:"test,omitempty"`
+	Interval    string   `yaml:"interval,omitempty"`
+	Retries     int      `yaml:"retries,omitempty"`
+	StartPeriod string   `yaml:"start_period,omitempty"`
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/TouchBistro/goutils/errors"
 	"github.com/TouchBistro/goutils/progress"
 	"github.com/TouchBistro/tb/errkind"
+	"github.com/TouchBistro/tb/internal/util"
 	dockerconfig "github.com/docker/cli/cli/config"
 	configtypes "github.com/docker/cli/cli/config/types"
 	"github.com/docker/distribution/reference"
@@ -25,6 +26,9 @@ import (
 	"github.com/docker/docker/registry"
 )
 
+// ErrNotFound is returned when a docker resource does not exist.
+const ErrNotFound errors.String = "docker resource not found"
+
 // These are the states we care about.
 // There are others but they are not used by tb.
 const (
@@ -36,6 +40,18 @@ const (
 	ContainerStateExited = "Exited"
 )
 
+// Health statuses reported by docker for containers.
+const (
+	// HealthStarting indicates the container is still within its start period.
+	HealthStarting = "starting"
+	// HealthHealthy indicates the container's healthcheck is passing.
+	HealthHealthy = "healthy"
+	// HealthUnhealthy indicates the container's healthcheck failed too many times.
+	HealthUnhealthy = "unhealthy"
+	// HealthNone indicates the container has no healthcheck configured.
+	HealthNone = "none"
+)
+
 // Docker labels for use in lookups
 const (
 	// ProjectLabel is a docker label that specifies the compose project.
@@ -78,8 +94,9 @@ type Config interface {
 
 // Docker provides functionality for working with docker resources.
 type Docker struct {
-	project   ComposeProject
-	apiClient APIClient
+	project         ComposeProject
+	apiClient       APIClient
+	containerPrefix string // prepended to container names
 
 	config                 Config // docker config; for registry auth
 	defaultRegistryAddress string // used to resolve creds for dockerhub
@@ -94,6 +111,9 @@ type Options struct {
 	// Config is the docker config to use to resolve things like registry auth.
 	// If omitted, the default docker config will be loaded.
 	Config Config
+	// ContainerNamePrefix is prepended to the normalized service name to get the name
+	// of the service's container. It must match the prefix used in the compose file.
+	ContainerNamePrefix string
 }
 
 // New returns a new Docker instance that provides docker functionality for tb.
@@ -130,8 +150,9 @@ func New(projectName, workdir string, opts Options) (*Docker, error) {
 			Name:    projectName,
 			Workdir: workdir,
 		},
-		apiClient: opts.APIClient,
-		config:    opts.Config,
+		apiClient:       opts.APIClient,
+		config:          opts.Config,
+		containerPrefix: opts.ContainerNamePrefix,
 	}, nil
 }
 
@@ -187,6 +208,57 @@ func (d *Docker) StopContainers(ctx context.Context, serviceNames ...string) err
 	return nil
 }
 
+// StartContainers starts stopped containers matching the given service names.
+// If no names are provided, all stopped containers part of the project will be started.
+func (d *Docker) StartContainers(ctx context.Context, serviceNames ...string) error {
+	const op = errors.Op("docker.Docker.StartContainers")
+	containers, err := d.listContainers(ctx, serviceNames, true, op)
+	if err != nil {
+		return err
+	}
+
+	tracker := progress.TrackerFromContext(ctx)
+	for _, container := range containers {
+		if strings.EqualFold(container.State, ContainerStateRunning) {
+			continue
+		}
+		tracker.Debugf("Starting container %s", container.Names[0])
+		if err := d.apiClient.ContainerStart(ctx, container.ID, types.ContainerStartOptions{}); err != nil {
+			return errors.Wrap(err, errors.Meta{
+				Kind:   errkind.Docker,
+				Reason: fmt.Sprintf("failed to start container %s, %s", container.Names[0], container.ID),
+				Op:     op,
+			})
+		}
+	}
+	return nil
+}
+
+// RestartContainers restarts containers matching the given service names.
+// Stopped containers are started. If no names are provided, all containers
+// part of the project will be restarted.
+func (d *Docker) RestartContainers(ctx context.Context, serviceNames ...string) error {
+	const op = errors.Op("docker.Docker.RestartContainers")
+	containers, err := d.listContainers(ctx, serviceNames, true, op)
+	if err != nil {
+		return err
+	}
+
+	tracker := progress.TrackerFromContext(ctx)
+	timeout := 5 * time.Second
+	for _, container := range containers {
+		tracker.Debugf("Restarting container %s", container.Names[0])
+		if err := d.apiClient.ContainerRestart(ctx, container.ID, &timeout); err != nil {
+			return errors.Wrap(err, errors.Meta{
+				Kind:   errkind.Docker,
+				Reason: fmt.Sprintf("failed to restart container %s, %s", container.Names[0], container.ID),
+				Op:     op,
+			})
+		}
+	}
+	return nil
+}
+
 // RemoveContainers removes containers matching the given service names.
 // If no names, all containers part of the project will be removed.
 func (d *Docker) RemoveContainers(ctx context.Context, serviceNames ...string) error {
@@ -216,7 +288,7 @@ func (d *Docker) listContainers(ctx context.Context, serviceNames []string, stop
 	f := filters.NewArgs(projectFilter(d.project.Name))
 	if len(serviceNames) > 0 {
 		for _, n := range serviceNames {
-			f.Add("name", NormalizeName(n))
+			f.Add("name", d.ContainerName(n))
 		}
 	}
 	containers, err := d.apiClient.ContainerList(ctx, types.ContainerListOptions{
@@ -233,6 +305,118 @@ func (d *Docker) listContainers(ctx context.Context, serviceNames []string, stop
 	return containers, nil
 }
 
+// ServiceContainer contains details about the container for a service.
+type ServiceContainer struct {
+	ID   string
+	Name string
+	// Image is the name of the image the container was created from.
+	Image string
+	// State is the state of the container, ex: running or exited.
+	State string
+	// Health is the health status of the container.
+	// If the container has no healthcheck it will be HealthNone.
+	Health string
+	// StartedAt is the time the container was last started.
+	// It is the zero value if the container has never been started.
+	StartedAt time.Time
+	// RestartCount is the number of times docker has restarted the container.
+	RestartCount int
+	// Ports are the published ports of the container in the form HOST:CONTAINER/PROTOCOL.
+	// It is only set by ListServiceContainers.
+	Ports []string
+}
+
+// Running reports whether the container is running.
+func (sc ServiceContainer) Running() bool {
+	return strings.EqualFold(sc.State, ContainerStateRunning)
+}
+
+// InspectServiceContainer returns details about the container for the given service.
+// If no container exists for the service, ErrNotFound will be returned.
+func (d *Docker) InspectServiceContainer(ctx context.Context, serviceName string) (ServiceContainer, error) {
+	const op = errors.Op("docker.Docker.InspectServiceContainer")
+	c, err := d.apiClient.ContainerInspect(ctx, d.ContainerName(serviceName))
+	if errdefs.IsNotFound(err) {
+		return ServiceContainer{}, errors.Wrap(ErrNotFound, errors.Meta{
+			Kind:   errkind.Docker,
+			Reason: fmt.Sprintf("no container for service %s", serviceName),
+			Op:     op,
+		})
+	}
+	if err != nil {
+		return ServiceContainer{}, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Docker,
+			Reason: fmt.Sprintf("failed to inspect container for service %s", serviceName),
+			Op:     op,
+		})
+	}
+	return newServiceContainer(c), nil
+}
+
+// ListServiceContainers returns details about all containers that are part of the project,
+// including stopped containers. If serviceNames are provided, only containers for those services
+// will be returned.
+func (d *Docker) ListServiceContainers(ctx context.Context, serviceNames ...string) ([]ServiceContainer, error) {
+	const op = errors.Op("docker.Docker.ListServiceContainers")
+	containers, err := d.listContainers(ctx, serviceNames, true, op)
+	if err != nil {
+		return nil, err
+	}
+	scs := make([]ServiceContainer, 0, len(containers))
+	for _, container := range containers {
+		// Not all details are available when listing so we need to inspect each container.
+		c, err := d.apiClient.ContainerInspect(ctx, container.ID)
+		if errdefs.IsNotFound(err) {
+			// Container was removed after it was listed, nothing to report.
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, errors.Meta{
+				Kind:   errkind.Docker,
+				Reason: fmt.Sprintf("failed to inspect container %s", container.ID),
+				Op:     op,
+			})
+		}
+		sc := newServiceContainer(c)
+		var ports []string
+		for _, p := range container.Ports {
+			// Skip ports that are exposed but not published to the host.
+			if p.PublicPort == 0 {
+				continue
+			}
+			ports = append(ports, fmt.Sprintf("%d:%d/%s", p.PublicPort, p.PrivatePort, p.Type))
+		}
+		// Ports are listed for each host IP, ex: both 0.0.0.0 and ::, so remove duplicates.
+		sc.Ports = util.UniqueStrings(ports)
+		scs = append(scs, sc)
+	}
+	return scs, nil
+}
+
+// newServiceContainer creates a ServiceContainer from the result of inspecting a container.
+func newServiceContainer(c types.ContainerJSON) ServiceContainer {
+	sc := ServiceContainer{Health: HealthNone}
+	if c.ContainerJSONBase != nil {
+		sc.ID = c.ID
+		sc.Name = strings.TrimPrefix(c.Name, "/")
+		sc.RestartCount = c.RestartCount
+		if c.State != nil {
+			sc.State = c.State.Status
+			if c.State.Health != nil {
+				sc.Health = c.State.Health.Status
+			}
+			// Ignore errors since an invalid or missing time means the container was never started.
+			if t, err := time.Parse(time.RFC3339Nano, c.State.StartedAt); err == nil && t.Year() > 1 {
+				sc.StartedAt = t
+			}
+		}
+	}
+	if c.Config != nil {
+		sc.Image = c.Config.Image
+	}
+	return sc
+}
+
 // PullImage pulls the specified image from a remote registry.
 // imageName must be a valid image name either in normalized for or familiar form.
 //
@@ -327,6 +511,53 @@ func (d *Docker) PullImage(ctx context.Context, imageName string) error {
 	return nil
 }
 
+// ImageExists reports whether the specified image is present locally.
+// imageName must be a valid image name either in normalized for or familiar form.
+// If imageName has no tag, latest is assumed.
+func (d *Docker) ImageExists(ctx context.Context, imageName string) (bool, error) {
+	id, err := d.ImageID(ctx, ImageSearch{Name: imageName})
+	if err != nil {
+		return false, errors.Wrap(err, errors.Meta{Op: "docker.Docker.ImageExists"})
+	}
+	return id != "", nil
+}
+
+// ImageID returns the ID of the local image matching the given search.
+// If no image is found, an empty string is returned.
+// If the search is not for a local build and the image name has no tag, latest is assumed.
+func (d *Docker) ImageID(ctx context.Context, search ImageSearch) (string, error) {
+	const op = errors.Op("docker.Docker.ImageID")
+	var name string
+	if search.LocalBuild {
+		name = buildImageName(d.project.Name, NormalizeName(search.Name))
+	} else {
+		ref, err := reference.ParseNormalizedNamed(search.Name)
+		if err != nil {
+			return "", errors.Wrap(err, errors.Meta{
+				Kind:   errkind.Docker,
+				Reason: fmt.Sprintf("failed to parse image name %s", search.Name),
+				Op:     op,
+			})
+		}
+		// ImageList does not like the normalized name so use the familiar form, same as RemoveImages.
+		name = reference.FamiliarString(reference.TagNameOnly(ref))
+	}
+	images, err := d.apiClient.ImageList(ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(filters.Arg("reference", name)),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Docker,
+			Reason: "failed to list images",
+			Op:     op,
+		})
+	}
+	if len(images) == 0 {
+		return "", nil
+	}
+	return images[0].ID, nil
+}
+
 // ImageSearch is used to find an image for image related operations.
 type ImageSearch struct {
 	// Name is the name of the image. It is expected to be a valid docker image name
@@ -584,6 +815,11 @@ func (d *Docker) LogsFromServices(ctx context.Context, opts LogsFromServicesOpti
 	return nil
 }
 
+// ContainerName returns the name of the container for the given service.
+func (d *Docker) ContainerName(serviceName string) string {
+	return d.containerPrefix + NormalizeName(serviceName)
+}
+
 // buildImageName returns the name of an image that is built locally by compose.
 // It assumes serviceName has already been normalized.
 func buildImageName(projectName string, serviceName string) string {
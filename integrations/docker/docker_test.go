@@ -1,9 +1,12 @@
 package docker_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/TouchBistro/tb/integrations/docker"
+	dockertypes "github.com/docker/docker/api/types"
 	"github.com/matryer/is"
 )
 
@@ -27,3 +30,48 @@ func TestNormalizeName(t *testing.T) {
 		})
 	}
 }
+
+func TestInspectServiceContainer(t *testing.T) {
+	apiClient := docker.NewMockAPIClient(docker.MockAPIClientOptions{
+		Containers: []dockertypes.Container{
+			{
+				ID:     "32ce4d8d9c648dd5fce39cf48319da8d55b195513b6fe0cef4a425de9380590c",
+				Names:  []string{"touchbistro-tb-registry-postgres"},
+				Labels: map[string]string{docker.ProjectLabel: "tb"},
+				State:  docker.ContainerStateRunning,
+				Status: "Up 2 minutes (healthy)",
+			},
+			{
+				ID:     "f4d2913f1010244b61940cf52845e6dbe5d687791ea185237efe9121adf15edd",
+				Names:  []string{"touchbistro-tb-registry-redis"},
+				Labels: map[string]string{docker.ProjectLabel: "tb"},
+				State:  docker.ContainerStateRunning,
+				Status: "Up 2 minutes",
+			},
+		},
+	})
+	d, err := docker.New("tb", t.TempDir(), docker.Options{
+		APIClient: apiClient,
+		Config:    docker.NewMockConfig(nil),
+	})
+	is := is.New(t)
+	is.NoErr(err)
+
+	ctx := context.Background()
+	c, err := d.InspectServiceContainer(ctx, "TouchBistro/tb-registry/postgres")
+	is.NoErr(err)
+	is.Equal(c, docker.ServiceContainer{
+		ID:     "32ce4d8d9c648dd5fce39cf48319da8d55b195513b6fe0cef4a425de9380590c",
+		Name:   "touchbistro-tb-registry-postgres",
+		State:  "running",
+		Health: docker.HealthHealthy,
+	})
+	is.True(c.Running())
+
+	c, err = d.InspectServiceContainer(ctx, "TouchBistro/tb-registry/redis")
+	is.NoErr(err)
+	is.Equal(c.Health, docker.HealthNone)
+
+	_, err = d.InspectServiceContainer(ctx, "TouchBistro/tb-registry/localstack")
+	is.True(errors.Is(err, docker.ErrNotFound))
+}
@@ -3,10 +3,14 @@ package docker
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -14,9 +18,11 @@ import (
 	configtypes "github.com/docker/cli/cli/config/types"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	volumetypes "github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/registry"
+	"gopkg.in/yaml.v3"
 )
 
 // notFoundError implements the docker errdefs.ErrNotFound interface.
@@ -69,6 +75,8 @@ type mockAPIClient struct {
 
 	// map of server address to registry
 	registries map[string]MockRegistry
+	// Used to generate unique IDs for containers created by ComposeUp.
+	nextContainerID int
 }
 
 type MockRegistry struct {
@@ -208,6 +216,83 @@ func (m *mockAPIClient) ContainerStop(ctx context.Context, container string, tim
 	return nil
 }
 
+func (m *mockAPIClient) ContainerStart(ctx context.Context, container string, options types.ContainerStartOptions) error {
+	if container == "" {
+		return fmt.Errorf("container cannot be empty")
+	}
+	found, err := m.findContainerByID(container)
+	if err != nil {
+		return err
+	}
+	found.State = ContainerStateRunning
+	m.containers[container] = found
+	return nil
+}
+
+func (m *mockAPIClient) ContainerRestart(ctx context.Context, container string, timeout *time.Duration) error {
+	if container == "" {
+		return fmt.Errorf("container cannot be empty")
+	}
+	found, err := m.findContainerByID(container)
+	if err != nil {
+		return err
+	}
+	found.State = ContainerStateRunning
+	m.containers[container] = found
+	return nil
+}
+
+func (m *mockAPIClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	found, err := m.findContainerByID(containerID)
+	if err != nil {
+		// Docker allows inspecting by name as well so check that.
+		var ok bool
+		found, ok = m.findContainerByName(containerID)
+		if !ok {
+			return types.ContainerJSON{}, err
+		}
+	}
+	state := &types.ContainerState{
+		Status:  strings.ToLower(found.State),
+		Running: found.State == ContainerStateRunning,
+	}
+	if state.Running && found.Created > 0 {
+		// Not exactly accurate but good enough since the mock doesn't track when containers start.
+		state.StartedAt = time.Unix(found.Created, 0).UTC().Format(time.RFC3339Nano)
+	}
+	// The health status is part of the human readable status, ex: 'Up 2 minutes (healthy)'.
+	for _, h := range []string{HealthStarting, HealthHealthy, HealthUnhealthy} {
+		if strings.Contains(found.Status, "("+h+")") {
+			state.Health = &types.Health{Status: h}
+			break
+		}
+	}
+	var name string
+	if len(found.Names) > 0 {
+		name = "/" + found.Names[0]
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:    found.ID,
+			Name:  name,
+			Image: found.ImageID,
+			State: state,
+		},
+		Config: &container.Config{Image: found.Image, Labels: found.Labels},
+	}, nil
+}
+
+func (m *mockAPIClient) findContainerByName(name string) (types.Container, bool) {
+	for _, c := range m.containers {
+		for _, n := range c.Names {
+			if n == name {
+				return c, true
+			}
+		}
+	}
+	return types.Container{}, false
+}
+
 func (m *mockAPIClient) findContainerByID(id string) (types.Container, error) {
 	if id == "" {
 		return types.Container{}, fmt.Errorf("container cannot be empty")
@@ -456,3 +541,85 @@ func checkLabelFilters(labels map[string]string, labelFilters []string) bool {
 	}
 	return true
 }
+
+// Compose functionality is faked by reading the compose file in the project workdir and creating
+// the corresponding docker resources directly.
+
+func (m *mockAPIClient) readComposeFile(project ComposeProject) (ComposeConfig, error) {
+	var composeConfig ComposeConfig
+	b, err := os.ReadFile(filepath.Join(project.Workdir, ComposeFilename))
+	if err != nil {
+		return composeConfig, err
+	}
+	err = yaml.Unmarshal(b, &composeConfig)
+	return composeConfig, err
+}
+
+func (m *mockAPIClient) ComposeBuild(ctx context.Context, project ComposeProject, services []string) error {
+	composeConfig, err := m.readComposeFile(project)
+	if err != nil {
+		return err
+	}
+	for _, name := range services {
+		if _, ok := composeConfig.Services[name]; !ok {
+			return fmt.Errorf("no such service: %s", name)
+		}
+		// Use a deterministic ID so that rebuilding the same service results in the same image
+		// like it would with docker if nothing changed.
+		sum := sha256.Sum256([]byte(name))
+		id := "sha256:" + hex.EncodeToString(sum[:])
+		m.images[id] = types.ImageSummary{
+			ID:       id,
+			RepoTags: []string{buildImageName(project.Name, name) + ":latest"},
+		}
+	}
+	return nil
+}
+
+func (m *mockAPIClient) ComposeUp(ctx context.Context, project ComposeProject, services []string) error {
+	composeConfig, err := m.readComposeFile(project)
+	if err != nil {
+		return err
+	}
+	for _, name := range services {
+		cs, ok := composeConfig.Services[name]
+		if !ok {
+			return fmt.Errorf("no such service: %s", name)
+		}
+		if c, ok := m.findContainerByName(cs.ContainerName); ok {
+			c.State = ContainerStateRunning
+			m.containers[c.ID] = c
+			continue
+		}
+		image := cs.Image
+		if image == "" {
+			image = buildImageName(project.Name, name)
+		}
+		m.nextContainerID++
+		sum := sha256.Sum256([]byte(strconv.Itoa(m.nextContainerID)))
+		id := hex.EncodeToString(sum[:])
+		m.containers[id] = types.Container{
+			ID:      id,
+			Names:   []string{cs.ContainerName},
+			Image:   image,
+			Created: time.Now().Unix(),
+			Labels: map[string]string{
+				ProjectLabel: project.Name,
+			},
+			State:  ContainerStateRunning,
+			Status: "Up Less than a second",
+		}
+	}
+	return nil
+}
+
+func (m *mockAPIClient) ComposeRun(ctx context.Context, project ComposeProject, opts ComposeRunOptions) error {
+	composeConfig, err := m.readComposeFile(project)
+	if err != nil {
+		return err
+	}
+	if _, ok := composeConfig.Services[opts.Service]; !ok {
+		return fmt.Errorf("no such service: %s", opts.Service)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/TouchBistro/goutils/errors"
 	"github.com/TouchBistro/goutils/progress"
@@ -20,6 +21,26 @@ type Git interface {
 	Clone(ctx context.Context, repo, path string) error
 	Pull(ctx context.Context, path string) error
 	GetBranchHeadSha(ctx context.Context, repo, branch string) (string, error)
+	// Fetch fetches all branches and tags from the remote of the repo at path.
+	Fetch(ctx context.Context, path string) error
+	// Checkout checks out ref in the repo at path. If ref is not a branch,
+	// the repo will be in a detached HEAD state.
+	Checkout(ctx context.Context, path, ref string) error
+	// ResolveCommit returns the SHA of the commit that ref points to in the repo at path.
+	// ref can be a branch, tag, or SHA. Branches are resolved using the remote version of the
+	// branch so that Fetch can be used to get the latest commit. If ref is empty, the commit
+	// of the remote's default branch is returned.
+	ResolveCommit(ctx context.Context, path, ref string) (string, error)
+	// HeadCommit returns the commit that is currently checked out in the repo at path.
+	HeadCommit(ctx context.Context, path string) (Commit, error)
+}
+
+// Commit contains details about a commit.
+type Commit struct {
+	// SHA is the full SHA of the commit.
+	SHA string
+	// Time is when the commit was made.
+	Time time.Time
 }
 
 type realGit struct{}
@@ -59,6 +80,54 @@ func (realGit) GetBranchHeadSha(ctx context.Context, repo, branch string) (strin
 	return result[0:40], nil
 }
 
+func (realGit) Fetch(ctx context.Context, path string) error {
+	return execGit(ctx, "git.Git.Fetch", nil, "-C", path, "fetch", "--tags", "--force", "--prune", "origin")
+}
+
+func (realGit) Checkout(ctx context.Context, path, ref string) error {
+	return execGit(ctx, "git.Git.Checkout", nil, "-C", path, "checkout", "--quiet", ref)
+}
+
+func (realGit) ResolveCommit(ctx context.Context, path, ref string) (string, error) {
+	const op = errors.Op("git.Git.ResolveCommit")
+	// Try the remote branch first so that branches resolve to the latest fetched commit
+	// instead of the local branch which is not updated by fetch.
+	candidates := []string{"origin/HEAD"}
+	if ref != "" {
+		candidates = []string{"origin/" + ref, ref}
+	}
+	for _, c := range candidates {
+		var stdout bytes.Buffer
+		err := execGit(ctx, op, &stdout, "-C", path, "rev-parse", "--verify", "--quiet", c+"^{commit}")
+		if err == nil {
+			return strings.TrimSpace(stdout.String()), nil
+		}
+	}
+	return "", errors.New(errkind.Git, fmt.Sprintf("unable to resolve ref %q to a commit", ref), op)
+}
+
+func (realGit) HeadCommit(ctx context.Context, path string) (Commit, error) {
+	const op = errors.Op("git.Git.HeadCommit")
+	var stdout bytes.Buffer
+	err := execGit(ctx, op, &stdout, "-C", path, "log", "-1", "--format=%H %cI")
+	if err != nil {
+		return Commit{}, err
+	}
+	sha, date, ok := strings.Cut(strings.TrimSpace(stdout.String()), " ")
+	if !ok {
+		return Commit{}, errors.New(errkind.Git, fmt.Sprintf("unexpected output from git log %q", stdout.String()), op)
+	}
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return Commit{}, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Git,
+			Reason: fmt.Sprintf("invalid commit date %q", date),
+			Op:     op,
+		})
+	}
+	return Commit{SHA: sha, Time: t}, nil
+}
+
 func execGit(ctx context.Context, op errors.Op, stdout io.Writer, args ...string) error {
 	tracker := progress.TrackerFromContext(ctx)
 	w := progress.LogWriter(tracker, tracker.WithFields(progress.Fields{"op": op}).Debug)
@@ -0,0 +1,169 @@
+// Package jsonschema generates JSON Schemas from Go types so that editors can provide
+// autocompletion and validation for tb's yaml config files.
+//
+// Schemas are created using reflection based on the yaml struct tags of each field,
+// which means they always match how the config files are decoded. Additional details
+// can be provided using the following struct tags:
+//
+//	desc:"..."      A description of the field.
+//	enum:"a,b"      A comma separated list of allowed values.
+//	pattern:"^a+$"  A regular expression that values must match, ex: to allow values in any case.
+//	vars:"true"     The field supports variable expansion, ex: ${@REPOPATH}.
+//
+// A type can implement Schemaer to provide its own schema, which is useful for types
+// with custom yaml unmarshaling.
+package jsonschema
+
+import (
+	"path"
+	"reflect"
+	"strings"
+)
+
+// Draft is the JSON Schema version used by schemas.
+// Draft 7 is used since it has the widest support in editors.
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON Schema. Only the subset of JSON Schema used by tb is supported.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// Schemaer is implemented by types that provide their own schema.
+type Schemaer interface {
+	JSONSchema() *Schema
+}
+
+// varsDescription is appended to the description of fields that support variable expansion.
+const varsDescription = "Supports variable expansion using ${name}. Variables provided by tb are prefixed with @, " +
+	"ex: ${@ROOTPATH}, ${@STATICPATH}, ${@REPOPATH}, or ${@<service>} for the container name of a service."
+
+var schemaerType = reflect.TypeOf((*Schemaer)(nil)).Elem()
+
+// Reflect creates a schema for the type of v. Named struct types are added as
+// definitions and are referenced so each type is only described once.
+func Reflect(v interface{}, title string) *Schema {
+	r := reflector{definitions: make(map[string]*Schema)}
+	s := r.reflect(reflect.TypeOf(v))
+	if s.Ref != "" {
+		// Top level schema can't be a reference since title and description
+		// wouldn't be shown, use the definition directly instead.
+		name := strings.TrimPrefix(s.Ref, "#/definitions/")
+		s = r.definitions[name]
+		delete(r.definitions, name)
+	}
+	s.Schema = Draft
+	s.Title = title
+	if len(r.definitions) > 0 {
+		s.Definitions = r.definitions
+	}
+	return s
+}
+
+// ScalarType is the type used for string values. yaml allows any scalar to be decoded
+// into a string, ex: 8080 for an env var, so numbers and booleans are allowed as well.
+var ScalarType = []string{"string", "number", "boolean"}
+
+type reflector struct {
+	definitions map[string]*Schema
+}
+
+func (r *reflector) reflect(t reflect.Type) *Schema {
+	if t.Implements(schemaerType) {
+		return reflect.New(t).Elem().Interface().(Schemaer).JSONSchema()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return r.reflect(t.Elem())
+	case reflect.String:
+		return &Schema{Type: ScalarType}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: r.reflect(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.reflect(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.reflectStruct(t)
+		}
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		if _, ok := r.definitions[name]; !ok {
+			// Add a placeholder first in case the type references itself.
+			r.definitions[name] = nil
+			r.definitions[name] = r.reflectStruct(t)
+		}
+		return &Schema{Ref: "#/definitions/" + name}
+	default:
+		// Any value is allowed.
+		return &Schema{}
+	}
+}
+
+func (r *reflector) reflectStruct(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: false,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			// Default used by yaml.
+			name = strings.ToLower(f.Name)
+		}
+
+		fs := r.reflect(f.Type)
+		desc := f.Tag.Get("desc")
+		if f.Tag.Get("vars") == "true" {
+			desc = strings.TrimSpace(desc + " " + varsDescription)
+		}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			target := fs
+			if fs.Type == "array" {
+				target = fs.Items
+			}
+			target.Enum = strings.Split(enum, ",")
+		}
+		if pattern := f.Tag.Get("pattern"); pattern != "" {
+			target := fs
+			if fs.Type == "array" {
+				target = fs.Items
+			}
+			target.Pattern = pattern
+		}
+		if desc != "" {
+			if fs.Ref != "" {
+				// Keywords next to $ref are ignored in draft 7 so it needs to be wrapped.
+				fs = &Schema{AllOf: []*Schema{fs}}
+			}
+			fs.Description = desc
+		}
+		s.Properties[name] = fs
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package jsonschema_test
+
+import (
+	"testing"
+
+	"github.com/TouchBistro/tb/internal/jsonschema"
+	"github.com/matryer/is"
+)
+
+type testConfig struct {
+	Name     string            `yaml:"name" desc:"The name."`
+	Mode     string            `yaml:"mode,omitempty" enum:"a,b"`
+	Tags     []string          `yaml:"tags" enum:"x,y"`
+	Path     string            `yaml:"path" vars:"true"`
+	Kind     string            `yaml:"kind" pattern:"^[aA]$"`
+	Count    *int              `yaml:"count"`
+	Env      map[string]string `yaml:"env"`
+	Item     testItem          `yaml:"item" desc:"An item."`
+	Items    []testItem        `yaml:"items"`
+	Custom   testCustom        `yaml:"custom"`
+	Runtime  string            `yaml:"-"`
+	internal string
+}
+
+type testItem struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type testCustom struct{}
+
+func (testCustom) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{Type: "string"}
+}
+
+func TestReflect(t *testing.T) {
+	is := is.New(t)
+	s := jsonschema.Reflect(testConfig{}, "test")
+	is.Equal(s.Schema, jsonschema.Draft)
+	is.Equal(s.Title, "test")
+	is.Equal(s.Type, "object")
+	is.Equal(s.AdditionalProperties, false)
+	is.Equal(len(s.Properties), 10)
+
+	is.Equal(s.Properties["name"], &jsonschema.Schema{Description: "The name.", Type: jsonschema.ScalarType})
+	is.Equal(s.Properties["mode"].Enum, []string{"a", "b"})
+	is.Equal(s.Properties["tags"].Items.Enum, []string{"x", "y"})
+	is.True(s.Properties["path"].Description != "")
+	is.Equal(s.Properties["kind"].Pattern, "^[aA]$")
+	is.Equal(s.Properties["count"], &jsonschema.Schema{Type: "integer"})
+	is.Equal(s.Properties["env"], &jsonschema.Schema{Type: "object", AdditionalProperties: &jsonschema.Schema{Type: jsonschema.ScalarType}})
+	is.Equal(s.Properties["custom"], &jsonschema.Schema{Type: "string"})
+
+	// Named structs are definitions, descriptions need to wrap the reference.
+	ref := &jsonschema.Schema{Ref: "#/definitions/jsonschema_test.testItem"}
+	is.Equal(s.Properties["item"], &jsonschema.Schema{Description: "An item.", AllOf: []*jsonschema.Schema{ref}})
+	is.Equal(s.Properties["items"], &jsonschema.Schema{Type: "array", Items: ref})
+	is.Equal(s.Definitions, map[string]*jsonschema.Schema{
+		"jsonschema_test.testItem": {
+			Type:                 "object",
+			Properties:           map[string]*jsonschema.Schema{"enabled": {Type: "boolean"}},
+			AdditionalProperties: false,
+		},
+	})
+}
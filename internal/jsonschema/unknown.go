@@ -0,0 +1,268 @@
+package jsonschema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownKeyError is a key in a yaml document that is not allowed by a schema.
+type UnknownKeyError struct {
+	// Key is the unknown key.
+	Key string
+	// Path is the path of the mapping containing the key, with keys separated by dots.
+	// It is empty if the key is at the top level.
+	Path string
+	// Line and Column are the position of the key in the document.
+	Line   int
+	Column int
+	// Suggestion is the allowed key that is the closest to Key.
+	// It is empty if no allowed key is close enough to be a likely typo.
+	Suggestion string
+}
+
+func (e *UnknownKeyError) Error() string {
+	if e.Line == 0 {
+		return e.Message()
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message())
+}
+
+// Message returns a description of the error without the position of the key.
+func (e *UnknownKeyError) Message() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "unknown key %q", e.Key)
+	if e.Path != "" {
+		fmt.Fprintf(&sb, " in %s", e.Path)
+	}
+	if e.Suggestion != "" {
+		fmt.Fprintf(&sb, ", did you mean %q?", e.Suggestion)
+	}
+	return sb.String()
+}
+
+// FindUnknownKeys returns an error for each key in the yaml document node that is not allowed by s.
+// node can either be a document node or the top level node of the document.
+// Keys are returned in the order they appear in the document.
+func FindUnknownKeys(s *Schema, node *yaml.Node) []*UnknownKeyError {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	f := unknownKeyFinder{root: s}
+	f.walk(s, node, "")
+	return f.errs
+}
+
+type unknownKeyFinder struct {
+	root *Schema
+	errs []*UnknownKeyError
+}
+
+func (f *unknownKeyFinder) walk(s *Schema, node *yaml.Node, path string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	s = f.resolve(s, node)
+	if s == nil {
+		return
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				// Merge keys (<<) pull in keys from another mapping which are checked where they are defined.
+				continue
+			}
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+			if ps, ok := s.Properties[key.Value]; ok {
+				f.walk(ps, value, keyPath)
+				continue
+			}
+			if ps, ok := s.AdditionalProperties.(*Schema); ok {
+				f.walk(ps, value, keyPath)
+				continue
+			}
+			if s.AdditionalProperties != false {
+				continue
+			}
+			f.errs = append(f.errs, &UnknownKeyError{
+				Key:        key.Value,
+				Path:       path,
+				Line:       key.Line,
+				Column:     key.Column,
+				Suggestion: closestKey(key.Value, s.Properties),
+			})
+		}
+	case yaml.SequenceNode:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range node.Content {
+			f.walk(s.Items, item, fmt.Sprintf("%s.%d", path, i))
+		}
+	}
+}
+
+// Lookup returns the schema of the value at path in a document described by s. Each element of path
+// is either a property of an object or identifies an element of an array. Elements of arrays are
+// identified by their index, or for arrays of objects, by any other value such as a name.
+// The returned schema contains the definitions of s so it can be used with FindUnknownKeys.
+//
+// If path contains a key that is not allowed by s, an *UnknownKeyError is returned.
+func Lookup(s *Schema, path []string) (*Schema, error) {
+	f := unknownKeyFinder{root: s}
+	cur := f.deref(s)
+	for i, elem := range path {
+		// Find the alternative that allows elem, if there are multiple.
+		// Properties take precedence over arrays and maps.
+		alts := []*Schema{cur}
+		if cur != nil && len(cur.OneOf) > 0 {
+			alts = cur.OneOf
+		}
+		var next *Schema
+		var allowed map[string]*Schema
+		for _, alt := range alts {
+			if alt = f.deref(alt); alt != nil {
+				if ps, ok := alt.Properties[elem]; ok {
+					next = ps
+					break
+				}
+			}
+		}
+		for _, alt := range alts {
+			if next != nil {
+				break
+			}
+			alt = f.deref(alt)
+			if alt == nil {
+				continue
+			}
+			if ps, ok := alt.AdditionalProperties.(*Schema); ok {
+				next = ps
+			} else if alt.Items != nil {
+				if _, err := strconv.Atoi(elem); err == nil {
+					next = alt.Items
+				} else if items := f.deref(alt.Items); items != nil && items.Type == "object" {
+					next = alt.Items
+				}
+			} else if alt.Type != "array" && alt.AdditionalProperties != false {
+				// Any value is allowed.
+				next = &Schema{}
+			}
+			if alt.Properties != nil {
+				allowed = alt.Properties
+			}
+		}
+		if next == nil {
+			return nil, &UnknownKeyError{
+				Key:        elem,
+				Path:       strings.Join(path[:i], "."),
+				Suggestion: closestKey(elem, allowed),
+			}
+		}
+		cur = f.deref(next)
+	}
+	if cur == nil {
+		// The schema could not be resolved so any value is allowed.
+		cur = &Schema{}
+	}
+	result := *cur
+	result.Definitions = s.Definitions
+	return &result, nil
+}
+
+// deref follows references and wrappers of s and returns the schema they point to.
+// Alternatives in oneOf are not resolved.
+func (f *unknownKeyFinder) deref(s *Schema) *Schema {
+	for s != nil {
+		switch {
+		case s.Ref != "":
+			s = f.root.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+		case len(s.AllOf) > 0:
+			s = s.AllOf[0]
+		default:
+			return s
+		}
+	}
+	return nil
+}
+
+// resolve returns the schema that applies to node. References are followed and if s has
+// multiple alternatives, the one with a type matching node is used. If no schema can be
+// determined, nil is returned.
+func (f *unknownKeyFinder) resolve(s *Schema, node *yaml.Node) *Schema {
+	s = f.deref(s)
+	if s == nil || len(s.OneOf) == 0 {
+		return s
+	}
+	want := "object"
+	if node.Kind == yaml.SequenceNode {
+		want = "array"
+	}
+	for _, alt := range s.OneOf {
+		if alt = f.deref(alt); alt != nil && alt.Type == want {
+			return alt
+		}
+	}
+	return nil
+}
+
+// closestKey returns the key in properties that is closest to key. If none of the keys
+// are close enough to be a likely typo, an empty string is returned.
+func closestKey(key string, properties map[string]*Schema) string {
+	// Allow roughly one edit for every three characters, ex: a missing or swapped letter.
+	best := ""
+	bestDist := len(key)/3 + 2
+	for name := range properties {
+		// Differences in case are the most common typo, ex: envvars instead of envVars.
+		if strings.EqualFold(name, key) {
+			return name
+		}
+		d := editDistance(key, name)
+		if d < bestDist || (d == bestDist && best != "" && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// minInt returns the smallest of vals. It is named so that it does not shadow the min builtin of newer Go versions.
+func minInt(vals ...int) int {
+	m := vals[0]
+	for _, v := range vals[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,154 @@
+package jsonschema_test
+
+import (
+	"testing"
+
+	"github.com/TouchBistro/tb/internal/jsonschema"
+	"github.com/matryer/is"
+	"gopkg.in/yaml.v3"
+)
+
+type testOverride struct {
+	Ports testList `yaml:"ports"`
+}
+
+type testList struct{}
+
+func (testList) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{OneOf: []*jsonschema.Schema{
+		{Type: "array", Items: &jsonschema.Schema{Type: "string"}},
+		{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				"add":    {Type: "array"},
+				"remove": {Type: "array"},
+			},
+			AdditionalProperties: false,
+		},
+	}}
+}
+
+type testRoot struct {
+	Config    testConfig              `yaml:"config"`
+	Overrides map[string]testOverride `yaml:"overrides"`
+}
+
+func TestFindUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []*jsonschema.UnknownKeyError
+	}{
+		{
+			name: "no unknown keys",
+			data: `config:
+  name: foo
+  env:
+    ANY_KEY: bar
+  items:
+    - enabled: true
+overrides:
+  foo:
+    ports: ["8080:8080"]
+`,
+		},
+		{
+			name: "case typo",
+			data: "config:\n  Name: foo\n",
+			want: []*jsonschema.UnknownKeyError{
+				{Key: "Name", Path: "config", Line: 2, Column: 3, Suggestion: "name"},
+			},
+		},
+		{
+			name: "typos at different levels",
+			data: `confg:
+  name: foo
+config:
+  items:
+    - enabld: true
+overrides:
+  foo:
+    ports:
+      ad: ["8080:8080"]
+    unrelated: true
+`,
+			want: []*jsonschema.UnknownKeyError{
+				{Key: "confg", Line: 1, Column: 1, Suggestion: "config"},
+				{Key: "enabld", Path: "config.items.0", Line: 5, Column: 7, Suggestion: "enabled"},
+				{Key: "ad", Path: "overrides.foo.ports", Line: 9, Column: 7, Suggestion: "add"},
+				{Key: "unrelated", Path: "overrides.foo", Line: 10, Column: 5},
+			},
+		},
+		{
+			name: "merge keys and aliases",
+			data: `config:
+  item: &item
+    enabled: true
+    extra: true
+  items:
+    - *item
+    - <<: *item
+`,
+			want: []*jsonschema.UnknownKeyError{
+				{Key: "extra", Path: "config.item", Line: 4, Column: 5, Suggestion: ""},
+				{Key: "extra", Path: "config.items.0", Line: 4, Column: 5, Suggestion: ""},
+			},
+		},
+	}
+	s := jsonschema.Reflect(testRoot{}, "test")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			var node yaml.Node
+			is.NoErr(yaml.Unmarshal([]byte(tt.data), &node))
+			is.Equal(jsonschema.FindUnknownKeys(s, &node), tt.want)
+		})
+	}
+}
+
+func TestUnknownKeyError(t *testing.T) {
+	is := is.New(t)
+	err := &jsonschema.UnknownKeyError{Key: "envvars", Path: "services.postgres", Line: 3, Column: 5, Suggestion: "envVars"}
+	is.Equal(err.Error(), `line 3: unknown key "envvars" in services.postgres, did you mean "envVars"?`)
+	err = &jsonschema.UnknownKeyError{Key: "foo", Line: 1, Column: 1}
+	is.Equal(err.Error(), `line 1: unknown key "foo"`)
+}
+
+func TestLookup(t *testing.T) {
+	s := jsonschema.Reflect(testRoot{}, "test")
+	tests := []struct {
+		name     string
+		path     []string
+		wantType interface{}
+		wantErr  *jsonschema.UnknownKeyError
+	}{
+		{"root", nil, "object", nil},
+		{"property", []string{"config", "count"}, "integer", nil},
+		{"map entry", []string{"overrides", "foo"}, "object", nil},
+		{"array item", []string{"config", "items", "0", "enabled"}, "boolean", nil},
+		{"oneOf", []string{"overrides", "foo", "ports", "add"}, "array", nil},
+		{
+			name:    "unknown key",
+			path:    []string{"config", "item", "enabld"},
+			wantErr: &jsonschema.UnknownKeyError{Key: "enabld", Path: "config.item", Suggestion: "enabled"},
+		},
+		{
+			name:    "unknown oneOf key",
+			path:    []string{"overrides", "foo", "ports", "ad"},
+			wantErr: &jsonschema.UnknownKeyError{Key: "ad", Path: "overrides.foo.ports", Suggestion: "add"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			got, err := jsonschema.Lookup(s, tt.path)
+			if tt.wantErr != nil {
+				is.Equal(err, tt.wantErr)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got.Type, tt.wantType)
+			is.Equal(got.Definitions, s.Definitions)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Package watch provides functionality for watching a directory for changes.
+//
+// The filesystem is polled instead of relying on OS specific notification mechanisms
+// so that it works the same everywhere and doesn't run into limits on the number of
+// watched files, which is easy to hit with directories like node_modules.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Default values for Options.
+const (
+	DefaultInterval = 500 * time.Millisecond
+	DefaultDebounce = time.Second
+)
+
+// alwaysIgnore is a list of patterns that are always ignored.
+var alwaysIgnore = []string{".git"}
+
+// Options customizes the behaviour of Watch.
+type Options struct {
+	// Ignore is a list of glob patterns for files and directories to ignore.
+	// Patterns use the syntax of filepath.Match and are matched against both the path
+	// relative to the watched directory and the base name of each file. This means a pattern
+	// like node_modules ignores any directory named node_modules, while a pattern like
+	// build/*.js only ignores JavaScript files in the top level build directory.
+	// .git directories are always ignored.
+	Ignore []string
+	// Interval is how often the directory is checked for changes.
+	// Defaults to DefaultInterval if omitted.
+	Interval time.Duration
+	// Debounce is how long to wait for changes to stop before reporting them.
+	// This prevents reacting to each file individually when many files are changed at once,
+	// for example when switching git branches. Defaults to DefaultDebounce if omitted.
+	Debounce time.Duration
+}
+
+// Watch watches dir for changes and calls fn with the paths of the changed files
+// relative to dir. A file is considered changed if it was created, removed, or modified.
+//
+// Watch blocks until ctx is cancelled, in which case it returns nil, or until fn returns an error,
+// in which case the error is returned.
+func Watch(ctx context.Context, dir string, opts Options, fn func(ctx context.Context, changed []string) error) error {
+	if opts.Interval == 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Debounce == 0 {
+		opts.Debounce = DefaultDebounce
+	}
+	ignore := append(append([]string{}, alwaysIgnore...), opts.Ignore...)
+	for _, p := range ignore {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", p, err)
+		}
+	}
+
+	prev, err := snapshot(dir, ignore)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	pending := make(map[string]bool)
+	var lastChange time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		cur, err := snapshot(dir, ignore)
+		if err != nil {
+			return err
+		}
+		for _, p := range diff(prev, cur) {
+			pending[p] = true
+			lastChange = time.Now()
+		}
+		prev = cur
+		if len(pending) == 0 || time.Since(lastChange) < opts.Debounce {
+			continue
+		}
+
+		changed := make([]string, 0, len(pending))
+		for p := range pending {
+			changed = append(changed, p)
+		}
+		sort.Strings(changed)
+		pending = make(map[string]bool)
+		if err := fn(ctx, changed); err != nil {
+			return err
+		}
+	}
+}
+
+// fileState is the state of a file used to determine if it changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	mode    fs.FileMode
+}
+
+// snapshot returns the state of all files in dir that are not ignored keyed by relative path.
+func snapshot(dir string, ignore []string) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files can be removed while walking, this isn't an error, it will be seen as a change.
+			if errors.Is(err, fs.ErrNotExist) && path != dir {
+				return nil
+			}
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if ignored(rel, ignore) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		files[rel] = fileState{modTime: info.ModTime(), size: info.Size(), mode: info.Mode()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// ignored reports whether the path matches any of the ignore patterns.
+// Patterns are assumed to be valid.
+func ignored(rel string, ignore []string) bool {
+	base := filepath.Base(rel)
+	for _, p := range ignore {
+		if ok, _ := filepath.Match(p, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// diff returns the paths of all files that were added, removed, or modified between prev and cur.
+func diff(prev, cur map[string]fileState) []string {
+	var changed []string
+	for p, cs := range cur {
+		if ps, ok := prev[p]; !ok || ps != cs {
+			changed = append(changed, p)
+		}
+	}
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,66 @@
+package watch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TouchBistro/tb/internal/watch"
+	"github.com/matryer/is"
+)
+
+func TestWatch(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	writeFile("index.js", "console.log('hello')")
+	writeFile("node_modules/lib/index.js", "module.exports = {}")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changes := make(chan []string)
+	done := make(chan error)
+	go func() {
+		done <- watch.Watch(ctx, dir, watch.Options{
+			Ignore:   []string{"node_modules", "*.log"},
+			Interval: 10 * time.Millisecond,
+			Debounce: 50 * time.Millisecond,
+		}, func(ctx context.Context, changed []string) error {
+			changes <- changed
+			return nil
+		})
+	}()
+
+	// Give the watcher time to take the initial snapshot.
+	time.Sleep(50 * time.Millisecond)
+	writeFile("index.js", "console.log('hello world')")
+	writeFile("src/app.js", "export default {}")
+	writeFile("node_modules/lib/other.js", "module.exports = {}")
+	writeFile("debug.log", "ignored")
+
+	select {
+	case changed := <-changes:
+		is.Equal(changed, []string{"index.js", filepath.Join("src", "app.js")})
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for changes")
+	}
+	cancel()
+	is.NoErr(<-done)
+}
+
+func TestWatchInvalidIgnore(t *testing.T) {
+	is := is.New(t)
+	err := watch.Watch(context.Background(), t.TempDir(), watch.Options{Ignore: []string{"[a-"}}, nil)
+	is.True(err != nil)
+}
@@ -0,0 +1,254 @@
+package registry
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TouchBistro/goutils/errors"
+	"github.com/TouchBistro/tb/internal/jsonschema"
+	"github.com/TouchBistro/tb/resource"
+	"gopkg.in/yaml.v3"
+)
+
+// Rules used for findings that were not caused by a specific check.
+const (
+	// RuleInvalidResource means a resource is invalid on its own, ex: a required field is missing.
+	RuleInvalidResource = "invalid-resource"
+	// RuleInvalidConfig means a config file could not be read, ex: it is not valid YAML.
+	RuleInvalidConfig = "invalid-config"
+	// RuleUnknownKey means a config file contains a key that tb does not use, ex: a typo of a field name.
+	// It is only reported in strict mode.
+	RuleUnknownKey = "unknown-key"
+)
+
+var ruleDescriptions = map[string]string{
+	RuleInvalidConfig:          "Config file could not be read",
+	RuleDuplicateName:          "Resource is defined in multiple files",
+	RuleInvalidResource:        "Resource is invalid",
+	RuleMissingDockerfilePath:  "Service build.dockerfilePath does not exist",
+	RuleMissingEnvFile:         "Service envFile does not exist",
+	RuleNamedVolumeCollision:   "Named volume is used by multiple services",
+	RulePlaylistExtendsCycle:   "Playlist extends itself",
+	RulePortConflict:           "Host port is published by multiple services",
+	RuleUnknownDependency:      "Service dependency does not exist",
+	RuleUnknownKey:             "Config file contains an unknown key",
+	RuleUnknownPlaylistExtends: "Extended playlist does not exist",
+	RuleUnknownPlaylistService: "Playlist service does not exist",
+	RuleUnknownVariable:        "Service uses an unknown variable",
+	RuleUnknownTemplate:        "Extended template does not exist",
+	RuleTemplateExtendsCycle:   "Template extends itself",
+}
+
+// RuleDescription returns a short human readable description of rule.
+// If rule is not known, an empty string is returned.
+func RuleDescription(rule string) string {
+	return ruleDescriptions[rule]
+}
+
+// Severity is how severe a Finding is.
+type Severity string
+
+// SeverityError means the finding makes the registry invalid.
+// All findings reported by Validate currently have this severity.
+const SeverityError Severity = "error"
+
+// Finding is a single problem found by Validate. It contains the location of
+// the problem so that it can be reported by tools like editors or code scanners.
+type Finding struct {
+	// File is the path of the config file relative to the registry root, using forward slashes.
+	File string
+	// Line and Column are the position of the problem in File. They start at 1.
+	// They are 0 if the position could not be determined.
+	Line   int
+	Column int
+	// Resource is the full name of the resource with the problem.
+	// It is empty if the problem is not with a specific resource.
+	Resource string
+	// Rule identifies the kind of problem, it is one of the Rule constants.
+	Rule     string
+	Severity Severity
+	Message  string
+}
+
+// yamlLineRegex matches the line number in errors returned by the yaml package.
+var yamlLineRegex = regexp.MustCompile(`line (\d+)`)
+
+// findings converts an error returned while validating a type of config into findings.
+// If an error does not contain the file it occurred in, filename is used. Files are parsed
+// again to find the position of each problem, nodes is used to cache the parsed files.
+func findings(r Registry, filename string, err error, nodes map[string]*yaml.Node) []Finding {
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	var errs errors.List
+	if !errors.As(err, &errs) {
+		errs = errors.List{err}
+	}
+
+	var findings []Finding
+	for _, err := range errs {
+		file := filename
+		var fe *fileError
+		if errors.As(err, &fe) {
+			file = fe.path
+		}
+		var uk *jsonschema.UnknownKeyError
+		if errors.As(err, &uk) {
+			findings = append(findings, Finding{
+				File:     file,
+				Line:     uk.Line,
+				Column:   uk.Column,
+				Rule:     RuleUnknownKey,
+				Severity: SeverityError,
+				Message:  uk.Message(),
+			})
+			continue
+		}
+		var ve *resource.ValidationError
+		if errors.As(err, &ve) {
+			f := Finding{
+				File:     file,
+				Resource: ve.Resource.FullName(),
+				Rule:     ve.Rule,
+				Severity: SeverityError,
+			}
+			if f.Rule == "" {
+				f.Rule = RuleInvalidResource
+			}
+			root, ok := nodes[file]
+			if !ok {
+				root = readYAMLNode(filepath.Join(r.Path, filepath.FromSlash(file)))
+				nodes[file] = root
+			}
+			if _, name, err := resource.ParseName(f.Resource); err == nil {
+				if n := findResourceNode(root, ve.Resource.Type(), name, ve.Field); n != nil {
+					f.Line, f.Column = n.Line, n.Column
+				}
+			}
+			for _, msg := range ve.Messages {
+				f.Message = msg
+				findings = append(findings, f)
+			}
+			continue
+		}
+
+		// Decoding errors contain a message for each problem with the line number in it.
+		msgs := []string{err.Error()}
+		var te *yaml.TypeError
+		if errors.As(err, &te) {
+			msgs = te.Errors
+		}
+		for _, msg := range msgs {
+			f := Finding{File: file, Rule: RuleInvalidConfig, Severity: SeverityError, Message: msg}
+			if m := yamlLineRegex.FindStringSubmatch(msg); m != nil {
+				f.Line, _ = strconv.Atoi(m[1])
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// sortFindings sorts findings by their position.
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// readYAMLNode reads the file at path into a yaml node. It returns the top level
+// node of the document, or nil if the file could not be read.
+func readYAMLNode(path string) *yaml.Node {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// findResourceNode finds the node for the resource with the given type and name in root, which is
+// the contents of a config file. If field is not empty, the node for the field within the resource
+// is returned if it exists, otherwise the node for the resource's key is returned.
+// If the resource cannot be found, nil is returned.
+func findResourceNode(root *yaml.Node, t resource.Type, name, field string) *yaml.Node {
+	var parents [][]string
+	switch t {
+	case resource.TypeService:
+		parents = [][]string{{"services"}}
+	case resource.TypePlaylist:
+		parents = [][]string{{}}
+	case resource.TypeApp:
+		parents = [][]string{{"iosApps"}, {"desktopApps"}}
+	}
+	for _, path := range parents {
+		key, value := lookupYAMLPath(root, path)
+		if value == nil {
+			continue
+		}
+		key, value = lookupYAMLPath(value, []string{name})
+		if value == nil {
+			continue
+		}
+		if field == "" {
+			return key
+		}
+		if _, n := lookupYAMLPath(value, strings.Split(field, ".")); n != nil {
+			return n
+		}
+		return key
+	}
+	return nil
+}
+
+// lookupYAMLPath follows path from n, where each element is either a mapping key or a sequence index.
+// It returns the key node, if the last element was a mapping key, and the value node.
+// If path does not exist in n, the returned value is nil.
+func lookupYAMLPath(n *yaml.Node, path []string) (key, value *yaml.Node) {
+	value = n
+	for _, elem := range path {
+		if value == nil {
+			return nil, nil
+		}
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		switch value.Kind {
+		case yaml.MappingNode:
+			var found bool
+			for i := 0; i+1 < len(value.Content); i += 2 {
+				if value.Content[i].Value == elem {
+					key, value, found = value.Content[i], value.Content[i+1], true
+					break
+				}
+			}
+			if !found {
+				return nil, nil
+			}
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(value.Content) {
+				return nil, nil
+			}
+			key, value = nil, value.Content[i]
+		default:
+			return nil, nil
+		}
+	}
+	return key, value
+}
@@ -9,9 +9,12 @@ package registry
 
 import (
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/TouchBistro/goutils/errors"
@@ -19,6 +22,7 @@ import (
 	"github.com/TouchBistro/goutils/text"
 	"github.com/TouchBistro/tb/errkind"
 	"github.com/TouchBistro/tb/integrations/docker"
+	"github.com/TouchBistro/tb/internal/jsonschema"
 	"github.com/TouchBistro/tb/internal/util"
 	"github.com/TouchBistro/tb/resource"
 	"github.com/TouchBistro/tb/resource/app"
@@ -41,10 +45,17 @@ const (
 type Registry struct {
 	// Name is the name of the registry.
 	// Must be of the form <org>/<repo>.
-	Name string `yaml:"name"`
+	Name string `yaml:"name" desc:"Name of the registry, of the form <org>/<repo>."`
 	// LocalPath specifies the location of the registry
 	// on the local filesystem.
-	LocalPath string `yaml:"localPath,omitempty"`
+	LocalPath string `yaml:"localPath,omitempty" desc:"Path to a local copy of the registry to use instead of the one managed by tb."`
+	// Ref is the git ref to use for the registry. It can be a branch, tag, or commit SHA.
+	// If omitted, the default branch of the registry is used. It has no effect if
+	// LocalPath is set.
+	Ref string `yaml:"ref,omitempty" desc:"Git branch, tag, or commit SHA of the registry to use, defaults to the default branch."`
+	// Priority is used to resolve short names when multiple registries have a resource with the same name.
+	// The resource from the registry with the highest priority is used. The default priority is 0.
+	Priority int `yaml:"priority,omitempty" desc:"Priority used to resolve short names of resources that exist in multiple registries, the highest priority wins. Defaults to 0."`
 
 	// Path is the path to the local clone of the registry.
 	// Path is not part of the config but is determined dynamically
@@ -68,6 +79,12 @@ type ReadAllOptions struct {
 	ReposPath string
 	// Overrides are any overrides that should be applied to services.
 	Overrides map[string]service.ServiceOverride
+	// Variables are additional variables that can be used for variable expansion in services.
+	// They take priority over variables with the same name defined by registries.
+	// Names cannot start with @ since those are reserved for variables provided by tb.
+	Variables map[string]string
+	// Strict enables strict decoding, unknown keys in registry files will be considered errors.
+	Strict bool
 	// Logger can be provided to log debug details while reading registries.
 	// If it is nil, logging is off.
 	Logger progress.Logger
@@ -113,16 +130,26 @@ func ReadAll(registries []Registry, opts ReadAllOptions) (ReadAllResult, error)
 	if opts.Logger == nil {
 		opts.Logger = progress.NoopTracker{}
 	}
+	for name := range opts.Variables {
+		if strings.HasPrefix(name, "@") {
+			msg := fmt.Sprintf("invalid variable %s: names starting with @ are reserved for variables provided by tb", name)
+			return result, errors.New(errkind.Invalid, msg, op)
+		}
+	}
 
 	for _, r := range registries {
 		if opts.ReadServices {
+			result.Services.SetPriority(r.Name, r.Priority)
+			result.Playlists.SetPriority(r.Name, r.Priority)
 			opts.Logger.Debugf("Reading services from registry %s", r.Name)
 			globalConf, err := readServices(op, r, readServicesOptions{
-				collection: result.Services,
-				homeDir:    opts.HomeDir,
-				rootPath:   opts.RootPath,
-				reposPath:  opts.ReposPath,
-				overrides:  opts.Overrides,
+				collection:  result.Services,
+				homeDir:     opts.HomeDir,
+				rootPath:    opts.RootPath,
+				reposPath:   opts.ReposPath,
+				overrides:   opts.Overrides,
+				variables:   opts.Variables,
+				knownFields: opts.Strict,
 			})
 			if errors.Is(err, fs.ErrNotExist) {
 				// No file, do nothing
@@ -135,7 +162,7 @@ func ReadAll(registries []Registry, opts ReadAllOptions) (ReadAllResult, error)
 			}
 
 			opts.Logger.Debugf("Reading playlists from registry %s", r.Name)
-			err = readPlaylists(op, r, result.Playlists)
+			err = readPlaylists(op, r, readPlaylistsOptions{collection: result.Playlists, knownFields: opts.Strict})
 			if errors.Is(err, fs.ErrNotExist) {
 				// No file, do nothing
 				opts.Logger.Debugf("registry %s has no %s", r.Name, PlaylistsFileName)
@@ -150,10 +177,13 @@ func ReadAll(registries []Registry, opts ReadAllOptions) (ReadAllResult, error)
 			result.LoginStrategies = append(result.LoginStrategies, globalConf.loginStrategies...)
 		}
 		if opts.ReadApps {
+			result.IOSApps.SetPriority(r.Name, r.Priority)
+			result.DesktopApps.SetPriority(r.Name, r.Priority)
 			opts.Logger.Debugf("Reading apps from registry %s", r.Name)
 			err := readApps(op, r, readAppsOptions{
 				iosCollection:     result.IOSApps,
 				desktopCollection: result.DesktopApps,
+				knownFields:       opts.Strict,
 			})
 			if errors.Is(err, fs.ErrNotExist) {
 				// No file, do nothing
@@ -166,11 +196,59 @@ func ReadAll(registries []Registry, opts ReadAllOptions) (ReadAllResult, error)
 			}
 		}
 	}
+
+	// Replaces can only be applied once all registries have been read since they refer to other registries.
+	registryNames := make(map[string]bool, len(registries))
+	for _, r := range registries {
+		registryNames[r.Name] = true
+	}
+	var errs errors.List
+	if opts.ReadServices {
+		errs = append(errs, applyReplaces(op, result.Services, registryNames, opts.Logger, func(s service.Service) string { return s.Replaces })...)
+	}
+	if opts.ReadApps {
+		appReplaces := func(a app.App) string { return a.Replaces }
+		errs = append(errs, applyReplaces(op, result.IOSApps, registryNames, opts.Logger, appReplaces)...)
+		errs = append(errs, applyReplaces(op, result.DesktopApps, registryNames, opts.Logger, appReplaces)...)
+	}
+	if len(errs) > 0 {
+		return result, errs
+	}
 	result.BaseImages = util.UniqueStrings(result.BaseImages)
 	result.LoginStrategies = util.UniqueStrings(result.LoginStrategies)
 	return result, nil
 }
 
+// applyReplaces marks each resource in c that is replaced by another resource as replaced.
+// replaces returns the full name of the resource that a resource replaces. registryNames contains
+// the names of the registries that were read. Replacing a resource from a registry that was not read
+// does nothing, since the replaced resource can't be used anyway.
+func applyReplaces[R resource.Resource](op errors.Op, c *resource.Collection[R], registryNames map[string]bool, logger progress.Logger, replaces func(R) string) errors.List {
+	var errs errors.List
+	for it := c.Iter(); it.Next(); {
+		r := it.Value()
+		old := replaces(r)
+		if old == "" {
+			continue
+		}
+		if registryName, _, err := resource.ParseName(old); err == nil && registryName != "" && !registryNames[registryName] {
+			logger.Debugf("Skipping replace of %s by %s %s, registry %s is not used", old, r.Type(), r.FullName(), registryName)
+			continue
+		}
+		if err := c.Replace(old, r.FullName()); err != nil {
+			errs = append(errs, errors.Wrap(err, errors.Meta{
+				Reason: fmt.Sprintf("%s %s failed to replace %s", r.Type(), r.FullName(), old),
+				Op:     op,
+			}))
+		}
+	}
+	// Chains of replaces can only be checked once all of them are known.
+	if err := c.ValidateReplaces(); err != nil {
+		errs = append(errs, errors.Wrap(err, errors.Meta{Reason: "invalid replaces", Op: op}))
+	}
+	return errs
+}
+
 // ValidateOptions allows for customizing the behaviour of Validate.
 // All fields are optional.
 type ValidateOptions struct {
@@ -179,7 +257,12 @@ type ValidateOptions struct {
 	// The following additional validations are enabled:
 	//
 	// - Unknown variables will be considered errors.
+	// - Unknown keys in registry files will be considered errors.
 	Strict bool
+	// ReposPath is the path where service repos are cloned. If provided, paths within
+	// the repo of a service, like its envFile, are checked to make sure they exist if the
+	// repo has been cloned. If omitted, paths within repos are not checked.
+	ReposPath string
 	// Logger can be provided to log debug details while reading registries.
 	// If it is nil, logging is off.
 	Logger progress.Logger
@@ -203,11 +286,20 @@ type ValidateResult struct {
 	// If the serivces config was valid, ServicesErr is nil.
 	// If no apps config file was found, ServicesErr will be fs.ErrNotExist.
 	ServicesErr error
+	// Findings contains each problem found in the registry along with its location.
+	// Findings are sorted by file and position. If the registry is valid, Findings is empty.
+	Findings []Finding
 }
 
 // Validate checks to see if the registry located at path is valid. It will read and validate
-// each configuration file in the registry. path is expected to be a valid file path
-// on the local OS filesystem.
+// each configuration file in the registry, including the files in the services.d, playlists.d,
+// and apps.d directories. path is expected to be a valid file path on the local OS filesystem.
+//
+// In addition to validating each resource on its own, Validate checks that references between
+// resources are valid, for example that the dependencies of a service exist. Each failure found
+// by these checks is a *resource.ValidationError with Rule set to one of the Rule constants.
+// Each problem is also reported as a Finding in ValidateResult.Findings which includes its
+// location within the registry files.
 //
 // opts can be used to customize the behaviour of validate, see each field for more details.
 //
@@ -228,7 +320,7 @@ func Validate(path string, opts ValidateOptions) ValidateResult {
 			Op:     op,
 		})
 		// We can just return this error for every single one.
-		return ValidateResult{err, err, err}
+		return ValidateResult{AppsErr: err, PlaylistsErr: err, ServicesErr: err}
 	}
 
 	r := Registry{
@@ -241,72 +333,79 @@ func Validate(path string, opts ValidateOptions) ValidateResult {
 	}
 	opts.Logger.Debugf("Validating registry %s", r.Name)
 
-	// Validate apps.yml
+	// Validate apps.yml and apps.d
 	opts.Logger.Debug("Validating apps")
 	err = readApps(op, r, readAppsOptions{
 		iosCollection:     &resource.Collection[app.App]{},
 		desktopCollection: &resource.Collection[app.App]{},
+		knownFields:       opts.Strict,
 	})
 	if err != nil {
 		result.AppsErr = err
 	}
-	// Validate playlists.yml
+	// Validate playlists.yml and playlists.d
 	opts.Logger.Debug("Validating playlists")
-	if err := readPlaylists(op, r, &playlist.Collection{}); err != nil {
+	// Keep track of the file each resource is defined in so errors found by checks
+	// across resources can be attributed to the right file.
+	var playlists playlist.Collection
+	playlistSources := make(map[string]string)
+	err = readPlaylists(op, r, readPlaylistsOptions{
+		collection:  &playlists,
+		sources:     playlistSources,
+		knownFields: opts.Strict,
+	})
+	if err != nil {
 		result.PlaylistsErr = err
 	}
 
-	// Validate services.yml
+	// Validate services.yml and services.d
 	opts.Logger.Debug("Validating services")
 	var services resource.Collection[service.Service]
-	_, err = readServices(op, r, readServicesOptions{
-		collection: &services,
-		strict:     opts.Strict,
+	serviceSources := make(map[string]string)
+	_, servicesErr := readServices(op, r, readServicesOptions{
+		collection:  &services,
+		reposPath:   opts.ReposPath,
+		strict:      opts.Strict,
+		knownFields: opts.Strict,
+		sources:     serviceSources,
 	})
-	if err != nil {
-		result.ServicesErr = err
-	} else {
-		// Perform additional validations
-		// Keep track of ports to check for conflicting ports
-		usedPorts := make(map[string]string)
-		var errs errors.List
-		for it := services.Iter(); it.Next(); {
-			s := it.Value()
-			// Check for port conflict. Port conflicts shouldn't be allowed in the same registry
-			// since this just causes confusion and a poor user experience.
-			for _, p := range s.Ports {
-				// ports are of the form EXTERNAL:INTERNAL
-				// get external part
-				exposedPort := strings.Split(p, ":")[0]
-				conflict, ok := usedPorts[exposedPort]
-				if !ok {
-					usedPorts[exposedPort] = s.Name
-					continue
-				}
-
-				// Handle port conflict
-				msg := fmt.Sprintf("conflicting port %s with service %s", exposedPort, conflict)
-				errs = append(errs, &resource.ValidationError{
-					Resource: s,
-					Messages: []string{msg},
-				})
-			}
+	if servicesErr != nil {
+		result.ServicesErr = servicesErr
+	} else if errs := validateServices(r, &services, opts.ReposPath); len(errs) > 0 {
+		// Perform additional validations across services now that they are all read.
+		result.ServicesErr = withSources(errs, serviceSources)
+	}
+
+	// Perform additional validations on playlists now that services are available.
+	// Services can only be checked if they were read successfully, otherwise there would be false positives.
+	if result.PlaylistsErr == nil {
+		playlistServices := &services
+		if servicesErr != nil && !errors.Is(servicesErr, fs.ErrNotExist) {
+			playlistServices = nil
 		}
-		if len(errs) > 0 {
-			result.ServicesErr = errs
+		if errs := validatePlaylists(r, &playlists, playlistServices); len(errs) > 0 {
+			result.PlaylistsErr = withSources(errs, playlistSources)
 		}
 	}
+
+	nodes := make(map[string]*yaml.Node)
+	result.Findings = append(result.Findings, findings(r, AppsFileName, result.AppsErr, nodes)...)
+	result.Findings = append(result.Findings, findings(r, PlaylistsFileName, result.PlaylistsErr, nodes)...)
+	result.Findings = append(result.Findings, findings(r, ServicesFileName, result.ServicesErr, nodes)...)
+	sortFindings(result.Findings)
 	return result
 }
 
 // registryServiceConfig represents a services.yml file in a registry.
 type registryServiceConfig struct {
 	Global struct {
-		BaseImages      []string          `yaml:"baseImages"`
-		LoginStrategies []string          `yaml:"loginStrategies"`
-		Variables       map[string]string `yaml:"variables"`
-	} `yaml:"global"`
-	Services map[string]service.Service `yaml:"services"`
+		BaseImages      []string          `yaml:"baseImages" desc:"Docker images to pull before building containers."`
+		LoginStrategies []string          `yaml:"loginStrategies" desc:"Login strategies to run before running services." enum:"ecr,npm"`
+		Variables       map[string]string `yaml:"variables" desc:"Variables that can be used in services."`
+	} `yaml:"global" desc:"Config that applies to all services in the registry."`
+	// Templates are partial services that services can extend to share common config.
+	Templates map[string]service.Service `yaml:"templates" desc:"Partial services that services can extend to share common config."`
+	Services  map[string]service.Service `yaml:"services" desc:"The services that can be run."`
 }
 
 type readServicesOptions struct {
@@ -315,7 +414,14 @@ type readServicesOptions struct {
 	rootPath   string
 	reposPath  string
 	overrides  map[string]service.ServiceOverride
-	strict     bool
+	// variables take priority over the variables defined in the registry.
+	variables map[string]string
+	// strict makes unknown variables errors.
+	strict bool
+	// knownFields makes unknown keys errors.
+	knownFields bool
+	// sources, if not nil, is populated with the file each service was read from.
+	sources map[string]string
 }
 
 type serviceGlobalConfig struct {
@@ -325,33 +431,68 @@ type serviceGlobalConfig struct {
 
 // readServices reads the service config from the registry r.
 func readServices(op errors.Op, r Registry, opts readServicesOptions) (serviceGlobalConfig, error) {
-	var serviceConf registryServiceConfig
-	err := readRegistryFile(op, ServicesFileName, r, &serviceConf)
+	files, err := readRegistryFiles[registryServiceConfig](op, ServicesFileName, r, opts.knownFields)
 	if err != nil {
 		return serviceGlobalConfig{}, err
 	}
 
-	// Set special vars
-	vars := serviceConf.Global.Variables
-	// If no variables are defined in services.yml the map will be nil
-	if vars == nil {
-		vars = make(map[string]string)
+	// Combine all the files, names must be unique across all of them.
+	var globalConf serviceGlobalConfig
+	vars := make(map[string]string)
+	varFiles := make(map[string]string)
+	templates := make(map[string]service.Service)
+	templateFiles := make(map[string]string)
+	services := make(map[string]service.Service)
+	serviceFiles := make(map[string]string)
+	var errs errors.List
+	for _, f := range files {
+		errs = append(errs, f.errs...)
+		globalConf.baseImages = append(globalConf.baseImages, f.conf.Global.BaseImages...)
+		globalConf.loginStrategies = append(globalConf.loginStrategies, f.conf.Global.LoginStrategies...)
+		mergeFileMap(vars, varFiles, f.conf.Global.Variables, f.path, func(name, otherPath string) {
+			msg := fmt.Sprintf("variable %s is defined in both %s and %s", name, otherPath, f.path)
+			errs = append(errs, &fileError{path: f.path, err: errors.New(errkind.Invalid, msg, op)})
+		})
+		mergeFileMap(templates, templateFiles, f.conf.Templates, f.path, func(name, otherPath string) {
+			msg := fmt.Sprintf("template %s is defined in both %s and %s", name, otherPath, f.path)
+			errs = append(errs, &fileError{path: f.path, err: errors.New(errkind.Invalid, msg, op)})
+		})
+		mergeFileMap(services, serviceFiles, f.conf.Services, f.path, func(name, otherPath string) {
+			s := service.Service{Name: name, RegistryName: r.Name}
+			errs = append(errs, duplicateError(s, otherPath, f.path))
+		})
+	}
+
+	for name, value := range opts.variables {
+		vars[name] = value
 	}
+
+	// Set special vars
 	vars["@ROOTPATH"] = opts.rootPath
 	vars["@STATICPATH"] = filepath.Join(r.Path, staticDirName)
 
 	// Add vars for each service name
-	for name := range serviceConf.Services {
+	for name := range services {
 		fullName := resource.FullName(r.Name, name)
 		vars["@"+name] = docker.NormalizeName(fullName)
 	}
 
-	var errs errors.List
-	for n, s := range serviceConf.Services {
+	for n, s := range services {
 		s.Name = n
 		s.RegistryName = r.Name
+		path := serviceFiles[n]
+		if opts.sources != nil {
+			opts.sources[s.FullName()] = path
+		}
+		if s.Extends != "" {
+			s, err = extendService(s, templates)
+			if err != nil {
+				errs = append(errs, &fileError{path: path, err: err})
+				continue
+			}
+		}
 		if err := service.Validate(s); err != nil {
-			errs = append(errs, err)
+			errs = append(errs, &fileError{path: path, err: err})
 			continue
 		}
 
@@ -390,13 +531,17 @@ func readServices(op errors.Op, r Registry, opts readServicesOptions) (serviceGl
 		for i, value := range s.Entrypoint {
 			s.Entrypoint[i] = ve.expand(value, "entrypointValue")
 		}
+		if ok {
+			override = ve.expandOverride(override)
+		}
 
 		// Report unknown vars as an error if in strict mode
 		if len(ve.errMsgs) > 0 && opts.strict {
-			errs = append(errs, &resource.ValidationError{
+			errs = append(errs, &fileError{path: path, err: &resource.ValidationError{
 				Resource: s,
 				Messages: ve.errMsgs,
-			})
+				Rule:     RuleUnknownVariable,
+			}})
 			continue
 		}
 
@@ -417,10 +562,64 @@ func readServices(op errors.Op, r Registry, opts readServicesOptions) (serviceGl
 	if len(errs) > 0 {
 		return serviceGlobalConfig{}, errs
 	}
-	return serviceGlobalConfig{
-		baseImages:      serviceConf.Global.BaseImages,
-		loginStrategies: serviceConf.Global.LoginStrategies,
-	}, nil
+	return globalConf, nil
+}
+
+// extendService merges the template that s extends into s. Templates can extend other templates,
+// in which case they are merged starting from the last template in the chain of extends.
+func extendService(s service.Service, templates map[string]service.Service) (service.Service, error) {
+	newErr := func(rule, msg string) error {
+		return &resource.ValidationError{Resource: s, Messages: []string{msg}, Rule: rule, Field: "extends"}
+	}
+	var chain []string
+	seen := make(map[string]bool)
+	for name := s.Extends; name != ""; name = templates[name].Extends {
+		chain = append(chain, name)
+		if seen[name] {
+			return s, newErr(RuleTemplateExtendsCycle, fmt.Sprintf("template extends cycle %s", strings.Join(chain, " -> ")))
+		}
+		seen[name] = true
+		if _, ok := templates[name]; !ok {
+			return s, newErr(RuleUnknownTemplate, fmt.Sprintf("template %s does not exist", name))
+		}
+	}
+
+	t := templates[chain[len(chain)-1]]
+	for i := len(chain) - 2; i >= 0; i-- {
+		t = service.Extend(templates[chain[i]], t)
+	}
+	return service.Extend(s, t), nil
+}
+
+// expandOverride expands variables in the values of o that are also expanded for services, so that
+// overrides can use the same variables as services, ex: ${@ROOTPATH}. Ports are not expanded, same as
+// for services. o is not modified, a copy with the expanded values is returned.
+func (ve *variableExpander) expandOverride(o service.ServiceOverride) service.ServiceOverride {
+	expandAll := func(values []string, fieldName string) []string {
+		if values == nil {
+			return nil
+		}
+		expanded := make([]string, len(values))
+		for i, v := range values {
+			expanded[i] = ve.expand(v, fieldName)
+		}
+		return expanded
+	}
+	expandVolumes := func(o service.VolumeListOverride, fieldName string) service.VolumeListOverride {
+		var add []service.Volume
+		for _, v := range o.Add {
+			v.Value = ve.expand(v.Value, fieldName)
+			add = append(add, v)
+		}
+		return service.VolumeListOverride{Add: add, Remove: expandAll(o.Remove, fieldName)}
+	}
+	o.Dependencies.Add = expandAll(o.Dependencies.Add, "overrides.dependencies")
+	o.Dependencies.Remove = expandAll(o.Dependencies.Remove, "overrides.dependencies")
+	o.Entrypoint = expandAll(o.Entrypoint, "overrides.entrypoint")
+	o.EnvFile = ve.expand(o.EnvFile, "overrides.envFile")
+	o.Build.Volumes = expandVolumes(o.Build.Volumes, "overrides.build.volumes")
+	o.Remote.Volumes = expandVolumes(o.Remote.Volumes, "overrides.remote.volumes")
+	return o
 }
 
 // variableExpander is a small helper type which expands variables in a service field.
@@ -444,6 +643,12 @@ func (ve *variableExpander) mapping(name string) string {
 	if strings.HasPrefix(name, envPrefix) {
 		return fmt.Sprintf("${%s}", strings.TrimPrefix(name, envPrefix))
 	}
+	// @hostenv reads an environment variable of the host at expansion time.
+	// Ex: ${@hostenv:AWS_PROFILE:-default}
+	const hostEnvPrefix = "@hostenv:"
+	if strings.HasPrefix(name, hostEnvPrefix) {
+		return ve.hostEnv(strings.TrimPrefix(name, hostEnvPrefix))
+	}
 	if v, ok := ve.vars[name]; ok {
 		return v
 	}
@@ -452,26 +657,66 @@ func (ve *variableExpander) mapping(name string) string {
 	return ""
 }
 
+// envNameRegex matches valid environment variable names.
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// hostEnv returns the value of the environment variable in expr, which is of the form NAME or NAME:-default.
+// Like in a shell, the default is used if the variable is unset or empty. The value is used
+// literally, it is not expanded further.
+func (ve *variableExpander) hostEnv(expr string) string {
+	name, def, hasDefault := strings.Cut(expr, ":-")
+	if !envNameRegex.MatchString(name) {
+		ve.errMsgs = append(ve.errMsgs, fmt.Sprintf("%s: invalid environment variable name %q", ve.fieldName, name))
+		return ""
+	}
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	if !hasDefault {
+		ve.errMsgs = append(ve.errMsgs, fmt.Sprintf("%s: environment variable %s is not set and has no default", ve.fieldName, name))
+	}
+	return def
+}
+
+type readPlaylistsOptions struct {
+	collection  *playlist.Collection
+	knownFields bool
+	// sources, if not nil, is populated with the file each playlist was read from.
+	sources map[string]string
+}
+
 // readPlaylists reads the playlist config from the registry r.
-func readPlaylists(op errors.Op, r Registry, collection *playlist.Collection) error {
-	playlistMap := make(map[string]playlist.Playlist)
-	err := readRegistryFile(op, PlaylistsFileName, r, &playlistMap)
+func readPlaylists(op errors.Op, r Registry, opts readPlaylistsOptions) error {
+	files, err := readRegistryFiles[map[string]playlist.Playlist](op, PlaylistsFileName, r, opts.knownFields)
 	if err != nil {
 		return err
 	}
 
 	var errs errors.List
+	playlistMap := make(map[string]playlist.Playlist)
+	playlistFiles := make(map[string]string)
+	for _, f := range files {
+		errs = append(errs, f.errs...)
+		mergeFileMap(playlistMap, playlistFiles, f.conf, f.path, func(name, otherPath string) {
+			p := playlist.Playlist{Name: name, RegistryName: r.Name}
+			errs = append(errs, duplicateError(p, otherPath, f.path))
+		})
+	}
 	for n, p := range playlistMap {
 		// Set necessary fields for each playlist
 		p.Name = n
 		p.RegistryName = r.Name
+		path := playlistFiles[n]
+		if opts.sources != nil {
+			opts.sources[p.FullName()] = path
+		}
 
 		// Make sure extends is a full name
 		if p.Extends != "" {
 			registryName, playlistName, err := resource.ParseName(p.Extends)
 			if err != nil {
 				msg := fmt.Sprintf("failed to resolve full name for extends field of playlist %s", p.FullName())
-				errs = append(errs, errors.Wrap(err, errors.Meta{Reason: msg, Op: op}))
+				errs = append(errs, &fileError{path: path, err: errors.Wrap(err, errors.Meta{Reason: msg, Op: op})})
 				continue
 			}
 			if registryName == "" {
@@ -485,7 +730,7 @@ func readPlaylists(op errors.Op, r Registry, collection *playlist.Collection) er
 			registryName, serviceName, err := resource.ParseName(name)
 			if err != nil {
 				msg := fmt.Sprintf("failed to resolve full name for service %s in playlist %s", name, p.FullName())
-				errs = append(errs, errors.Wrap(err, errors.Meta{Reason: msg, Op: op}))
+				errs = append(errs, &fileError{path: path, err: errors.Wrap(err, errors.Meta{Reason: msg, Op: op})})
 				continue
 			}
 			if registryName == "" {
@@ -495,7 +740,7 @@ func readPlaylists(op errors.Op, r Registry, collection *playlist.Collection) er
 			}
 		}
 		p.Services = serviceNames
-		if err := collection.Set(p); err != nil {
+		if err := opts.collection.Set(p); err != nil {
 			errs = append(errs, err)
 			continue
 		}
@@ -508,30 +753,44 @@ func readPlaylists(op errors.Op, r Registry, collection *playlist.Collection) er
 
 // registryAppConfig represents an apps.yml file in a registry.
 type registryAppConfig struct {
-	IOSApps     map[string]app.App `yaml:"iosApps"`
-	DesktopApps map[string]app.App `yaml:"desktopApps"`
+	IOSApps     map[string]app.App `yaml:"iosApps" desc:"iOS apps that can be run in a simulator."`
+	DesktopApps map[string]app.App `yaml:"desktopApps" desc:"Desktop apps that can be run."`
 }
 
 type readAppsOptions struct {
 	iosCollection     *resource.Collection[app.App]
 	desktopCollection *resource.Collection[app.App]
+	knownFields       bool
 }
 
-// readPlaylists reads the app config from the registry r.
+// readApps reads the app config from the registry r.
 func readApps(op errors.Op, r Registry, opts readAppsOptions) error {
-	var appConf registryAppConfig
-	err := readRegistryFile(op, AppsFileName, r, &appConf)
+	files, err := readRegistryFiles[registryAppConfig](op, AppsFileName, r, opts.knownFields)
 	if err != nil {
 		return err
 	}
 
 	var errs errors.List
+	iosApps := make(map[string]app.App)
+	iosAppFiles := make(map[string]string)
+	desktopApps := make(map[string]app.App)
+	desktopAppFiles := make(map[string]string)
+	for _, f := range files {
+		errs = append(errs, f.errs...)
+		onDup := func(name, otherPath string) {
+			a := app.App{Name: name, RegistryName: r.Name}
+			errs = append(errs, duplicateError(a, otherPath, f.path))
+		}
+		mergeFileMap(iosApps, iosAppFiles, f.conf.IOSApps, f.path, onDup)
+		mergeFileMap(desktopApps, desktopAppFiles, f.conf.DesktopApps, f.path, onDup)
+	}
+
 	// Deal with iOS apps
-	for n, a := range appConf.IOSApps {
+	for n, a := range iosApps {
 		a.Name = n
 		a.RegistryName = r.Name
 		if err := app.Validate(a, app.TypeiOS); err != nil {
-			errs = append(errs, err)
+			errs = append(errs, &fileError{path: iosAppFiles[n], err: err})
 			continue
 		}
 		if err := opts.iosCollection.Set(a); err != nil {
@@ -541,7 +800,7 @@ func readApps(op errors.Op, r Registry, opts readAppsOptions) error {
 	}
 
 	// Deal with desktop apps
-	for n, a := range appConf.DesktopApps {
+	for n, a := range desktopApps {
 		a.Name = n
 		a.RegistryName = r.Name
 		if err := opts.desktopCollection.Set(a); err != nil {
@@ -555,11 +814,129 @@ func readApps(op errors.Op, r Registry, opts readAppsOptions) error {
 	return nil
 }
 
+// registryFile is a config file that was read from a registry.
+type registryFile[T any] struct {
+	// path is the path to the file relative to the registry root, using forward slashes.
+	path string
+	conf T
+	// errs are problems in the file that don't prevent it from being used,
+	// ex: unknown keys when using strict decoding.
+	errs errors.List
+}
+
+// readRegistryFiles reads the config file filename from the registry r, as well as each .yml file
+// in the directory with the same name and a .d extension, ex: services.d for services.yml.
+// This allows config to be split across multiple files. filename is read first, followed by
+// the files in the directory in lexical order, so the returned files are always in the same order.
+//
+// If knownFields is true, each unknown key in a file is added to the errs of the file as a *jsonschema.UnknownKeyError.
+//
+// If none of the files exist, fs.ErrNotExist will be returned which can be checked with errors.Is.
+func readRegistryFiles[T any](op errors.Op, filename string, r Registry, knownFields bool) ([]registryFile[T], error) {
+	dir := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".d"
+	// Glob only fails if the pattern is malformed so the error can be ignored.
+	matches, _ := filepath.Glob(filepath.Join(r.Path, dir, "*.yml"))
+	sort.Strings(matches)
+	paths := []string{filename}
+	for _, m := range matches {
+		paths = append(paths, dir+"/"+filepath.Base(m))
+	}
+
+	var schema *jsonschema.Schema
+	if knownFields {
+		schema = fileSchema(filename)
+	}
+	var files []registryFile[T]
+	var errs errors.List
+	var notExistErr error
+	for _, p := range paths {
+		var conf T
+		var node yaml.Node
+		err := readRegistryFile(op, p, r, &node)
+		if errors.Is(err, fs.ErrNotExist) {
+			notExistErr = err
+			continue
+		}
+		if err == nil && len(node.Content) > 0 {
+			if err = node.Decode(&conf); err != nil {
+				err = errors.Wrap(err, errors.Meta{
+					Kind:   errkind.IO,
+					Reason: fmt.Sprintf("failed to decode %s in registry %s", p, r.Name),
+					Op:     op,
+				})
+			}
+		}
+		if err != nil {
+			errs = append(errs, &fileError{path: p, err: err})
+			continue
+		}
+		f := registryFile[T]{path: p, conf: conf}
+		if schema != nil {
+			for _, uk := range jsonschema.FindUnknownKeys(schema, &node) {
+				f.errs = append(f.errs, &fileError{path: p, err: errors.Wrap(uk, errors.Meta{Reason: p, Op: op})})
+			}
+		}
+		files = append(files, f)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(files) == 0 {
+		return nil, notExistErr
+	}
+	return files, nil
+}
+
+// mergeFileMap adds the values in src, which were read from the file at path, to dst.
+// The file each value came from is recorded in paths. If a key already exists in dst,
+// the value is not added and onDup is called with the key and the path of the file it came from.
+// Keys are added in sorted order so that onDup is called in a deterministic order.
+func mergeFileMap[V any](dst map[string]V, paths map[string]string, src map[string]V, path string, onDup func(key, otherPath string)) {
+	keys := make([]string, 0, len(src))
+	for k := range src {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := dst[k]; ok {
+			onDup(k, paths[k])
+			continue
+		}
+		dst[k] = src[k]
+		paths[k] = path
+	}
+}
+
+// duplicateError returns the error for a resource being defined in multiple files.
+func duplicateError(r resource.Resource, path, otherPath string) error {
+	msg := fmt.Sprintf("defined in both %s and %s", path, otherPath)
+	return &fileError{path: otherPath, err: &resource.ValidationError{
+		Resource: r,
+		Messages: []string{msg},
+		Rule:     RuleDuplicateName,
+	}}
+}
+
+// fileError is an error that occurred in a specific file in a registry.
+type fileError struct {
+	// path is the path to the file relative to the registry root, using forward slashes.
+	path string
+	err  error
+}
+
+func (e *fileError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fileError) Unwrap() error {
+	return e.err
+}
+
 // readRegistryFile is a small helper to read a registry file and unmarshal it.
 // If the file does not exist, fs.ErrNotExist will be returned which can be checked
-// with errors.Is.
+// with errors.Is. An empty file is not an error, v will be left unchanged.
 func readRegistryFile(op errors.Op, filename string, r Registry, v interface{}) error {
-	fp := filepath.Join(r.Path, filename)
+	fp := filepath.Join(r.Path, filepath.FromSlash(filename))
 	f, err := os.Open(fp)
 	if err != nil {
 		return errors.Wrap(err, errors.Meta{
@@ -569,7 +946,7 @@ func readRegistryFile(op errors.Op, filename string, r Registry, v interface{})
 		})
 	}
 	defer f.Close()
-	if err := yaml.NewDecoder(f).Decode(v); err != nil {
+	if err := yaml.NewDecoder(f).Decode(v); err != nil && err != io.EOF {
 		return errors.Wrap(err, errors.Meta{
 			Kind:   errkind.IO,
 			Reason: fmt.Sprintf("failed to decode %s in registry %s", filename, r.Name),
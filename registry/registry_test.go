@@ -2,11 +2,15 @@ package registry_test
 
 import (
 	"io/fs"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/TouchBistro/goutils/errors"
 	"github.com/TouchBistro/tb/integrations/simulator"
+	"github.com/TouchBistro/tb/internal/jsonschema"
 	"github.com/TouchBistro/tb/registry"
 	"github.com/TouchBistro/tb/resource"
 	"github.com/TouchBistro/tb/resource/app"
@@ -322,3 +326,602 @@ func TestValidateErrors(t *testing.T) {
 	is.Equal(serviceErrs[1].Resource.FullName(), "local/invalid-registry-1/venue-core-service")
 	is.Equal(serviceErrs[2].Resource.FullName(), "local/invalid-registry-1/venue-example-service")
 }
+
+func TestValidateCrossResourceErrors(t *testing.T) {
+	is := is.New(t)
+	// Create a clone of the repo without the docker dir to check that paths within repos are validated.
+	reposPath := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(reposPath, "TouchBistro", "venue-core-service"), 0o755); err != nil {
+		t.Fatalf("failed to create repo dir: %v", err)
+	}
+	result := registry.Validate("testdata/invalid-registry-2", registry.ValidateOptions{
+		Strict:    true,
+		ReposPath: reposPath,
+	})
+	is.True(errors.Is(result.AppsErr, fs.ErrNotExist))
+
+	type finding struct {
+		resource string
+		rule     string
+	}
+	findings := func(err error) []finding {
+		t.Helper()
+		var errs errors.List
+		is.True(errors.As(err, &errs))
+		var fs []finding
+		for _, err := range errs {
+			var ve *resource.ValidationError
+			is.True(errors.As(err, &ve))
+			fs = append(fs, finding{ve.Resource.FullName(), ve.Rule})
+		}
+		return fs
+	}
+	is.Equal(findings(result.ServicesErr), []finding{
+		{"local/invalid-registry-2/postgres-replica", registry.RuleNamedVolumeCollision},
+		{"local/invalid-registry-2/postgres-replica", registry.RuleMissingEnvFile},
+		{"local/invalid-registry-2/venue-core-service", registry.RuleUnknownDependency},
+		{"local/invalid-registry-2/venue-core-service", registry.RuleMissingDockerfilePath},
+	})
+	is.Equal(findings(result.PlaylistsErr), []finding{
+		{"local/invalid-registry-2/cycle-a", registry.RulePlaylistExtendsCycle},
+		{"local/invalid-registry-2/cycle-b", registry.RulePlaylistExtendsCycle},
+		{"local/invalid-registry-2/missing-extends", registry.RuleUnknownPlaylistExtends},
+		// Services from other registries can't be checked.
+		{"local/invalid-registry-2/missing-services", registry.RuleUnknownPlaylistService},
+	})
+
+	// Paths within repos are not checked if the repos path isn't known.
+	result = registry.Validate("testdata/invalid-registry-2", registry.ValidateOptions{Strict: true})
+	for _, f := range findings(result.ServicesErr) {
+		is.True(f.rule != registry.RuleMissingDockerfilePath)
+	}
+}
+
+func TestValidateFindings(t *testing.T) {
+	is := is.New(t)
+	reposPath := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(reposPath, "TouchBistro", "venue-core-service"), 0o755); err != nil {
+		t.Fatalf("failed to create repo dir: %v", err)
+	}
+	result := registry.Validate("testdata/invalid-registry-2", registry.ValidateOptions{
+		Strict:    true,
+		ReposPath: reposPath,
+	})
+
+	type finding struct {
+		file   string
+		line   int
+		column int
+		rule   string
+	}
+	var findings []finding
+	for _, f := range result.Findings {
+		is.Equal(f.Severity, registry.SeverityError)
+		is.True(f.Resource != "")
+		findings = append(findings, finding{f.File, f.Line, f.Column, f.Rule})
+	}
+	is.Equal(findings, []finding{
+		{registry.PlaylistsFileName, 7, 7, registry.RuleUnknownPlaylistService},
+		{registry.PlaylistsFileName, 10, 12, registry.RuleUnknownPlaylistExtends},
+		{registry.PlaylistsFileName, 14, 12, registry.RulePlaylistExtendsCycle},
+		{registry.PlaylistsFileName, 18, 12, registry.RulePlaylistExtendsCycle},
+		{registry.ServicesFileName, 11, 14, registry.RuleMissingEnvFile},
+		{registry.ServicesFileName, 16, 11, registry.RuleNamedVolumeCollision},
+		{registry.ServicesFileName, 21, 9, registry.RuleUnknownDependency},
+		{registry.ServicesFileName, 26, 23, registry.RuleMissingDockerfilePath},
+	})
+
+	// Resources that are invalid on their own are located at their key.
+	result = registry.Validate("testdata/invalid-registry-1", registry.ValidateOptions{Strict: true})
+	is.True(len(result.Findings) > 0)
+	f := result.Findings[0]
+	is.Equal(f.File, registry.AppsFileName)
+	is.Equal(f.Resource, "local/invalid-registry-1/GemSwapper")
+	is.Equal(f.Rule, registry.RuleInvalidResource)
+	is.Equal([]int{f.Line, f.Column}, []int{2, 3})
+}
+
+func TestValidateFindingsInvalidYAML(t *testing.T) {
+	is := is.New(t)
+	registryPath := newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: "services:\n  postgres:\n    ports: 5432\n",
+	})
+	result := registry.Validate(registryPath, registry.ValidateOptions{})
+	is.True(result.ServicesErr != nil)
+	is.Equal(len(result.Findings), 1)
+	f := result.Findings[0]
+	is.Equal(f.File, registry.ServicesFileName)
+	is.Equal(f.Line, 3)
+	is.Equal(f.Rule, registry.RuleInvalidConfig)
+	is.Equal(f.Resource, "")
+}
+
+func TestReadServicesTemplates(t *testing.T) {
+	is := is.New(t)
+	registryPath := newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: `templates:
+  base:
+    envVars:
+      LOG_LEVEL: info
+  node:
+    extends: base
+    envVars:
+      NODE_ENV: development
+    mode: build
+    build:
+      dockerfilePath: ${@REPOPATH}
+      volumes:
+        - value: ${@REPOPATH}:/home/node/app:delegated
+services:
+  venue-core-service:
+    extends: node
+    envVars:
+      LOG_LEVEL: debug
+    repo:
+      name: TouchBistro/venue-core-service
+`,
+	})
+	result, err := registry.ReadAll([]registry.Registry{{Name: "TouchBistro/tb-registry", Path: registryPath}}, registry.ReadAllOptions{
+		ReadServices: true,
+		RootPath:     "/home/test/.tb",
+		ReposPath:    "/home/test/.tb/repos",
+	})
+	is.NoErr(err)
+	s, err := result.Services.Get("venue-core-service")
+	is.NoErr(err)
+	is.Equal(s, service.Service{
+		EnvVars: map[string]string{
+			"LOG_LEVEL": "debug",
+			"NODE_ENV":  "development",
+		},
+		GitRepo: service.GitRepo{Name: "TouchBistro/venue-core-service"},
+		Mode:    service.ModeBuild,
+		Build: service.Build{
+			DockerfilePath: "/home/test/.tb/repos/TouchBistro/venue-core-service",
+			Volumes: []service.Volume{
+				{Value: "/home/test/.tb/repos/TouchBistro/venue-core-service:/home/node/app:delegated"},
+			},
+		},
+		Extends:      "node",
+		Name:         "venue-core-service",
+		RegistryName: "TouchBistro/tb-registry",
+	})
+}
+
+func TestReadServicesVariables(t *testing.T) {
+	t.Setenv("TB_TEST_AWS_PROFILE", "dev")
+	t.Setenv("TB_TEST_EMPTY", "")
+	is := is.New(t)
+	registryPath := newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: `global:
+  variables:
+    logLevel: info
+services:
+  postgres:
+    envVars:
+      AWS_PROFILE: ${@hostenv:TB_TEST_AWS_PROFILE:-default}
+      DATA_DIR: ${@hostenv:TB_TEST_DATA_DIR:-/var/lib/data}
+      EMPTY: ${@hostenv:TB_TEST_EMPTY:-fallback}
+      LOG_LEVEL: ${logLevel}
+      REGION: ${region}
+    mode: remote
+    remote:
+      image: postgres
+`,
+	})
+	registries := []registry.Registry{{Name: "TouchBistro/tb-registry", Path: registryPath}}
+	result, err := registry.ReadAll(registries, registry.ReadAllOptions{
+		ReadServices: true,
+		RootPath:     "/home/test/.tb",
+		ReposPath:    "/home/test/.tb/repos",
+		Variables: map[string]string{
+			"logLevel": "debug",
+			"region":   "ca-central-1",
+		},
+	})
+	is.NoErr(err)
+	s, err := result.Services.Get("postgres")
+	is.NoErr(err)
+	is.Equal(s.EnvVars, map[string]string{
+		"AWS_PROFILE": "dev",
+		"DATA_DIR":    "/var/lib/data",
+		"EMPTY":       "fallback",
+		"LOG_LEVEL":   "debug",
+		"REGION":      "ca-central-1",
+	})
+
+	// Variables provided by tb cannot be overridden.
+	_, err = registry.ReadAll(registries, registry.ReadAllOptions{
+		ReadServices: true,
+		Variables:    map[string]string{"@ROOTPATH": "/tmp"},
+	})
+	is.True(err != nil)
+}
+
+func TestReadServicesOverrideVariables(t *testing.T) {
+	is := is.New(t)
+	registryPath := newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: `global:
+  variables:
+    dataDir: /var/lib/postgresql/data
+services:
+  postgres:
+    mode: remote
+    remote:
+      image: postgres
+  venue-core-service:
+    mode: remote
+    remote:
+      image: venue-core-service
+`,
+	})
+	overrides := map[string]service.ServiceOverride{
+		"TouchBistro/tb-registry/venue-core-service": {
+			Dependencies: service.StringListOverride{Add: []string{"${@postgres}"}},
+			EnvFile:      "${@ROOTPATH}/venue-core-service.env",
+			Ports:        service.StringListOverride{Add: []string{"${HTTP_PORT}:8080"}},
+			Remote: service.RemoteOverride{
+				Volumes: service.VolumeListOverride{
+					Add: []service.Volume{{Value: "${@ROOTPATH}/data:${dataDir}"}},
+				},
+			},
+		},
+	}
+	result, err := registry.ReadAll([]registry.Registry{{Name: "TouchBistro/tb-registry", Path: registryPath}}, registry.ReadAllOptions{
+		ReadServices: true,
+		RootPath:     "/home/test/.tb",
+		ReposPath:    "/home/test/.tb/repos",
+		Overrides:    overrides,
+	})
+	is.NoErr(err)
+	s, err := result.Services.Get("venue-core-service")
+	is.NoErr(err)
+	is.Equal(s.Dependencies, []string{"touchbistro-tb-registry-postgres"})
+	is.Equal(s.EnvFile, "/home/test/.tb/venue-core-service.env")
+	is.Equal(s.Remote.Volumes, []service.Volume{{Value: "/home/test/.tb/data:/var/lib/postgresql/data"}})
+	// Ports are not expanded, same as the ports of services.
+	is.Equal(s.Ports, []string{"${HTTP_PORT}:8080"})
+	// The overrides are not changed.
+	is.Equal(overrides["TouchBistro/tb-registry/venue-core-service"].EnvFile, "${@ROOTPATH}/venue-core-service.env")
+}
+
+func TestValidateHostEnvErrors(t *testing.T) {
+	is := is.New(t)
+	registryPath := newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: `services:
+  postgres:
+    envVars:
+      AWS_PROFILE: ${@hostenv:TB_TEST_UNSET_VAR}
+      BAD: ${@hostenv:NOT-VALID:-x}
+    mode: remote
+    remote:
+      image: postgres
+`,
+	})
+	result := registry.Validate(registryPath, registry.ValidateOptions{Strict: true})
+	is.True(result.ServicesErr != nil)
+	var msgs []string
+	for _, f := range result.Findings {
+		is.Equal(f.Rule, registry.RuleUnknownVariable)
+		msgs = append(msgs, f.Message)
+	}
+	sort.Strings(msgs)
+	is.Equal(msgs, []string{
+		`envVars: environment variable TB_TEST_UNSET_VAR is not set and has no default`,
+		`envVars: invalid environment variable name "NOT-VALID"`,
+	})
+}
+
+func TestValidateTemplateErrors(t *testing.T) {
+	is := is.New(t)
+	registryPath := newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: `templates:
+  cycle-a:
+    extends: cycle-b
+  cycle-b:
+    extends: cycle-a
+services:
+  postgres:
+    extends: nope
+    mode: remote
+    remote:
+      image: postgres
+  redis:
+    extends: cycle-a
+    mode: remote
+    remote:
+      image: redis
+`,
+	})
+	result := registry.Validate(registryPath, registry.ValidateOptions{})
+	is.True(result.ServicesErr != nil)
+
+	type finding struct {
+		line    int
+		rule    string
+		message string
+	}
+	var findings []finding
+	for _, f := range result.Findings {
+		findings = append(findings, finding{f.Line, f.Rule, f.Message})
+	}
+	is.Equal(findings, []finding{
+		{8, registry.RuleUnknownTemplate, "template nope does not exist"},
+		{13, registry.RuleTemplateExtendsCycle, "template extends cycle cycle-a -> cycle-b -> cycle-a"},
+	})
+}
+
+func TestReadRegistriesSplitFiles(t *testing.T) {
+	is := is.New(t)
+	registryPath := newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: `global:
+  variables:
+    docker: 12345.dkr.ecr.us-east-1.amazonaws.com
+services:
+  postgres:
+    mode: remote
+    remote:
+      image: postgres
+`,
+		"services.d/venue-core-service.yml": `services:
+  venue-core-service:
+    dependencies:
+      - ${@redis}
+    mode: remote
+    remote:
+      image: ${docker}/venue-core-service
+`,
+		"services.d/redis.yml": `services:
+  redis:
+    mode: remote
+    remote:
+      image: redis
+`,
+		"services.d/README.md":     "Not a config file",
+		"playlists.d/core.yml":     "core:\n  services:\n    - postgres\n",
+		registry.AppsFileName:      "",
+		"apps.d/ios.yml":           "iosApps:\n  TB:\n    bundleID: com.example.TB\n    branch: master\n    repo: TouchBistro/tb-ios\n    runsOn: all\n",
+		"apps.d/desktop.yml":       "desktopApps:\n  TB:\n    branch: master\n    repo: TouchBistro/tb-desktop\n",
+		"playlists.d/nested/x.yml": "ignored:\n  services:\n    - postgres\n",
+	})
+	result, err := registry.ReadAll([]registry.Registry{{Name: "TouchBistro/tb-registry", Path: registryPath}}, registry.ReadAllOptions{
+		ReadServices: true,
+		ReadApps:     true,
+		RootPath:     "/home/test/.tb",
+		ReposPath:    "/home/test/.tb/repos",
+	})
+	is.NoErr(err)
+	is.Equal(result.Services.Len(), 3)
+	s, err := result.Services.Get("venue-core-service")
+	is.NoErr(err)
+	// Variables are shared across files.
+	is.Equal(s.Dependencies, []string{"touchbistro-tb-registry-redis"})
+	is.Equal(s.Remote.Image, "12345.dkr.ecr.us-east-1.amazonaws.com/venue-core-service")
+	is.Equal(result.Playlists.Names(), []string{"TouchBistro/tb-registry/core"})
+	is.Equal(result.IOSApps.Len(), 1)
+	is.Equal(result.DesktopApps.Len(), 1)
+}
+
+func TestReadRegistriesPriorityAndReplaces(t *testing.T) {
+	is := is.New(t)
+	services := `services:
+  postgres:
+    mode: remote
+    remote:
+      image: postgres
+  redis:
+    mode: remote
+    remote:
+      image: redis
+`
+	basePath := newTestRegistry(t, map[string]string{registry.ServicesFileName: services})
+	teamPath := newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: services + `  venue-core-service:
+    mode: remote
+    replaces: TouchBistro/tb-registry/venue-core-service
+    remote:
+      image: venue-core-service
+`,
+	})
+	registries := []registry.Registry{
+		{Name: "TouchBistro/tb-registry", Path: basePath, Priority: 10},
+		{Name: "ExampleZone/tb-registry", Path: teamPath},
+	}
+	_, err := registry.ReadAll(registries, registry.ReadAllOptions{ReadServices: true})
+	is.True(err != nil) // replaced service does not exist
+	is.True(strings.Contains(err.Error(), "failed to replace TouchBistro/tb-registry/venue-core-service"))
+
+	// Replacing a resource from a registry that isn't used does nothing.
+	_, err = registry.ReadAll(registries[1:], registry.ReadAllOptions{ReadServices: true})
+	is.NoErr(err)
+
+	teamPath = newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: services + "    replaces: TouchBistro/tb-registry/redis\n",
+	})
+	registries[1].Path = teamPath
+	result, err := registry.ReadAll(registries, registry.ReadAllOptions{ReadServices: true})
+	is.NoErr(err)
+	s, err := result.Services.Get("postgres")
+	is.NoErr(err)
+	is.Equal(s.RegistryName, "TouchBistro/tb-registry") // higher priority
+	s, err = result.Services.Get("redis")
+	is.NoErr(err)
+	is.Equal(s.RegistryName, "ExampleZone/tb-registry") // replaced
+	by, ok := result.Services.ShadowedBy("TouchBistro/tb-registry/redis")
+	is.True(ok)
+	is.Equal(by, "ExampleZone/tb-registry/redis")
+}
+
+func TestValidateSplitFiles(t *testing.T) {
+	is := is.New(t)
+	registryPath := newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: `services:
+  postgres:
+    mode: remote
+    remote:
+      image: postgres
+`,
+		"services.d/a.yml": `services:
+  venue-core-service:
+    dependencies:
+      - ${@redis}
+    mode: remote
+    remote:
+      image: venue-core-service
+`,
+		"services.d/b.yml": `services:
+  postgres:
+    mode: remote
+    remote:
+      image: postgres
+`,
+		"playlists.d/core.yml": "core:\n  services:\n    - postgres\n",
+		"playlists.d/more.yml": "core:\n  services:\n    - redis\n",
+	})
+	result := registry.Validate(registryPath, registry.ValidateOptions{})
+	is.True(errors.Is(result.AppsErr, fs.ErrNotExist))
+	is.True(result.PlaylistsErr != nil)
+	is.True(result.ServicesErr != nil)
+	is.True(strings.Contains(result.ServicesErr.Error(), "defined in both services.yml and services.d/b.yml"))
+
+	type finding struct {
+		file string
+		line int
+		rule string
+	}
+	var findings []finding
+	for _, f := range result.Findings {
+		findings = append(findings, finding{f.File, f.Line, f.Rule})
+	}
+	is.Equal(findings, []finding{
+		{"playlists.d/more.yml", 1, registry.RuleDuplicateName},
+		{"services.d/b.yml", 2, registry.RuleDuplicateName},
+	})
+
+	// Checks across resources are attributed to the file the resource was defined in.
+	if err := os.Remove(filepath.Join(registryPath, "services.d", "b.yml")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	result = registry.Validate(registryPath, registry.ValidateOptions{})
+	is.Equal(len(result.Findings), 2)
+	is.Equal(result.Findings[1].File, "services.d/a.yml")
+	is.Equal(result.Findings[1].Line, 4)
+	is.Equal(result.Findings[1].Rule, registry.RuleUnknownDependency)
+}
+
+func TestValidateStrictUnknownKeys(t *testing.T) {
+	is := is.New(t)
+	registryPath := newTestRegistry(t, map[string]string{
+		registry.ServicesFileName: `services:
+  postgres:
+    envvars:
+      POSTGRES_USER: core
+    mode: remote
+    remote:
+      image: postgres
+`,
+		"playlists.d/core.yml": "core:\n  service:\n    - postgres\n",
+		registry.AppsFileName: `iosApps:
+  TouchBistro:
+    bundleID: com.touchbistro.TouchBistro
+    branch: master
+    runsOn: iPad
+    storage:
+      provider: s3
+      bucket: tb-ios-builds
+    envvars:
+      debug.autoLogin: true
+`,
+	})
+
+	// Unknown keys are ignored when not in strict mode.
+	result := registry.Validate(registryPath, registry.ValidateOptions{})
+	is.NoErr(result.AppsErr)
+	is.NoErr(result.PlaylistsErr)
+	is.NoErr(result.ServicesErr)
+
+	result = registry.Validate(registryPath, registry.ValidateOptions{Strict: true})
+	is.True(result.AppsErr != nil)
+	is.True(result.PlaylistsErr != nil)
+	is.True(result.ServicesErr != nil)
+	is.True(strings.Contains(result.ServicesErr.Error(), `services.yml: line 3: unknown key "envvars" in services.postgres, did you mean "envVars"?`))
+	is.Equal(result.Findings, []registry.Finding{
+		{
+			File:     registry.AppsFileName,
+			Line:     9,
+			Column:   5,
+			Rule:     registry.RuleUnknownKey,
+			Severity: registry.SeverityError,
+			Message:  `unknown key "envvars" in iosApps.TouchBistro, did you mean "envVars"?`,
+		},
+		{
+			File:     "playlists.d/core.yml",
+			Line:     2,
+			Column:   3,
+			Rule:     registry.RuleUnknownKey,
+			Severity: registry.SeverityError,
+			Message:  `unknown key "service" in core, did you mean "services"?`,
+		},
+		{
+			File:     registry.ServicesFileName,
+			Line:     3,
+			Column:   5,
+			Rule:     registry.RuleUnknownKey,
+			Severity: registry.SeverityError,
+			Message:  `unknown key "envvars" in services.postgres, did you mean "envVars"?`,
+		},
+	})
+}
+
+// newTestRegistry creates a registry in a temp dir containing files,
+// which maps each file path to its contents.
+func newTestRegistry(t *testing.T, files map[string]string) string {
+	t.Helper()
+	registryPath := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(registryPath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("failed to write file %s: %v", name, err)
+		}
+	}
+	return registryPath
+}
+
+func TestSchemas(t *testing.T) {
+	schemas := map[string]*jsonschema.Schema{
+		registry.ServicesFileName:  registry.ServicesSchema(),
+		registry.PlaylistsFileName: registry.PlaylistsSchema(),
+		registry.AppsFileName:      registry.AppsSchema(),
+	}
+	for name, s := range schemas {
+		t.Run(name, func(t *testing.T) {
+			// Every field should be documented so editors can show what it does.
+			var check func(path string, s *jsonschema.Schema)
+			check = func(path string, s *jsonschema.Schema) {
+				for prop, ps := range s.Properties {
+					if ps.Description == "" {
+						t.Errorf("property %s.%s has no description", path, prop)
+					}
+					check(path+"."+prop, ps)
+				}
+				if ps, ok := s.AdditionalProperties.(*jsonschema.Schema); ok {
+					check(path+".*", ps)
+				}
+				if s.Items != nil {
+					check(path+"[]", s.Items)
+				}
+			}
+			check(name, s)
+			for defName, def := range s.Definitions {
+				check(defName, def)
+			}
+		})
+	}
+
+	is := is.New(t)
+	def := schemas[registry.ServicesFileName].Definitions["service.Service"]
+	is.Equal(def.Properties["mode"].Enum, []string{service.ModeRemote, service.ModeBuild})
+}
@@ -0,0 +1,33 @@
+package registry
+
+import (
+	"github.com/TouchBistro/tb/internal/jsonschema"
+	"github.com/TouchBistro/tb/resource/playlist"
+)
+
+// ServicesSchema returns the JSON Schema of services.yml, which also applies to the files in services.d.
+func ServicesSchema() *jsonschema.Schema {
+	return jsonschema.Reflect(registryServiceConfig{}, "tb registry "+ServicesFileName)
+}
+
+// PlaylistsSchema returns the JSON Schema of playlists.yml, which also applies to the files in playlists.d.
+func PlaylistsSchema() *jsonschema.Schema {
+	return jsonschema.Reflect(map[string]playlist.Playlist{}, "tb registry "+PlaylistsFileName)
+}
+
+// AppsSchema returns the JSON Schema of apps.yml, which also applies to the files in apps.d.
+func AppsSchema() *jsonschema.Schema {
+	return jsonschema.Reflect(registryAppConfig{}, "tb registry "+AppsFileName)
+}
+
+// fileSchema returns the schema of the registry file with the given name.
+func fileSchema(filename string) *jsonschema.Schema {
+	switch filename {
+	case ServicesFileName:
+		return ServicesSchema()
+	case PlaylistsFileName:
+		return PlaylistsSchema()
+	default:
+		return AppsSchema()
+	}
+}
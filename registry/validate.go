@@ -0,0 +1,229 @@
+package registry
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/TouchBistro/goutils/errors"
+	"github.com/TouchBistro/goutils/file"
+	"github.com/TouchBistro/tb/integrations/docker"
+	"github.com/TouchBistro/tb/resource"
+	"github.com/TouchBistro/tb/resource/playlist"
+	"github.com/TouchBistro/tb/resource/service"
+)
+
+// Rules used by Validate to identify validation failures. They are set as the Rule field
+// of each resource.ValidationError returned by Validate that was caused by a check across resources
+// or by a strict mode check. Rules are stable and will not change between versions of tb.
+const (
+	// RulePortConflict means multiple services publish the same host port.
+	RulePortConflict = "port-conflict"
+	// RuleUnknownDependency means a service depends on a service that does not exist.
+	RuleUnknownDependency = "unknown-dependency"
+	// RuleUnknownPlaylistService means a playlist contains a service that does not exist.
+	RuleUnknownPlaylistService = "unknown-playlist-service"
+	// RuleUnknownPlaylistExtends means a playlist extends a playlist that does not exist.
+	RuleUnknownPlaylistExtends = "unknown-playlist-extends"
+	// RulePlaylistExtendsCycle means a playlist extends itself through a chain of extends.
+	RulePlaylistExtendsCycle = "playlist-extends-cycle"
+	// RuleNamedVolumeCollision means multiple services use the same named volume.
+	RuleNamedVolumeCollision = "named-volume-collision"
+	// RuleMissingEnvFile means the envFile of a service does not exist.
+	RuleMissingEnvFile = "missing-env-file"
+	// RuleMissingDockerfilePath means the build.dockerfilePath of a service does not exist.
+	RuleMissingDockerfilePath = "missing-dockerfile-path"
+	// RuleUnknownVariable means a service uses a variable that is not defined.
+	// It is only reported in strict mode.
+	RuleUnknownVariable = "unknown-variable"
+	// RuleUnknownTemplate means a service or template extends a template that does not exist.
+	RuleUnknownTemplate = "unknown-template"
+	// RuleTemplateExtendsCycle means a template extends itself through a chain of extends.
+	RuleTemplateExtendsCycle = "template-extends-cycle"
+	// RuleDuplicateName means multiple resources of the same type have the same name.
+	// This can happen when config is split across multiple files.
+	RuleDuplicateName = "duplicate-name"
+)
+
+// validateServices performs validations across all services in the registry r.
+// reposPath is where service repos are cloned, it is used to check if paths within
+// repos exist. If it is empty, paths within repos are not checked.
+func validateServices(r Registry, services *resource.Collection[service.Service], reposPath string) errors.List {
+	// Sort services so errors are reported in a deterministic order.
+	var sorted []service.Service
+	containerNames := make(map[string]bool)
+	for it := services.Iter(); it.Next(); {
+		s := it.Value()
+		sorted = append(sorted, s)
+		containerNames[docker.NormalizeName(s.FullName())] = true
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].FullName() < sorted[j].FullName()
+	})
+
+	var errs errors.List
+	newErr := func(s service.Service, rule, field, msg string) {
+		errs = append(errs, &resource.ValidationError{Resource: s, Messages: []string{msg}, Rule: rule, Field: field})
+	}
+
+	// Keep track of ports and volumes to check for conflicts
+	usedPorts := make(map[string]string)
+	usedVolumes := make(map[string]string)
+	staticPath := filepath.Join(r.Path, staticDirName)
+	for _, s := range sorted {
+		// Check for port conflict. Port conflicts shouldn't be allowed in the same registry
+		// since this just causes confusion and a poor user experience.
+		for i, p := range s.Ports {
+			// ports are of the form EXTERNAL:INTERNAL
+			// get external part
+			exposedPort := strings.Split(p, ":")[0]
+			conflict, ok := usedPorts[exposedPort]
+			if !ok {
+				usedPorts[exposedPort] = s.Name
+				continue
+			}
+			newErr(s, RulePortConflict, fmt.Sprintf("ports.%d", i), fmt.Sprintf("conflicting port %s with service %s", exposedPort, conflict))
+		}
+
+		// Dependencies are container names, which is what @<service> expands to, but can also be full names.
+		for i, dep := range s.Dependencies {
+			if !containerNames[docker.NormalizeName(dep)] {
+				newErr(s, RuleUnknownDependency, fmt.Sprintf("dependencies.%d", i), fmt.Sprintf("dependency %s does not exist", dep))
+			}
+		}
+
+		// Named volumes are shared by all containers that use them, so two services using the
+		// same named volume are almost certainly overwriting each other's data.
+		volumes, volumesField := s.Build.Volumes, "build.volumes"
+		if s.Mode == service.ModeRemote {
+			volumes, volumesField = s.Remote.Volumes, "remote.volumes"
+		}
+		for i, v := range volumes {
+			if !v.IsNamed {
+				continue
+			}
+			name := strings.Split(v.Value, ":")[0]
+			conflict, ok := usedVolumes[name]
+			if !ok {
+				usedVolumes[name] = s.Name
+				continue
+			}
+			if conflict != s.Name {
+				newErr(s, RuleNamedVolumeCollision, fmt.Sprintf("%s.%d", volumesField, i), fmt.Sprintf("named volume %s is also used by service %s", name, conflict))
+			}
+		}
+
+		// Check that paths exist. Only paths within the registry's static dir or the service's repo
+		// can be checked, since other paths depend on the machine tb is run on.
+		var repoPath string
+		if reposPath != "" && s.HasGitRepo() {
+			repoPath = filepath.Join(reposPath, s.GitRepo.Name)
+			if !file.Exists(repoPath) {
+				// Repo isn't cloned so nothing in it can be checked.
+				repoPath = ""
+			}
+		}
+		checkPath := func(p, rule, fieldName string) {
+			if p == "" || (!isWithin(p, staticPath) && (repoPath == "" || !isWithin(p, repoPath))) {
+				return
+			}
+			if !file.Exists(p) {
+				newErr(s, rule, fieldName, fmt.Sprintf("%s %s does not exist", fieldName, p))
+			}
+		}
+		checkPath(s.EnvFile, RuleMissingEnvFile, "envFile")
+		checkPath(s.Build.DockerfilePath, RuleMissingDockerfilePath, "build.dockerfilePath")
+	}
+	return errs
+}
+
+// validatePlaylists performs validations across all playlists in the registry r.
+// If services is nil, the services in playlists will not be checked.
+func validatePlaylists(r Registry, playlists *playlist.Collection, services *resource.Collection[service.Service]) errors.List {
+	names := playlists.Names()
+	sort.Strings(names)
+	var errs errors.List
+	for _, name := range names {
+		p, err := playlists.Get(name)
+		if err != nil {
+			// Shouldn't happen since the name came from the collection.
+			errs = append(errs, err)
+			continue
+		}
+		newErr := func(rule, field, msg string) {
+			errs = append(errs, &resource.ValidationError{Resource: p, Messages: []string{msg}, Rule: rule, Field: field})
+		}
+
+		// Only resources in this registry can be checked, ones from other registries are not available.
+		if services != nil {
+			for i, sn := range p.Services {
+				if inRegistry(r, sn) && !collectionHas(services, sn) {
+					newErr(RuleUnknownPlaylistService, fmt.Sprintf("services.%d", i), fmt.Sprintf("service %s does not exist", sn))
+				}
+			}
+		}
+		if p.Extends == "" || !inRegistry(r, p.Extends) {
+			continue
+		}
+		if _, err := playlists.Get(p.Extends); err != nil {
+			newErr(RuleUnknownPlaylistExtends, "extends", fmt.Sprintf("extended playlist %s does not exist", p.Extends))
+			continue
+		}
+
+		// Follow the chain of extends to see if it leads back to this playlist.
+		chain := []string{p.FullName()}
+		seen := map[string]bool{p.FullName(): true}
+		for cur := p; cur.Extends != "" && inRegistry(r, cur.Extends); {
+			next, err := playlists.Get(cur.Extends)
+			if err != nil {
+				// Missing playlist will be reported for the playlist that extends it.
+				break
+			}
+			chain = append(chain, next.FullName())
+			if next.FullName() == p.FullName() {
+				newErr(RulePlaylistExtendsCycle, "extends", fmt.Sprintf("extends cycle %s", strings.Join(chain, " -> ")))
+				break
+			}
+			if seen[next.FullName()] {
+				// Cycle that doesn't include p, it will be reported for the playlists in it.
+				break
+			}
+			seen[next.FullName()] = true
+			cur = next
+		}
+	}
+	return errs
+}
+
+// withSources associates each error in errs with the file its resource was read from.
+// sources maps the full name of each resource to its file.
+func withSources(errs errors.List, sources map[string]string) errors.List {
+	for i, err := range errs {
+		var ve *resource.ValidationError
+		if errors.As(err, &ve) {
+			if path, ok := sources[ve.Resource.FullName()]; ok {
+				errs[i] = &fileError{path: path, err: err}
+			}
+		}
+	}
+	return errs
+}
+
+// inRegistry reports whether the resource with the given full name belongs to the registry r.
+func inRegistry(r Registry, fullName string) bool {
+	registryName, _, err := resource.ParseName(fullName)
+	return err == nil && registryName == r.Name
+}
+
+// collectionHas reports whether the resource with the given full name exists in c.
+func collectionHas[R resource.Resource](c *resource.Collection[R], fullName string) bool {
+	_, err := c.Get(fullName)
+	return err == nil
+}
+
+// isWithin reports whether path is dir or is contained in dir.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
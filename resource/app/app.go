@@ -21,23 +21,25 @@ const (
 type App struct {
 	// These fields are iOS specific
 
-	BundleID string `yaml:"bundleID"`
-	RunsOn   string `yaml:"runsOn"`
+	BundleID string `yaml:"bundleID" desc:"Bundle identifier of the iOS app."`
+	RunsOn   string `yaml:"runsOn" desc:"Type of device the iOS app runs on, one of all, ipad, or iphone, case insensitive." pattern:"^([aA][lL][lL]|[iI][pP][aA][dD]|[iI][pP][hH][oO][nN][eE])$"`
 
 	// General fields
 
-	Branch  string            `yaml:"branch"`
-	GitRepo string            `yaml:"repo"`
-	EnvVars map[string]string `yaml:"envVars"`
-	Storage Storage           `yaml:"storage"`
+	Branch  string            `yaml:"branch" desc:"Default branch to use when fetching builds of the app."`
+	GitRepo string            `yaml:"repo" desc:"Name of the repo on GitHub, of the form <org>/<repo>."`
+	EnvVars map[string]string `yaml:"envVars" desc:"Env vars to set when running the app."`
+	Storage Storage           `yaml:"storage" desc:"Where builds of the app are stored."`
+	// Replaces is the full name of an app with the same name in another registry that this app replaces.
+	Replaces string `yaml:"replaces" desc:"Full name of an app with the same name in another registry that this app replaces."`
 	// Not part of yaml, set at runtime
 	Name         string `yaml:"-"`
 	RegistryName string `yaml:"-"`
 }
 
 type Storage struct {
-	Provider string `yaml:"provider"`
-	Bucket   string `yaml:"bucket"`
+	Provider string `yaml:"provider" desc:"Storage provider the builds are stored in." enum:"s3"`
+	Bucket   string `yaml:"bucket" desc:"Name of the bucket the builds are stored in."`
 }
 
 func (App) Type() resource.Type {
@@ -17,8 +17,8 @@ import (
 // Playlists can extend another playlist which effectively merges
 // the lists of services together.
 type Playlist struct {
-	Extends  string   `yaml:"extends,omitempty"`
-	Services []string `yaml:"services"`
+	Extends  string   `yaml:"extends,omitempty" desc:"Name of a playlist to extend, its services are included in this playlist."`
+	Services []string `yaml:"services" desc:"Names of the services in the playlist."`
 	// Not part of yaml, set at runtime
 	Name         string `yaml:"-"`
 	RegistryName string `yaml:"-"`
@@ -71,6 +71,26 @@ func (c *Collection) Set(p Playlist) error {
 	return c.collection.Set(p)
 }
 
+// SetPriority sets the priority of the registry with the given name.
+// See resource.Collection.SetPriority for more details.
+func (c *Collection) SetPriority(registryName string, priority int) {
+	c.collection.SetPriority(registryName, priority)
+}
+
+// ShadowedBy returns the name of the playlist that the short name of the playlist with the given
+// full name resolves to, if it is a different playlist. Custom playlists shadow all registry
+// playlists with the same name. If the playlist is not shadowed, false is returned.
+func (c *Collection) ShadowedBy(fullName string) (string, bool) {
+	registryName, name, err := resource.ParseName(fullName)
+	if err != nil || registryName == "" {
+		return "", false
+	}
+	if _, ok := c.customPlaylists[name]; ok {
+		return name, true
+	}
+	return c.collection.ShadowedBy(fullName)
+}
+
 // SetCustom sets a custom playlist. Custom playlists exist outside of registries,
 // and take priority over playlists within registries during lookup.
 func (c *Collection) SetCustom(p Playlist) {
@@ -8,6 +8,7 @@ package resource
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/TouchBistro/goutils/errors"
@@ -46,9 +47,18 @@ func (t Type) String() string {
 
 // ValidationError represents a resource having failed validation.
 // It contains the resource that failed validation and a list of validation failure messages.
+//
+// Rule optionally identifies the validation rule that failed. Rules are stable identifiers
+// that can be used to programmatically identify a kind of failure.
+//
+// Field optionally identifies the field of the resource that failed validation. It is a path
+// of yaml keys separated by dots, where list elements are identified by their index,
+// ex: remote.volumes.0.
 type ValidationError struct {
 	Resource Resource
 	Messages []string
+	Rule     string
+	Field    string
 }
 
 func (ve *ValidationError) Error() string {
@@ -63,6 +73,11 @@ func (ve *ValidationError) Error() string {
 		}
 		sb.WriteString(msg)
 	}
+	if ve.Rule != "" {
+		sb.WriteString(" [")
+		sb.WriteString(ve.Rule)
+		sb.WriteString("]")
+	}
 	return sb.String()
 }
 
@@ -105,6 +120,10 @@ type Collection[R Resource] struct {
 	// nameMap is a map of short names to a list of indices
 	// for each matching resource in resources.
 	nameMap map[string][]int
+	// priorities is the priority of each registry, used to resolve short names.
+	priorities map[string]int
+	// replacedBy maps the full name of a replaced resource to the full name of the resource that replaces it.
+	replacedBy map[string]string
 }
 
 // Len returns the number of resources stored in the Collection.
@@ -118,8 +137,11 @@ func (c *Collection[R]) Len() int {
 // Get retrieves the resource with the given name from the Collection.
 // name can either be the full name or the short name of the resource.
 //
-// If no resource is found, ErrNotFound is returned. If name is a short name
-// and multiple resources are found, ErrMultipleResources is returned.
+// If name is a short name and multiple resources match, resources that have been replaced
+// are ignored and the resource from the registry with the highest priority is returned.
+//
+// If no resource is found, ErrNotFound is returned. If name is a short name and multiple
+// resources with the same priority are found, ErrMultipleResources is returned.
 func (c *Collection[R]) Get(name string) (R, error) {
 	const op = errors.Op("resource.Collection.Get")
 	// Create zero value that we can return on error
@@ -140,10 +162,11 @@ func (c *Collection[R]) Get(name string) (R, error) {
 
 	// Handle short name
 	if registryName == "" {
-		if len(bucket) > 1 {
+		ri, ok := c.resolveShortName(bucket)
+		if !ok {
 			return r, errors.Wrap(ErrMultipleResources, errMeta)
 		}
-		return c.resources[bucket[0]], nil
+		return c.resources[ri], nil
 	}
 	for _, ri := range bucket {
 		r := c.resources[ri]
@@ -154,6 +177,130 @@ func (c *Collection[R]) Get(name string) (R, error) {
 	return r, errors.Wrap(ErrNotFound, errMeta)
 }
 
+// resolveShortName returns the index of the resource that a short name resolves to,
+// where bucket is the list of indices of resources with that short name.
+// It returns false if multiple resources match.
+func (c *Collection[R]) resolveShortName(bucket []int) (int, bool) {
+	best := -1
+	ambiguous := false
+	for _, ri := range bucket {
+		r := c.resources[ri]
+		if _, ok := c.replacedBy[r.FullName()]; ok {
+			continue
+		}
+		if best == -1 {
+			best = ri
+			continue
+		}
+		rp, bp := c.priority(r), c.priority(c.resources[best])
+		if rp > bp {
+			best, ambiguous = ri, false
+		} else if rp == bp {
+			ambiguous = true
+		}
+	}
+	return best, best != -1 && !ambiguous
+}
+
+func (c *Collection[R]) priority(r R) int {
+	registryName, _, _ := ParseName(r.FullName())
+	return c.priorities[registryName]
+}
+
+// SetPriority sets the priority of the registry with the given name. If a short name matches
+// resources from multiple registries, the resource from the registry with the highest priority is used.
+// Registries have a priority of 0 by default.
+func (c *Collection[R]) SetPriority(registryName string, priority int) {
+	if c.priorities == nil {
+		c.priorities = make(map[string]int)
+	}
+	c.priorities[registryName] = priority
+}
+
+// Replace marks the resource with the full name oldName as replaced by the resource with
+// the full name newName. A replaced resource is ignored when resolving a short name, but
+// can still be retrieved using its full name.
+//
+// Both resources must be in the Collection and must have the same short name.
+// A resource can only be replaced by one other resource. A replaced resource cannot replace
+// other resources, since this depends on the order replacements are made in it is not checked
+// by Replace, use ValidateReplaces once all replacements have been made.
+func (c *Collection[R]) Replace(oldName, newName string) error {
+	const op = errors.Op("resource.Collection.Replace")
+	for _, name := range []string{oldName, newName} {
+		registryName, _, err := ParseName(name)
+		if err != nil {
+			return errors.Wrap(err, errors.Meta{Kind: errkind.Invalid, Op: op})
+		}
+		if registryName == "" {
+			return errors.New(errkind.Invalid, fmt.Sprintf("%s is not a full name", name), op)
+		}
+		if _, err := c.Get(name); err != nil {
+			return errors.Wrap(err, errors.Meta{Op: op})
+		}
+	}
+	_, oldShortName, _ := ParseName(oldName)
+	_, newShortName, _ := ParseName(newName)
+	if oldShortName != newShortName {
+		msg := fmt.Sprintf("%s cannot replace %s, they must have the same name", newName, oldName)
+		return errors.New(errkind.Invalid, msg, op)
+	}
+	if other, ok := c.replacedBy[oldName]; ok && other != newName {
+		return errors.New(errkind.Invalid, fmt.Sprintf("%s is already replaced by %s", oldName, other), op)
+	}
+	if c.replacedBy == nil {
+		c.replacedBy = make(map[string]string)
+	}
+	c.replacedBy[oldName] = newName
+	return nil
+}
+
+// ValidateReplaces checks that no replaced resource replaces another resource, i.e. that
+// there are no chains or cycles of replacements. Each invalid replacement is reported in
+// the returned errors.List, sorted by the name of the replaced resource.
+func (c *Collection[R]) ValidateReplaces() error {
+	const op = errors.Op("resource.Collection.ValidateReplaces")
+	if c == nil {
+		return nil
+	}
+	oldNames := make([]string, 0, len(c.replacedBy))
+	for oldName := range c.replacedBy {
+		oldNames = append(oldNames, oldName)
+	}
+	sort.Strings(oldNames)
+	var errs errors.List
+	for _, oldName := range oldNames {
+		newName := c.replacedBy[oldName]
+		if other, ok := c.replacedBy[newName]; ok {
+			msg := fmt.Sprintf("%s cannot replace %s since it is replaced by %s", newName, oldName, other)
+			errs = append(errs, errors.New(errkind.Invalid, msg, op))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ShadowedBy returns the full name of the resource that the short name of the resource with
+// the given full name resolves to, if it is a different resource. This happens if the resource
+// was replaced or if a resource with the same short name is in a registry with a higher priority.
+// If the resource is not shadowed, false is returned.
+func (c *Collection[R]) ShadowedBy(fullName string) (string, bool) {
+	_, resourceName, err := ParseName(fullName)
+	if c == nil || err != nil {
+		return "", false
+	}
+	ri, ok := c.resolveShortName(c.nameMap[resourceName])
+	if !ok {
+		return "", false
+	}
+	if name := c.resources[ri].FullName(); name != fullName {
+		return name, true
+	}
+	return "", false
+}
+
 // Set adds or replaces the resource in the Collection.
 // r.FullName() must return a valid full name or an error will be returned.
 func (c *Collection[R]) Set(r R) error {
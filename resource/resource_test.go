@@ -133,6 +133,97 @@ func TestCollectionGet(t *testing.T) {
 	}
 }
 
+func TestCollectionGetPriority(t *testing.T) {
+	is := is.New(t)
+	c := newCollection(t)
+	c.SetPriority("TouchBistro/tb-registry", 10)
+	s, err := c.Get("postgres")
+	is.NoErr(err)
+	is.Equal(s.RegistryName, "TouchBistro/tb-registry")
+	by, ok := c.ShadowedBy("ExampleZone/tb-registry/postgres")
+	is.True(ok)
+	is.Equal(by, "TouchBistro/tb-registry/postgres")
+	_, ok = c.ShadowedBy("TouchBistro/tb-registry/postgres")
+	is.True(!ok)
+
+	// Same priority is still ambiguous.
+	c.SetPriority("ExampleZone/tb-registry", 10)
+	_, err = c.Get("postgres")
+	is.True(errors.Is(err, resource.ErrMultipleResources))
+	_, ok = c.ShadowedBy("ExampleZone/tb-registry/postgres")
+	is.True(!ok)
+}
+
+func TestCollectionReplace(t *testing.T) {
+	is := is.New(t)
+	c := newCollection(t)
+	// Replacing takes precedence over priority.
+	c.SetPriority("TouchBistro/tb-registry", 10)
+	is.NoErr(c.Replace("TouchBistro/tb-registry/postgres", "ExampleZone/tb-registry/postgres"))
+	s, err := c.Get("postgres")
+	is.NoErr(err)
+	is.Equal(s.RegistryName, "ExampleZone/tb-registry")
+	by, ok := c.ShadowedBy("TouchBistro/tb-registry/postgres")
+	is.True(ok)
+	is.Equal(by, "ExampleZone/tb-registry/postgres")
+	// Replaced resources can still be retrieved by full name.
+	s, err = c.Get("TouchBistro/tb-registry/postgres")
+	is.NoErr(err)
+	is.Equal(s.Tag, "12-alpine")
+
+	tests := []struct {
+		name    string
+		oldName string
+		newName string
+		wantErr error
+	}{
+		{"not found", "TouchBistro/tb-registry/redis", "ExampleZone/tb-registry/postgres", resource.ErrNotFound},
+		{"short name", "postgres", "ExampleZone/tb-registry/postgres", nil},
+		{"different names", "TouchBistro/tb-registry/venue-core-service", "ExampleZone/tb-registry/postgres", nil},
+		{"already replaced", "TouchBistro/tb-registry/postgres", "TouchBistro/tb-registry/postgres", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			err := c.Replace(tt.oldName, tt.newName)
+			is.True(err != nil)
+			if tt.wantErr != nil {
+				is.True(errors.Is(err, tt.wantErr))
+			}
+		})
+	}
+}
+
+func TestCollectionValidateReplaces(t *testing.T) {
+	const (
+		base = "TouchBistro/tb-registry/postgres"
+		team = "ExampleZone/tb-registry/postgres"
+		dev  = "ExampleZone/tb-registry-dev/postgres"
+	)
+	tests := []struct {
+		name     string
+		replaces [][2]string
+		wantErr  bool
+	}{
+		{"valid", [][2]string{{base, dev}, {team, dev}}, false},
+		{"chain", [][2]string{{base, team}, {team, dev}}, true},
+		{"chain reversed", [][2]string{{team, dev}, {base, team}}, true},
+		{"cycle", [][2]string{{base, team}, {team, base}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			c := newCollection(t)
+			is.NoErr(c.Set(mockService{Name: "postgres", RegistryName: "ExampleZone/tb-registry-dev", Tag: "13"}))
+			for _, r := range tt.replaces {
+				is.NoErr(c.Replace(r[0], r[1]))
+			}
+			err := c.ValidateReplaces()
+			is.Equal(err != nil, tt.wantErr)
+		})
+	}
+}
+
 func TestCollectionGetEmpty(t *testing.T) {
 	tests := []struct {
 		name       string
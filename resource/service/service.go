@@ -4,12 +4,16 @@ package service
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/TouchBistro/goutils/errors"
 	"github.com/TouchBistro/tb/errkind"
 	"github.com/TouchBistro/tb/integrations/docker"
+	"github.com/TouchBistro/tb/internal/jsonschema"
 	"github.com/TouchBistro/tb/resource"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -19,43 +23,94 @@ const (
 
 // Service specifies the configuration for a service that can be run by tb.
 type Service struct {
-	Build        Build             `yaml:"build"`
-	Dependencies []string          `yaml:"dependencies"`
-	Entrypoint   []string          `yaml:"entrypoint"`
-	EnvFile      string            `yaml:"envFile"`
-	EnvVars      map[string]string `yaml:"envVars"`
-	GitRepo      GitRepo           `yaml:"repo"`
-	Mode         string            `yaml:"mode"`
-	Ports        []string          `yaml:"ports"`
-	PreRun       string            `yaml:"preRun"`
-	Remote       Remote            `yaml:"remote"`
+	Build        Build             `yaml:"build" desc:"How to build the service locally with docker build."`
+	Dependencies []string          `yaml:"dependencies" desc:"Containers of services that must be running for this service to run." vars:"true"`
+	Entrypoint   []string          `yaml:"entrypoint" desc:"Custom docker entrypoint." vars:"true"`
+	EnvFile      string            `yaml:"envFile" desc:"Path to a file containing env vars to set for the service." vars:"true"`
+	EnvVars      map[string]string `yaml:"envVars" desc:"Env vars to set for the service." vars:"true"`
+	GitRepo      GitRepo           `yaml:"repo" desc:"The git repo containing the service."`
+	Healthcheck  Healthcheck       `yaml:"healthcheck" desc:"How to determine if the service is ready to accept connections."`
+	Mode         string            `yaml:"mode" desc:"Whether to pull the service from a remote source or build it locally." enum:"remote,build"`
+	Ports        []string          `yaml:"ports" desc:"Ports to publish, of the form HOST:CONTAINER."`
+	PreRun       string            `yaml:"preRun" desc:"Script to run before starting the service, ex: yarn db:prepare."`
+	Remote       Remote            `yaml:"remote" desc:"How to pull the service from a remote docker registry."`
+	// Extends is the name of a template in the registry that the service is based on.
+	// See Extend for how the template is merged into the service.
+	Extends string `yaml:"extends" desc:"Name of a template in the registry to base the service on."`
+	// Replaces is the full name of a service with the same name in another registry that this service replaces.
+	Replaces string `yaml:"replaces" desc:"Full name of a service with the same name in another registry that this service replaces, ex: TouchBistro/tb-registry/postgres."`
 	// Not part of yaml, set at runtime
 	Name         string `yaml:"-"`
 	RegistryName string `yaml:"-"`
 }
 
 type Build struct {
-	Args           map[string]string `yaml:"args"`
-	Command        string            `yaml:"command"`
-	DockerfilePath string            `yaml:"dockerfilePath"`
-	Target         string            `yaml:"target"`
-	Volumes        []Volume          `yaml:"volumes"`
+	Args           map[string]string `yaml:"args" desc:"Build args to pass to docker build."`
+	Command        string            `yaml:"command" desc:"Command to run when the container starts."`
+	DockerfilePath string            `yaml:"dockerfilePath" desc:"Path to the directory containing the Dockerfile." vars:"true"`
+	Target         string            `yaml:"target" desc:"Target to build in a multi-stage build."`
+	Volumes        []Volume          `yaml:"volumes" desc:"Volumes to mount in the container."`
 }
 
 type GitRepo struct {
-	Name string `yaml:"name"`
+	Name string `yaml:"name" desc:"Name of the repo on GitHub, of the form <org>/<repo>."`
 }
 
 type Remote struct {
-	Command string   `yaml:"command"`
-	Image   string   `yaml:"image"`
-	Tag     string   `yaml:"tag"`
-	Volumes []Volume `yaml:"volumes"`
+	Command string   `yaml:"command" desc:"Command to run when the container starts."`
+	Image   string   `yaml:"image" desc:"Name of the image or a URI pointing to a remote docker registry." vars:"true"`
+	Tag     string   `yaml:"tag" desc:"Tag of the image to use, ex: master."`
+	Volumes []Volume `yaml:"volumes" desc:"Volumes to mount in the container."`
 }
 
 type Volume struct {
-	Value   string `yaml:"value"`
-	IsNamed bool   `yaml:"named"`
+	Value   string `yaml:"value" desc:"The volume to mount, of the form SOURCE:TARGET." vars:"true"`
+	IsNamed bool   `yaml:"named" desc:"Whether the volume is a named volume instead of a bind mount."`
+}
+
+// Healthcheck configures how to determine if a service is ready to accept connections.
+// At most one kind of check can be configured: Command, HTTPPath, or Port on its own
+// which results in a TCP check.
+type Healthcheck struct {
+	// Command is a shell command run in the container. The service is healthy if it exits with 0.
+	Command string `yaml:"command" desc:"Shell command run in the container, the service is healthy if it exits with 0."`
+	// HTTPPath is a path that is requested on Port. The service is healthy if it responds with a 2xx.
+	HTTPPath string `yaml:"httpPath" desc:"Path to request on port, the service is healthy if it responds with a 2xx."`
+	// Port is the port within the container to check. If HTTPPath is empty, a TCP check is performed.
+	Port int `yaml:"port" desc:"Port in the container to check, a TCP check is done if httpPath is omitted."`
+	// Interval is the duration between checks, ex: 5s.
+	Interval string `yaml:"interval" desc:"Time between checks, ex: 5s."`
+	// Retries is the number of consecutive failures needed to consider the service unhealthy.
+	Retries int `yaml:"retries" desc:"Number of consecutive failures before the service is considered unhealthy."`
+	// StartPeriod is the amount of time the service has to start before failures count towards Retries.
+	StartPeriod string `yaml:"startPeriod" desc:"Time the service has to start before failures are counted, ex: 30s."`
+}
+
+// IsZero reports whether no healthcheck is configured.
+func (h Healthcheck) IsZero() bool {
+	return h == Healthcheck{}
+}
+
+// test returns the healthcheck test in the form expected by docker compose.
+func (h Healthcheck) test() []string {
+	var cmd string
+	switch {
+	case h.Command != "":
+		cmd = h.Command
+	case h.HTTPPath != "":
+		path := h.HTTPPath
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		// Not every image has curl or wget so try both.
+		url := fmt.Sprintf("http://localhost:%d%s", h.Port, path)
+		cmd = fmt.Sprintf("wget -q -O /dev/null %[1]s || curl -fsS -o /dev/null %[1]s", url)
+	case h.Port != 0:
+		cmd = fmt.Sprintf("nc -z localhost %d", h.Port)
+	default:
+		return nil
+	}
+	return []string{"CMD-SHELL", cmd}
 }
 
 func (s Service) HasGitRepo() bool {
@@ -97,40 +152,434 @@ func Validate(s Service) error {
 	if s.Mode == ModeBuild && s.Build.DockerfilePath == "" {
 		msgs = append(msgs, "'mode' is set to 'build' but 'build.dockerfilePath' was not provided")
 	}
+	msgs = append(msgs, validateHealthcheck(s.Healthcheck)...)
+	if s.Replaces != "" {
+		if registryName, _, err := resource.ParseName(s.Replaces); err != nil || registryName == "" {
+			msgs = append(msgs, fmt.Sprintf("invalid 'replaces' value %q, must be the full name of a service", s.Replaces))
+		}
+	}
 	if msgs == nil {
 		return nil
 	}
 	return &resource.ValidationError{Resource: s, Messages: msgs}
 }
 
+// validPort reports whether p is a valid port mapping as understood by docker compose.
+// IPv6 addresses must be in brackets, ex: [::1]:8080:80. Mappings that use compose variables,
+// ex: ${HTTP_PORT}:8080, are only known once compose expands them so they are always valid.
+func validPort(p string) bool {
+	if strings.Contains(p, "${") {
+		return true
+	}
+	if i := strings.LastIndex(p, "/"); i != -1 {
+		switch p[i+1:] {
+		case "tcp", "udp", "sctp":
+		default:
+			return false
+		}
+		p = p[:i]
+	}
+	hasIP := false
+	if strings.HasPrefix(p, "[") {
+		end := strings.Index(p, "]:")
+		if end <= 1 {
+			return false
+		}
+		p, hasIP = p[end+2:], true
+	}
+	parts := strings.Split(p, ":")
+	if !hasIP && len(parts) == 3 {
+		if parts[0] == "" {
+			return false
+		}
+		parts, hasIP = parts[1:], true
+	}
+	switch len(parts) {
+	case 1:
+		// An ip requires a host port, even if it is empty.
+		return !hasIP && validPortRange(parts[0])
+	case 2:
+		// The host port is optional when an ip is provided, docker picks a random one.
+		return ((hasIP && parts[0] == "") || validPortRange(parts[0])) && validPortRange(parts[1])
+	}
+	return false
+}
+
+// validPortRange reports whether s is a port number or a range of ports, ex: 8080 or 8080-8090.
+func validPortRange(s string) bool {
+	start, end, isRange := strings.Cut(s, "-")
+	if !validPortNumber(start) {
+		return false
+	}
+	return !isRange || validPortNumber(end)
+}
+
+func validPortNumber(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// OffsetPort adds offset to the host port of the port mapping p. p must be a valid port mapping.
+// If p does not publish a specific host port, it is returned unchanged.
+// An error is returned if the resulting host port is out of range.
+func OffsetPort(p string, offset int) (string, error) {
+	const op = errors.Op("service.OffsetPort")
+	mapping, protocol, hasProtocol := strings.Cut(p, "/")
+	parts := strings.Split(mapping, ":")
+	// Host port is the second last part. If it is missing, docker picks a random
+	// host port, so there is nothing to offset.
+	hi := len(parts) - 2
+	if hi < 0 || parts[hi] == "" {
+		return p, nil
+	}
+	start, end, isRange := strings.Cut(parts[hi], "-")
+	offsetNumber := func(s string) (string, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return "", errors.New(errkind.Invalid, fmt.Sprintf("invalid host port %q in %q", s, p), op)
+		}
+		n += offset
+		if n <= 0 || n > 65535 {
+			msg := fmt.Sprintf("host port %s in %q is out of range with offset %d", s, p, offset)
+			return "", errors.New(errkind.Invalid, msg, op)
+		}
+		return strconv.Itoa(n), nil
+	}
+	hostPort, err := offsetNumber(start)
+	if err != nil {
+		return "", err
+	}
+	if isRange {
+		e, err := offsetNumber(end)
+		if err != nil {
+			return "", err
+		}
+		hostPort += "-" + e
+	}
+	parts[hi] = hostPort
+	mapping = strings.Join(parts, ":")
+	if hasProtocol {
+		mapping += "/" + protocol
+	}
+	return mapping, nil
+}
+
+func validateVolumes(volumes []Volume, fieldName string) []string {
+	var msgs []string
+	for _, v := range volumes {
+		if v.Value == "" {
+			msgs = append(msgs, fmt.Sprintf("'%s' cannot contain an empty value", fieldName))
+			continue
+		}
+		if v.IsNamed && !strings.Contains(v.Value, ":") {
+			msgs = append(msgs, fmt.Sprintf("invalid '%s' value %q, named volumes must be in the form name:path", fieldName, v.Value))
+		}
+	}
+	return msgs
+}
+
+func validateHealthcheck(h Healthcheck) []string {
+	if h.IsZero() {
+		return nil
+	}
+	var msgs []string
+	if h.Command != "" && (h.HTTPPath != "" || h.Port != 0) {
+		msgs = append(msgs, "'healthcheck.command' cannot be used with 'healthcheck.httpPath' or 'healthcheck.port'")
+	}
+	if h.Command == "" && h.Port == 0 {
+		msgs = append(msgs, "'healthcheck' requires either 'command' or 'port' to be provided")
+	}
+	if h.Port < 0 || h.Port > 65535 {
+		msgs = append(msgs, fmt.Sprintf("invalid 'healthcheck.port' value %d", h.Port))
+	}
+	if h.Retries < 0 {
+		msgs = append(msgs, "'healthcheck.retries' cannot be negative")
+	}
+	if h.Interval != "" {
+		if d, err := time.ParseDuration(h.Interval); err != nil || d <= 0 {
+			msgs = append(msgs, fmt.Sprintf("invalid 'healthcheck.interval' value %q, must be a positive duration", h.Interval))
+		}
+	}
+	if h.StartPeriod != "" {
+		if d, err := time.ParseDuration(h.StartPeriod); err != nil || d <= 0 {
+			msgs = append(msgs, fmt.Sprintf("invalid 'healthcheck.startPeriod' value %q, must be a positive duration", h.StartPeriod))
+		}
+	}
+	return msgs
+}
+
+// DependencyWaves groups services into waves based on their dependencies. Services in a wave
+// only depend on services in earlier waves, so all services in a wave can be acted on concurrently
+// once the previous waves are done. Services keep their relative order within a wave.
+//
+// Dependencies can either be the full name of a service or the name of its container.
+// Dependencies on services that are not part of services are ignored.
+//
+// If a dependency cycle is found, a resource.ValidationError will be returned.
+func DependencyWaves(services []Service) ([][]Service, error) {
+	// Map of container name to index in services for looking up dependencies.
+	indices := make(map[string]int, len(services))
+	for i, s := range services {
+		indices[docker.NormalizeName(s.FullName())] = i
+	}
+	// deps[i] is the list of indices of the services that services[i] depends on.
+	deps := make([][]int, len(services))
+	for i, s := range services {
+		for _, d := range s.Dependencies {
+			if j, ok := indices[docker.NormalizeName(d)]; ok && j != i {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+
+	var waves [][]Service
+	done := make([]bool, len(services))
+	remaining := len(services)
+	for remaining > 0 {
+		var wave []int
+		for i := range services {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, j := range deps[i] {
+				if !done[j] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, i)
+			}
+		}
+		if len(wave) == 0 {
+			// Every remaining service is waiting on another, so there must be a cycle.
+			return nil, dependencyCycleError(services, deps, done)
+		}
+		ws := make([]Service, len(wave))
+		for k, i := range wave {
+			ws[k] = services[i]
+			done[i] = true
+		}
+		waves = append(waves, ws)
+		remaining -= len(wave)
+	}
+	return waves, nil
+}
+
+// dependencyCycleError finds a cycle between the services that are not done
+// and returns a resource.ValidationError describing it.
+func dependencyCycleError(services []Service, deps [][]int, done []bool) error {
+	// Walk dependencies from any remaining service. Since every remaining service
+	// has an unfinished dependency, we will eventually revisit a service.
+	start := -1
+	for i := range services {
+		if !done[i] {
+			start = i
+			break
+		}
+	}
+	visited := make(map[int]int) // index of service -> position in path
+	var path []int
+	cur := start
+	for {
+		if pos, ok := visited[cur]; ok {
+			path = append(path[pos:], cur)
+			break
+		}
+		visited[cur] = len(path)
+		path = append(path, cur)
+		for _, j := range deps[cur] {
+			if !done[j] {
+				cur = j
+				break
+			}
+		}
+	}
+	names := make([]string, len(path))
+	for i, si := range path {
+		names[i] = services[si].FullName()
+	}
+	msg := fmt.Sprintf("circular dependency between services: %s", strings.Join(names, " -> "))
+	return &resource.ValidationError{Resource: services[path[0]], Messages: []string{msg}}
+}
+
 // ServiceOverride defines the overrides that should be applied to a Service.
 // It is a subset of the fields of Service, since not all fields are allowed to
 // be overridden.
 type ServiceOverride struct {
-	Build   BuildOverride     `yaml:"build"`
-	EnvVars map[string]string `yaml:"envVars"`
-	GitRepo GitRepoOverride   `yaml:"repo"`
-	Mode    string            `yaml:"mode"`
-	PreRun  string            `yaml:"preRun"`
-	Remote  RemoteOverride    `yaml:"remote"`
+	Build        BuildOverride      `yaml:"build" desc:"Overrides for building the service locally."`
+	Dependencies StringListOverride `yaml:"dependencies" desc:"Services to add or remove as dependencies, by full name or container name."`
+	Entrypoint   []string           `yaml:"entrypoint" desc:"Custom docker entrypoint."`
+	EnvFile      string             `yaml:"envFile" desc:"Path to a file containing env vars to set for the service."`
+	EnvVars      map[string]string  `yaml:"envVars" desc:"Env vars to set for the service, merged with the service's env vars."`
+	GitRepo      GitRepoOverride    `yaml:"repo" desc:"Overrides for the git repo containing the service."`
+	Mode         string             `yaml:"mode" desc:"Whether to pull the service from a remote source or build it locally." enum:"remote,build"`
+	Ports        StringListOverride `yaml:"ports" desc:"Ports to add or remove, of the form HOST:CONTAINER."`
+	PreRun       string             `yaml:"preRun" desc:"Script to run before starting the service."`
+	Remote       RemoteOverride     `yaml:"remote" desc:"Overrides for pulling the service from a remote docker registry."`
 }
 
 type BuildOverride struct {
-	Command string `yaml:"command"`
-	Target  string `yaml:"target"`
+	Command string             `yaml:"command" desc:"Command to run when the container starts."`
+	Target  string             `yaml:"target" desc:"Target to build in a multi-stage build."`
+	Volumes VolumeListOverride `yaml:"volumes" desc:"Volumes to add or remove."`
 }
 
 type GitRepoOverride struct {
-	Path string `yaml:"path"`
+	Path string `yaml:"path" desc:"Path to a local clone of the repo to use instead of the one managed by tb."`
 }
 
 type RemoteOverride struct {
-	Command string `yaml:"command"`
-	Tag     string `yaml:"tag"`
+	Command string             `yaml:"command" desc:"Command to run when the container starts."`
+	Tag     string             `yaml:"tag" desc:"Tag of the image to use, ex: master."`
+	Volumes VolumeListOverride `yaml:"volumes" desc:"Volumes to add or remove."`
+}
+
+// StringListOverride overrides a list of strings. Values in Remove are removed from the list
+// and then values in Add are added to the end of the list if they are not already present.
+//
+// In yaml it can either be a list, which is shorthand for only adding values, or an object
+// with add and remove keys.
+type StringListOverride struct {
+	Add    []string `yaml:"add"`
+	Remove []string `yaml:"remove"`
+}
+
+func (o *StringListOverride) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		o.Remove = nil
+		return node.Decode(&o.Add)
+	}
+	// Use a different type to prevent infinite recursion.
+	type rawOverride StringListOverride
+	return node.Decode((*rawOverride)(o))
+}
+
+// JSONSchema returns the schema for a StringListOverride, which is either a list or an object.
+func (StringListOverride) JSONSchema() *jsonschema.Schema {
+	list := &jsonschema.Schema{Type: "array", Items: &jsonschema.Schema{Type: jsonschema.ScalarType}}
+	return &jsonschema.Schema{OneOf: []*jsonschema.Schema{
+		list,
+		{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				"add":    {Description: "Values to add to the end of the list.", Type: "array", Items: list.Items},
+				"remove": {Description: "Values to remove from the list.", Type: "array", Items: list.Items},
+			},
+			AdditionalProperties: false,
+		},
+	}}
+}
+
+// apply applies the override to list and returns the result. eq is used to determine
+// if two values are the same.
+func (o StringListOverride) apply(list []string, eq func(a, b string) bool) []string {
+	if len(o.Add) == 0 && len(o.Remove) == 0 {
+		return list
+	}
+	contains := func(list []string, v string) bool {
+		for _, lv := range list {
+			if eq(lv, v) {
+				return true
+			}
+		}
+		return false
+	}
+	var result []string
+	for _, v := range list {
+		if !contains(o.Remove, v) {
+			result = append(result, v)
+		}
+	}
+	for _, v := range o.Add {
+		if !contains(result, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// VolumeListOverride overrides a list of volumes. Volumes in Remove are removed from the list
+// and then volumes in Add are added to the end of the list. Volumes to remove are identified
+// by their value.
+//
+// In yaml it can either be a list, which is shorthand for only adding volumes, or an object
+// with add and remove keys.
+type VolumeListOverride struct {
+	Add    []Volume `yaml:"add"`
+	Remove []string `yaml:"remove"`
+}
+
+func (o *VolumeListOverride) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		o.Remove = nil
+		return node.Decode(&o.Add)
+	}
+	// Use a different type to prevent infinite recursion.
+	type rawOverride VolumeListOverride
+	return node.Decode((*rawOverride)(o))
+}
+
+// JSONSchema returns the schema for a VolumeListOverride, which is either a list or an object.
+func (VolumeListOverride) JSONSchema() *jsonschema.Schema {
+	volume := &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"value": {Description: "The volume to mount, of the form SOURCE:TARGET.", Type: jsonschema.ScalarType},
+			"named": {Description: "Whether the volume is a named volume instead of a bind mount.", Type: "boolean"},
+		},
+		AdditionalProperties: false,
+	}
+	list := &jsonschema.Schema{Type: "array", Items: volume}
+	return &jsonschema.Schema{OneOf: []*jsonschema.Schema{
+		list,
+		{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				"add":    {Description: "Volumes to add to the end of the list.", Type: "array", Items: volume},
+				"remove": {Description: "Values of volumes to remove from the list.", Type: "array", Items: &jsonschema.Schema{Type: jsonschema.ScalarType}},
+			},
+			AdditionalProperties: false,
+		},
+	}}
+}
+
+func (o VolumeListOverride) apply(volumes []Volume) []Volume {
+	if len(o.Add) == 0 && len(o.Remove) == 0 {
+		return volumes
+	}
+	contains := func(list []Volume, value string) bool {
+		for _, v := range list {
+			if v.Value == value {
+				return true
+			}
+		}
+		return false
+	}
+	remove := make(map[string]bool, len(o.Remove))
+	for _, v := range o.Remove {
+		remove[v] = true
+	}
+	var result []Volume
+	for _, v := range volumes {
+		if !remove[v.Value] {
+			result = append(result, v)
+		}
+	}
+	for _, v := range o.Add {
+		if !contains(result, v.Value) {
+			result = append(result, v)
+		}
+	}
+	return result
 }
 
 // Override applies the overrides from o to s. If applying the override
 // results in an invalid configuration, Override will return an error.
+//
+// Dependencies can be specified using either the full name of a service or the name
+// of its container. Dependencies are added using the container name.
 func Override(s Service, o ServiceOverride) (Service, error) {
 	const op = errors.Op("service.Override")
 	// Validate overrides
@@ -148,6 +597,25 @@ func Override(s Service, o ServiceOverride) (Service, error) {
 		}
 	}
 
+	// Only values added by o are checked, the values of s are validated by Validate.
+	var msgs []string
+	for _, p := range o.Ports.Add {
+		if !validPort(p) {
+			msgs = append(msgs, fmt.Sprintf("invalid override value %q for '%s.ports', must be in the form [[ip:]host:]container[/protocol]", p, s.FullName()))
+		}
+	}
+	for _, d := range o.Dependencies.Add {
+		if d == "" {
+			msgs = append(msgs, fmt.Sprintf("'%s.dependencies' override cannot contain an empty value", s.FullName()))
+			break
+		}
+	}
+	msgs = append(msgs, validateVolumes(o.Build.Volumes.Add, s.FullName()+".build.volumes")...)
+	msgs = append(msgs, validateVolumes(o.Remote.Volumes.Add, s.FullName()+".remote.volumes")...)
+	if len(msgs) > 0 {
+		return s, errors.New(errkind.Invalid, strings.Join(msgs, ", "), op)
+	}
+
 	// Apply overrides
 	if o.Build.Command != "" {
 		s.Build.Command = o.Build.Command
@@ -155,11 +623,30 @@ func Override(s Service, o ServiceOverride) (Service, error) {
 	if o.Build.Target != "" {
 		s.Build.Target = o.Build.Target
 	}
+	s.Build.Volumes = o.Build.Volumes.apply(s.Build.Volumes)
+	depsOverride := o.Dependencies
+	depsOverride.Add = make([]string, len(o.Dependencies.Add))
+	for i, dep := range o.Dependencies.Add {
+		depsOverride.Add[i] = docker.NormalizeName(dep)
+	}
+	s.Dependencies = depsOverride.apply(s.Dependencies, func(a, b string) bool {
+		return docker.NormalizeName(a) == docker.NormalizeName(b)
+	})
+	if o.Entrypoint != nil {
+		s.Entrypoint = o.Entrypoint
+	}
+	if o.EnvFile != "" {
+		s.EnvFile = o.EnvFile
+	}
 	if o.EnvVars != nil {
+		if s.EnvVars == nil {
+			s.EnvVars = make(map[string]string, len(o.EnvVars))
+		}
 		for v, val := range o.EnvVars {
 			s.EnvVars[v] = val
 		}
 	}
+	s.Ports = o.Ports.apply(s.Ports, func(a, b string) bool { return a == b })
 	if o.PreRun != "" {
 		s.PreRun = o.PreRun
 	}
@@ -172,9 +659,110 @@ func Override(s Service, o ServiceOverride) (Service, error) {
 	if o.Remote.Tag != "" {
 		s.Remote.Tag = o.Remote.Tag
 	}
+	s.Remote.Volumes = o.Remote.Volumes.apply(s.Remote.Volumes)
+
+	if err := Validate(s); err != nil {
+		return s, errors.Wrap(err, errors.Meta{
+			Kind:   errkind.Invalid,
+			Reason: fmt.Sprintf("overrides for %s result in an invalid service", s.FullName()),
+			Op:     op,
+		})
+	}
 	return s, nil
 }
 
+// Extend merges the template t into s and returns the result. Values set in s take precedence
+// over values set in t. Maps, like EnvVars and Build.Args, are merged. Lists, like Dependencies,
+// Ports, and volumes, contain the values from t followed by the values from s that are not in t.
+// Entrypoint is the exception, it is a single command so the entrypoint of t is only used if
+// s has no entrypoint. Similarly, the kind of healthcheck, i.e. Command, HTTPPath, and Port, is only
+// used from t if s sets none of them, the other healthcheck fields are merged.
+// The Name, RegistryName, and Extends fields of s are always kept.
+//
+// Neither s or t are modified.
+func Extend(s, t Service) Service {
+	mergeString := func(sv, tv string) string {
+		if sv != "" {
+			return sv
+		}
+		return tv
+	}
+	mergeInt := func(sv, tv int) int {
+		if sv != 0 {
+			return sv
+		}
+		return tv
+	}
+	mergeMap := func(sm, tm map[string]string) map[string]string {
+		if sm == nil && tm == nil {
+			return nil
+		}
+		result := make(map[string]string, len(sm)+len(tm))
+		for k, v := range tm {
+			result[k] = v
+		}
+		for k, v := range sm {
+			result[k] = v
+		}
+		return result
+	}
+	mergeList := func(sl, tl []string) []string {
+		o := StringListOverride{Add: sl}
+		return o.apply(append([]string(nil), tl...), func(a, b string) bool { return a == b })
+	}
+	mergeVolumes := func(sv, tv []Volume) []Volume {
+		o := VolumeListOverride{Add: sv}
+		return o.apply(append([]Volume(nil), tv...))
+	}
+
+	result := Service{
+		Build: Build{
+			Args:           mergeMap(s.Build.Args, t.Build.Args),
+			Command:        mergeString(s.Build.Command, t.Build.Command),
+			DockerfilePath: mergeString(s.Build.DockerfilePath, t.Build.DockerfilePath),
+			Target:         mergeString(s.Build.Target, t.Build.Target),
+			Volumes:        mergeVolumes(s.Build.Volumes, t.Build.Volumes),
+		},
+		Dependencies: mergeList(s.Dependencies, t.Dependencies),
+		EnvFile:      mergeString(s.EnvFile, t.EnvFile),
+		EnvVars:      mergeMap(s.EnvVars, t.EnvVars),
+		GitRepo:      GitRepo{Name: mergeString(s.GitRepo.Name, t.GitRepo.Name)},
+		Healthcheck: Healthcheck{
+			Command:     t.Healthcheck.Command,
+			HTTPPath:    t.Healthcheck.HTTPPath,
+			Port:        t.Healthcheck.Port,
+			Interval:    mergeString(s.Healthcheck.Interval, t.Healthcheck.Interval),
+			Retries:     mergeInt(s.Healthcheck.Retries, t.Healthcheck.Retries),
+			StartPeriod: mergeString(s.Healthcheck.StartPeriod, t.Healthcheck.StartPeriod),
+		},
+		Mode:   mergeString(s.Mode, t.Mode),
+		Ports:  mergeList(s.Ports, t.Ports),
+		PreRun: mergeString(s.PreRun, t.PreRun),
+		Remote: Remote{
+			Command: mergeString(s.Remote.Command, t.Remote.Command),
+			Image:   mergeString(s.Remote.Image, t.Remote.Image),
+			Tag:     mergeString(s.Remote.Tag, t.Remote.Tag),
+			Volumes: mergeVolumes(s.Remote.Volumes, t.Remote.Volumes),
+		},
+		Extends:      s.Extends,
+		Replaces:     s.Replaces,
+		Name:         s.Name,
+		RegistryName: s.RegistryName,
+	}
+	// The kind of check is a unit, mixing the check of t with the check of s would result
+	// in an invalid healthcheck, ex: a command with an httpPath.
+	if s.Healthcheck.Command != "" || s.Healthcheck.HTTPPath != "" || s.Healthcheck.Port != 0 {
+		result.Healthcheck.Command = s.Healthcheck.Command
+		result.Healthcheck.HTTPPath = s.Healthcheck.HTTPPath
+		result.Healthcheck.Port = s.Healthcheck.Port
+	}
+	result.Entrypoint = append([]string(nil), t.Entrypoint...)
+	if s.Entrypoint != nil {
+		result.Entrypoint = append([]string(nil), s.Entrypoint...)
+	}
+	return result
+}
+
 // DISCUSS(@cszatmary): Does this make sense here? I honestly struggled with where to put this the most.
 // I considerered the following:
 // config: Does not seem like config's business though as config deals with the higher level glue code.
@@ -182,8 +770,17 @@ func Override(s Service, o ServiceOverride) (Service, error) {
 // services: This logic has a lot to do with services so we can justify that services supports mapping to
 // other formats.
 
+// ComposeOptions customizes the compose config created by ComposeConfig.
+type ComposeOptions struct {
+	// ContainerNamePrefix is prepended to the name of each container.
+	ContainerNamePrefix string
+	// PortOffset is added to each published host port. Ports must have already been
+	// checked with OffsetPort to ensure they are valid with the offset applied.
+	PortOffset int
+}
+
 // ComposeConfig maps the Collection to a docker compose config.
-func ComposeConfig(c *resource.Collection[Service]) docker.ComposeConfig {
+func ComposeConfig(c *resource.Collection[Service], opts ComposeOptions) docker.ComposeConfig {
 	composeConfig := docker.ComposeConfig{
 		Version:  "3.7",
 		Services: make(map[string]docker.ComposeServiceConfig),
@@ -193,15 +790,30 @@ func ComposeConfig(c *resource.Collection[Service]) docker.ComposeConfig {
 		s := it.Value()
 		dockerName := docker.NormalizeName(s.FullName())
 		cs := docker.ComposeServiceConfig{
-			ContainerName: dockerName,
+			ContainerName: opts.ContainerNamePrefix + dockerName,
 			DependsOn:     s.Dependencies,
 			Entrypoint:    s.Entrypoint,
 			Environment:   s.EnvVars,
 			Ports:         s.Ports,
 		}
+		if opts.PortOffset != 0 {
+			cs.Ports = make([]string, len(s.Ports))
+			for i, p := range s.Ports {
+				// Ignore the error, it is documented that ports must already be checked.
+				cs.Ports[i], _ = OffsetPort(p, opts.PortOffset)
+			}
+		}
 		if s.EnvFile != "" {
 			cs.EnvFile = append(cs.EnvFile, s.EnvFile)
 		}
+		if !s.Healthcheck.IsZero() {
+			cs.Healthcheck = docker.ComposeHealthcheckConfig{
+				Test:        s.Healthcheck.test(),
+				Interval:    s.Healthcheck.Interval,
+				Retries:     s.Healthcheck.Retries,
+				StartPeriod: s.Healthcheck.StartPeriod,
+			}
+		}
 
 		var volumes []Volume
 		if s.Mode == ModeRemote {
@@ -8,6 +8,7 @@ import (
 	"github.com/TouchBistro/tb/resource"
 	"github.com/TouchBistro/tb/resource/service"
 	"github.com/matryer/is"
+	"gopkg.in/yaml.v3"
 )
 
 func TestServiceMethods(t *testing.T) {
@@ -110,6 +111,77 @@ func TestValidate(t *testing.T) {
 			wantErr:    true,
 			wantMsgLen: 1,
 		},
+		{
+			name: "valid healthcheck",
+			service: service.Service{
+				Healthcheck: service.Healthcheck{
+					HTTPPath:    "/ping",
+					Port:        8080,
+					Interval:    "5s",
+					Retries:     3,
+					StartPeriod: "1m",
+				},
+				Mode: service.ModeRemote,
+				Remote: service.Remote{
+					Image: "venue-core-service",
+				},
+				Name:         "venue-core-service",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid healthcheck",
+			service: service.Service{
+				Healthcheck: service.Healthcheck{
+					Command:  "pg_isready",
+					Port:     5432,
+					Interval: "often",
+				},
+				Mode: service.ModeRemote,
+				Remote: service.Remote{
+					Image: "postgres",
+				},
+				Name:         "postgres",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+			wantErr:    true,
+			wantMsgLen: 2,
+		},
+		{
+			name: "healthcheck non-positive durations",
+			service: service.Service{
+				Healthcheck: service.Healthcheck{
+					Command:     "pg_isready",
+					Interval:    "-5s",
+					StartPeriod: "0s",
+				},
+				Mode: service.ModeRemote,
+				Remote: service.Remote{
+					Image: "postgres",
+				},
+				Name:         "postgres",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+			wantErr:    true,
+			wantMsgLen: 2,
+		},
+		{
+			name: "healthcheck missing check",
+			service: service.Service{
+				Healthcheck: service.Healthcheck{
+					HTTPPath: "/ping",
+				},
+				Mode: service.ModeRemote,
+				Remote: service.Remote{
+					Image: "postgres",
+				},
+				Name:         "postgres",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+			wantErr:    true,
+			wantMsgLen: 1,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -127,9 +199,68 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestOverridePorts(t *testing.T) {
+	s := service.Service{
+		Mode:         service.ModeRemote,
+		Ports:        []string{"[::1]:5432:5432"},
+		Remote:       service.Remote{Image: "postgres", Tag: "12-alpine"},
+		Name:         "postgres",
+		RegistryName: "TouchBistro/tb-registry",
+	}
+	// Ports of services aren't checked since they are passed to docker compose as is.
+	is := is.New(t)
+	is.NoErr(service.Validate(s))
+
+	// Forms of port mappings docker compose accepts.
+	ports := []string{
+		"5432",
+		"5433:5432",
+		"9000-9002:9000-9002",
+		"127.0.0.1:5434:5432",
+		"127.0.0.1::5432",
+		"[::1]:5435:5432",
+		"[::1]::5432",
+		"5436:5432/udp",
+		"${POSTGRES_PORT}:5432",
+	}
+	for _, p := range ports {
+		t.Run(p, func(t *testing.T) {
+			is := is.New(t)
+			got, err := service.Override(s, service.ServiceOverride{
+				Ports: service.StringListOverride{Add: []string{p}},
+			})
+			is.NoErr(err)
+			is.Equal(got.Ports, []string{"[::1]:5432:5432", p})
+		})
+	}
+
+	invalidPorts := []string{
+		"5432:postgres",
+		"70000:5432",
+		"[::1]:5432",
+		"[::1:5432:5432",
+		":5432:5432",
+		"5432:5432/http",
+		"1:2:3:4",
+	}
+	for _, p := range invalidPorts {
+		t.Run(p, func(t *testing.T) {
+			is := is.New(t)
+			_, err := service.Override(s, service.ServiceOverride{
+				Ports: service.StringListOverride{Add: []string{p}},
+			})
+			is.True(err != nil)
+		})
+	}
+}
+
 func TestOverride(t *testing.T) {
 	is := is.New(t)
 	s := service.Service{
+		Dependencies: []string{
+			"touchbistro-tb-registry-postgres",
+			"touchbistro-tb-registry-localstack",
+		},
 		EnvFile: ".tb/repos/TouchBistro/venue-core-service/.env.example",
 		EnvVars: map[string]string{
 			"HTTP_PORT": "8080",
@@ -137,6 +268,7 @@ func TestOverride(t *testing.T) {
 		Mode: service.ModeBuild,
 		Ports: []string{
 			"8081:8080",
+			"9229:9229",
 		},
 		PreRun: "yarn db:prepare:dev",
 		GitRepo: service.GitRepo{
@@ -149,6 +281,9 @@ func TestOverride(t *testing.T) {
 			Command:        "yarn start",
 			DockerfilePath: ".tb/repos/TouchBistro/venue-core-service",
 			Target:         "release",
+			Volumes: []service.Volume{
+				{Value: "venue-core-service-node_modules:/app/node_modules", IsNamed: true},
+			},
 		},
 		Remote: service.Remote{
 			Image: "venue-core-service",
@@ -157,32 +292,54 @@ func TestOverride(t *testing.T) {
 		RegistryName: "TouchBistro/tb-registry",
 	}
 	o := service.ServiceOverride{
+		Dependencies: service.StringListOverride{
+			Add:    []string{"TouchBistro/tb-registry/redis"},
+			Remove: []string{"TouchBistro/tb-registry/localstack"},
+		},
+		Entrypoint: []string{"node", "--inspect=0.0.0.0:9229"},
+		EnvFile:    "/home/foo/venue-core-service/.env",
 		EnvVars: map[string]string{
 			"LOGGER_LEVEL": "debug",
 		},
-		Mode:   service.ModeRemote,
+		Mode: service.ModeRemote,
+		Ports: service.StringListOverride{
+			Add:    []string{"9081:8080", "9229:9229"},
+			Remove: []string{"8081:8080"},
+		},
 		PreRun: "yarn db:prepare",
 		Build: service.BuildOverride{
 			Command: "yarn start:dev",
 			Target:  "dev",
+			Volumes: service.VolumeListOverride{
+				Remove: []string{"venue-core-service-node_modules:/app/node_modules"},
+			},
 		},
 		Remote: service.RemoteOverride{
 			Command: "tail -f /dev/null",
 			Tag:     "master",
+			Volumes: service.VolumeListOverride{
+				Add: []service.Volume{{Value: "/home/foo/venue-core-service/dist:/app/dist"}},
+			},
 		},
 	}
 
 	overridden, err := service.Override(s, o)
 	is.NoErr(err)
 	is.Equal(overridden, service.Service{
-		EnvFile: ".tb/repos/TouchBistro/venue-core-service/.env.example",
+		Dependencies: []string{
+			"touchbistro-tb-registry-postgres",
+			"touchbistro-tb-registry-redis",
+		},
+		Entrypoint: []string{"node", "--inspect=0.0.0.0:9229"},
+		EnvFile:    "/home/foo/venue-core-service/.env",
 		EnvVars: map[string]string{
 			"HTTP_PORT":    "8080",
 			"LOGGER_LEVEL": "debug",
 		},
 		Mode: service.ModeRemote,
 		Ports: []string{
-			"8081:8080",
+			"9229:9229",
+			"9081:8080",
 		},
 		PreRun: "yarn db:prepare",
 		GitRepo: service.GitRepo{
@@ -200,12 +357,181 @@ func TestOverride(t *testing.T) {
 			Command: "tail -f /dev/null",
 			Image:   "venue-core-service",
 			Tag:     "master",
+			Volumes: []service.Volume{
+				{Value: "/home/foo/venue-core-service/dist:/app/dist"},
+			},
 		},
 		Name:         "venue-core-service",
 		RegistryName: "TouchBistro/tb-registry",
 	})
 }
 
+func TestExtend(t *testing.T) {
+	is := is.New(t)
+	template := service.Service{
+		Dependencies: []string{"${@postgres}"},
+		Entrypoint:   []string{"bash", "entrypoints/docker.sh"},
+		EnvFile:      "${@REPOPATH}/.env.compose",
+		EnvVars: map[string]string{
+			"NODE_ENV":  "development",
+			"HTTP_PORT": "8080",
+		},
+		Healthcheck: service.Healthcheck{
+			HTTPPath: "/ping",
+			Port:     8080,
+		},
+		Mode: service.ModeBuild,
+		Build: service.Build{
+			Args:           map[string]string{"NPM_TOKEN": "$NPM_TOKEN"},
+			DockerfilePath: "${@REPOPATH}",
+			Target:         "dev",
+			Volumes: []service.Volume{
+				{Value: "${@REPOPATH}:/home/node/app:delegated"},
+			},
+		},
+	}
+	s := service.Service{
+		Dependencies: []string{"${@postgres}", "${@redis}"},
+		EnvVars: map[string]string{
+			"HTTP_PORT": "9000",
+		},
+		Healthcheck: service.Healthcheck{
+			Port: 9000,
+		},
+		Ports: []string{"9000:9000"},
+		GitRepo: service.GitRepo{
+			Name: "TouchBistro/venue-core-service",
+		},
+		Build: service.Build{
+			Command: "yarn start",
+			Volumes: []service.Volume{
+				{Value: "venue-core-service-node_modules:/home/node/app/node_modules", IsNamed: true},
+			},
+		},
+		Extends:      "node",
+		Name:         "venue-core-service",
+		RegistryName: "TouchBistro/tb-registry",
+	}
+
+	extended := service.Extend(s, template)
+	is.Equal(extended, service.Service{
+		Dependencies: []string{"${@postgres}", "${@redis}"},
+		Entrypoint:   []string{"bash", "entrypoints/docker.sh"},
+		EnvFile:      "${@REPOPATH}/.env.compose",
+		EnvVars: map[string]string{
+			"NODE_ENV":  "development",
+			"HTTP_PORT": "9000",
+		},
+		// The service sets port so the httpPath of the template is not used, resulting in a TCP check.
+		Healthcheck: service.Healthcheck{
+			Port: 9000,
+		},
+		Mode:  service.ModeBuild,
+		Ports: []string{"9000:9000"},
+		GitRepo: service.GitRepo{
+			Name: "TouchBistro/venue-core-service",
+		},
+		Build: service.Build{
+			Args:           map[string]string{"NPM_TOKEN": "$NPM_TOKEN"},
+			Command:        "yarn start",
+			DockerfilePath: "${@REPOPATH}",
+			Target:         "dev",
+			Volumes: []service.Volume{
+				{Value: "${@REPOPATH}:/home/node/app:delegated"},
+				{Value: "venue-core-service-node_modules:/home/node/app/node_modules", IsNamed: true},
+			},
+		},
+		Extends:      "node",
+		Name:         "venue-core-service",
+		RegistryName: "TouchBistro/tb-registry",
+	})
+	// The template must not be modified since it is shared by services.
+	is.Equal(template.EnvVars["HTTP_PORT"], "8080")
+	is.Equal(len(template.Build.Volumes), 1)
+}
+
+func TestExtendHealthcheck(t *testing.T) {
+	is := is.New(t)
+	template := service.Service{
+		Healthcheck: service.Healthcheck{
+			Command:     "pg_isready",
+			Interval:    "2s",
+			Retries:     10,
+			StartPeriod: "30s",
+		},
+		Mode: service.ModeRemote,
+		Remote: service.Remote{
+			Image: "postgres",
+			Tag:   "12",
+		},
+	}
+
+	// The service sets a different kind of check, only the timing fields are inherited.
+	s := service.Service{
+		Healthcheck: service.Healthcheck{
+			HTTPPath: "/ping",
+			Port:     8080,
+			Retries:  3,
+		},
+		Extends:      "postgres",
+		Name:         "venue-core-service",
+		RegistryName: "TouchBistro/tb-registry",
+	}
+	extended := service.Extend(s, template)
+	is.Equal(extended.Healthcheck, service.Healthcheck{
+		HTTPPath:    "/ping",
+		Port:        8080,
+		Interval:    "2s",
+		Retries:     3,
+		StartPeriod: "30s",
+	})
+	is.NoErr(service.Validate(extended))
+
+	// The service sets no kind of check, the check of the template is used.
+	s.Healthcheck = service.Healthcheck{Interval: "5s"}
+	extended = service.Extend(s, template)
+	is.Equal(extended.Healthcheck, service.Healthcheck{
+		Command:     "pg_isready",
+		Interval:    "5s",
+		Retries:     10,
+		StartPeriod: "30s",
+	})
+}
+
+func TestDecodeListOverrides(t *testing.T) {
+	const data = `
+ports:
+  - 9081:8080
+dependencies:
+  add:
+    - TouchBistro/tb-registry/redis
+  remove:
+    - TouchBistro/tb-registry/localstack
+remote:
+  volumes:
+    remove:
+      - /tmp:/tmp
+`
+	is := is.New(t)
+	var o service.ServiceOverride
+	err := yaml.Unmarshal([]byte(data), &o)
+	is.NoErr(err)
+	is.Equal(o, service.ServiceOverride{
+		Dependencies: service.StringListOverride{
+			Add:    []string{"TouchBistro/tb-registry/redis"},
+			Remove: []string{"TouchBistro/tb-registry/localstack"},
+		},
+		Ports: service.StringListOverride{
+			Add: []string{"9081:8080"},
+		},
+		Remote: service.RemoteOverride{
+			Volumes: service.VolumeListOverride{
+				Remove: []string{"/tmp:/tmp"},
+			},
+		},
+	})
+}
+
 func TestOverrideError(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -238,6 +564,40 @@ func TestOverrideError(t *testing.T) {
 				Mode: service.ModeRemote,
 			},
 		},
+		{
+			name: "invalid port",
+			service: service.Service{
+				Mode: service.ModeRemote,
+				Remote: service.Remote{
+					Image: "postgres",
+					Tag:   "12-alpine",
+				},
+				Name:         "postgres",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+			override: service.ServiceOverride{
+				Ports: service.StringListOverride{Add: []string{"5432:postgres"}},
+			},
+		},
+		{
+			name: "invalid named volume",
+			service: service.Service{
+				Mode: service.ModeRemote,
+				Remote: service.Remote{
+					Image: "postgres",
+					Tag:   "12-alpine",
+				},
+				Name:         "postgres",
+				RegistryName: "TouchBistro/tb-registry",
+			},
+			override: service.ServiceOverride{
+				Remote: service.RemoteOverride{
+					Volumes: service.VolumeListOverride{
+						Add: []service.Volume{{Value: "postgres", IsNamed: true}},
+					},
+				},
+			},
+		},
 		{
 			name: "cannot override to build",
 			service: service.Service{
@@ -306,6 +666,11 @@ func TestComposeConfig(t *testing.T) {
 					},
 				},
 			},
+			Healthcheck: service.Healthcheck{
+				Command:  "pg_isready -U core",
+				Interval: "2s",
+				Retries:  10,
+			},
 			Name:         "postgres",
 			RegistryName: "TouchBistro/tb-registry",
 		},
@@ -340,6 +705,10 @@ func TestComposeConfig(t *testing.T) {
 					},
 				},
 			},
+			Healthcheck: service.Healthcheck{
+				HTTPPath: "ping",
+				Port:     8080,
+			},
 			Name:         "venue-core-service",
 			RegistryName: "TouchBistro/tb-registry",
 		},
@@ -370,6 +739,11 @@ func TestComposeConfig(t *testing.T) {
 					"DB_PASSWORD": "localdev",
 					"DB_USER":     "core",
 				},
+				Healthcheck: docker.ComposeHealthcheckConfig{
+					Test:     []string{"CMD-SHELL", "pg_isready -U core"},
+					Interval: "2s",
+					Retries:  10,
+				},
 				Image:   "postgres:10.6-alpine",
 				Ports:   []string{"5432:5432"},
 				Volumes: []string{"postgres:/var/lib/postgresql/data"},
@@ -391,13 +765,120 @@ func TestComposeConfig(t *testing.T) {
 					"DB_HOST":   "touchbistro-tb-registry-postgres",
 					"HTTP_PORT": "8080",
 				},
+				Healthcheck: docker.ComposeHealthcheckConfig{
+					Test: []string{
+						"CMD-SHELL",
+						"wget -q -O /dev/null http://localhost:8080/ping || curl -fsS -o /dev/null http://localhost:8080/ping",
+					},
+				},
 				Ports:   []string{"8081:8080"},
 				Volumes: []string{".tb/repos/TouchBistro/venue-core-service:/home/node/app:delegated"},
 			},
 		},
 		Volumes: map[string]interface{}{"postgres": nil},
 	}
-	composeConfig := service.ComposeConfig(&c)
+	composeConfig := service.ComposeConfig(&c, service.ComposeOptions{})
 	is := is.New(t)
 	is.Equal(composeConfig, wantComposeConfig)
 }
+
+func TestComposeConfigWorkspace(t *testing.T) {
+	var c resource.Collection[service.Service]
+	err := c.Set(service.Service{
+		Mode:         service.ModeRemote,
+		Ports:        []string{"5432:5432", "127.0.0.1:6379:6379/tcp", "9000-9002:9000-9002", "8080"},
+		Remote:       service.Remote{Image: "postgres"},
+		Name:         "postgres",
+		RegistryName: "TouchBistro/tb-registry",
+	})
+	is := is.New(t)
+	is.NoErr(err)
+	composeConfig := service.ComposeConfig(&c, service.ComposeOptions{
+		ContainerNamePrefix: "feature-",
+		PortOffset:          100,
+	})
+	cs := composeConfig.Services["touchbistro-tb-registry-postgres"]
+	is.Equal(cs.ContainerName, "feature-touchbistro-tb-registry-postgres")
+	is.Equal(cs.Ports, []string{"5532:5432", "127.0.0.1:6479:6379/tcp", "9100-9102:9000-9002", "8080"})
+}
+
+func TestOffsetPort(t *testing.T) {
+	tests := []struct {
+		name   string
+		port   string
+		offset int
+		want   string
+	}{
+		{"host and container", "8080:80", 10, "8090:80"},
+		{"ip", "127.0.0.1:8080:80", 10, "127.0.0.1:8090:80"},
+		{"ip without host port", "127.0.0.1::80", 10, "127.0.0.1::80"},
+		{"container only", "80", 10, "80"},
+		{"range with protocol", "5000-5002:5000-5002/udp", 1, "5001-5003:5000-5002/udp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			got, err := service.OffsetPort(tt.port, tt.offset)
+			is.NoErr(err)
+			is.Equal(got, tt.want)
+		})
+	}
+}
+
+func TestOffsetPortOutOfRange(t *testing.T) {
+	is := is.New(t)
+	_, err := service.OffsetPort("65000:80", 1000)
+	is.True(err != nil)
+}
+
+func TestDependencyWaves(t *testing.T) {
+	postgres := service.Service{Name: "postgres", RegistryName: "TouchBistro/tb-registry"}
+	redis := service.Service{Name: "redis", RegistryName: "TouchBistro/tb-registry"}
+	vcs := service.Service{
+		Dependencies: []string{"touchbistro-tb-registry-postgres", "touchbistro-tb-registry-redis"},
+		Name:         "venue-core-service",
+		RegistryName: "TouchBistro/tb-registry",
+	}
+	mas := service.Service{
+		// Full names can be used too and dependencies not being started are ignored.
+		Dependencies: []string{"TouchBistro/tb-registry/venue-core-service", "touchbistro-tb-registry-localstack"},
+		Name:         "menu-admin-service",
+		RegistryName: "TouchBistro/tb-registry",
+	}
+
+	is := is.New(t)
+	waves, err := service.DependencyWaves([]service.Service{mas, vcs, postgres, redis})
+	is.NoErr(err)
+	is.Equal(waves, [][]service.Service{
+		{postgres, redis},
+		{vcs},
+		{mas},
+	})
+}
+
+func TestDependencyWavesCycle(t *testing.T) {
+	services := []service.Service{
+		{
+			Name:         "postgres",
+			RegistryName: "TouchBistro/tb-registry",
+		},
+		{
+			Dependencies: []string{"touchbistro-tb-registry-postgres", "touchbistro-tb-registry-menu-admin-service"},
+			Name:         "venue-core-service",
+			RegistryName: "TouchBistro/tb-registry",
+		},
+		{
+			Dependencies: []string{"touchbistro-tb-registry-venue-core-service"},
+			Name:         "menu-admin-service",
+			RegistryName: "TouchBistro/tb-registry",
+		},
+	}
+
+	is := is.New(t)
+	_, err := service.DependencyWaves(services)
+	var validationErr *resource.ValidationError
+	is.True(errors.As(err, &validationErr))
+	is.Equal(validationErr.Messages, []string{
+		"circular dependency between services: TouchBistro/tb-registry/venue-core-service -> TouchBistro/tb-registry/menu-admin-service -> TouchBistro/tb-registry/venue-core-service",
+	})
+}